@@ -0,0 +1,530 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/api"
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+const (
+	batchFormatAuto   = "auto"
+	batchFormatCSV    = "csv"
+	batchFormatNDJSON = "ndjson"
+	// batchWindowPerWorker bounds the rows held in memory per worker while
+	// results are written back in input order.
+	batchWindowPerWorker = 4
+)
+
+// batchCommand calculates every row of an order file. Rows stream through a
+// bounded, order-preserving worker pipeline, so memory use does not depend on
+// the file size.
+type batchCommand struct {
+	cmd         *kingpin.CmdClause
+	input       *string
+	output      *string
+	inputFormat *string
+	itemsColumn *string
+	noHeader    *bool
+	workers     *int
+	packSizes   *string
+	tieBreak    *string
+}
+
+func newBatchCommand(app *kingpin.Application) *batchCommand {
+	b := &batchCommand{
+		cmd: app.Command("batch", "Calculate packs for every order in a CSV or NDJSON file"),
+	}
+	b.input = b.cmd.Flag("input", "Input file, or - for stdin").Default("-").String()
+	b.output = b.cmd.Flag("output", "Output file, or - for stdout").Default("-").String()
+	b.inputFormat = b.cmd.Flag("input-format", "Input format: auto (from the file extension), csv, or ndjson").Default(batchFormatAuto).Enum(batchFormatAuto, batchFormatCSV, batchFormatNDJSON)
+	b.itemsColumn = b.cmd.Flag("items-column", "CSV column or NDJSON field holding the quantity; a number selects a CSV column by 1-based position").Default("quantity").String()
+	b.noHeader = b.cmd.Flag("no-header", "The CSV input has no header row").Bool()
+	b.workers = b.cmd.Flag("workers", "Number of concurrent calculations").Default(strconv.Itoa(runtime.GOMAXPROCS(0))).Int()
+	b.packSizes = b.cmd.Flag("pack-sizes", "Comma-separated pack sizes (defaults to the configured sizes)").String()
+	b.tieBreak = b.cmd.Flag("tie-break", "Tie-break policy (defaults to the configured policy)").String()
+	return b
+}
+
+func (b *batchCommand) run(configFile string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if *b.workers < 1 {
+		fmt.Fprintln(stderr, "error: --workers must be at least 1")
+		return exitInvalid
+	}
+
+	settings, failure, ok := loadCalculationSettings(configFile, *b.packSizes, *b.tieBreak)
+	if !ok {
+		return reportFailure(stderr, formatTable, failure)
+	}
+
+	in := stdin
+	if *b.input != "-" {
+		file, err := os.Open(*b.input)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitInvalid
+		}
+		defer file.Close()
+		in = file
+	}
+
+	out := stdout
+	var outFile *os.File
+	if *b.output != "-" {
+		file, err := os.Create(*b.output)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return exitFailure
+		}
+		outFile = file
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+
+	reader, writer, err := b.codec(in, buffered)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+
+	ctx := calculator.WithTieBreak(context.Background(), settings.tieBreak)
+	summary, err := processBatch(ctx, reader, writer, calculator.New(), settings.packSizes, *b.workers)
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if outFile != nil {
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return exitInvalid
+		}
+		return exitFailure
+	}
+
+	fmt.Fprintf(stderr, "processed %d rows, %d failed\n", summary.rows, summary.failed)
+	return exitCodeForStatus(summary.firstFailureStatus)
+}
+
+// codec picks the reader and writer for the input format.
+func (b *batchCommand) codec(in io.Reader, out io.Writer) (batchReader, batchWriter, error) {
+	format := *b.inputFormat
+	if format == batchFormatAuto {
+		format = batchFormatCSV
+		switch strings.ToLower(filepath.Ext(*b.input)) {
+		case ".ndjson", ".jsonl":
+			format = batchFormatNDJSON
+		}
+	}
+
+	if format == batchFormatNDJSON {
+		return newNDJSONBatchReader(in, *b.itemsColumn), &ndjsonBatchWriter{w: out}, nil
+	}
+
+	reader, err := newCSVBatchReader(in, *b.itemsColumn, !*b.noHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer := &csvBatchWriter{w: csv.NewWriter(out)}
+	if reader.header != nil {
+		if err := writer.writeHeader(reader.header); err != nil {
+			return nil, nil, err
+		}
+	}
+	return reader, writer, nil
+}
+
+// batchRow is one input record together with its parsed quantity.
+type batchRow struct {
+	fields []string
+	// raw is the NDJSON line; object reports whether it is a JSON object.
+	raw    []byte
+	object bool
+	items  int
+	// failure is set when the row could not be parsed.
+	failure *api.Failure
+}
+
+// batchOutcome is the calculation result for a row.
+type batchOutcome struct {
+	counts     map[int]int
+	totalPacks int
+	overshoot  int
+	failure    *api.Failure
+}
+
+type batchReader interface {
+	// Next returns the next row or io.EOF.
+	Next() (batchRow, error)
+}
+
+type batchWriter interface {
+	Write(row batchRow, outcome batchOutcome) error
+	Flush() error
+}
+
+type batchSummary struct {
+	rows               int
+	failed             int
+	firstFailureStatus int
+}
+
+// processBatch calculates rows concurrently and writes them in input order.
+// At most batchWindowPerWorker rows per worker are in flight at any time.
+func processBatch(ctx context.Context, reader batchReader, writer batchWriter, calc calculator.Calculator, packSizes []int, workers int) (batchSummary, error) {
+	type pending struct {
+		row  batchRow
+		done chan batchOutcome
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan pending, workers)
+	ordered := make(chan pending, workers*batchWindowPerWorker)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				p.done <- calculateRow(ctx, calc, packSizes, p.row)
+			}
+		}()
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(ordered)
+		defer close(work)
+		for {
+			row, err := reader.Next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+			p := pending{row: row, done: make(chan batchOutcome, 1)}
+			select {
+			case ordered <- p:
+			case <-ctx.Done():
+				readErr <- nil
+				return
+			}
+			work <- p
+		}
+	}()
+
+	var summary batchSummary
+	var writeErr error
+	for p := range ordered {
+		outcome := <-p.done
+		if writeErr != nil {
+			continue
+		}
+		summary.rows++
+		if outcome.failure != nil {
+			summary.failed++
+			if summary.firstFailureStatus == 0 {
+				summary.firstFailureStatus = outcome.failure.Status
+			}
+		}
+		if writeErr = writer.Write(p.row, outcome); writeErr != nil {
+			cancel()
+		}
+	}
+	wg.Wait()
+
+	if writeErr != nil {
+		return summary, writeErr
+	}
+	if err := <-readErr; err != nil {
+		return summary, err
+	}
+	return summary, writer.Flush()
+}
+
+func calculateRow(ctx context.Context, calc calculator.Calculator, packSizes []int, row batchRow) batchOutcome {
+	if row.failure != nil {
+		return batchOutcome{failure: row.failure}
+	}
+
+	counts, err := calculator.CalculateWithContext(ctx, calc, row.items, packSizes)
+	if err != nil {
+		failure := api.CalculationFailure(row.items, err)
+		return batchOutcome{failure: &failure}
+	}
+
+	outcome := batchOutcome{counts: counts}
+	shipped := 0
+	for size, count := range counts {
+		outcome.totalPacks += count
+		shipped += size * count
+	}
+	outcome.overshoot = shipped - row.items
+	return outcome
+}
+
+// csvBatchReader reads order rows from CSV.
+type csvBatchReader struct {
+	r        *csv.Reader
+	header   []string
+	itemsIdx int
+}
+
+func newCSVBatchReader(in io.Reader, itemsColumn string, hasHeader bool) (*csvBatchReader, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	reader := &csvBatchReader{r: r, itemsIdx: -1}
+
+	if position, err := strconv.Atoi(itemsColumn); err == nil {
+		if position < 1 {
+			return nil, fmt.Errorf("items column position must be at least 1, got %d", position)
+		}
+		reader.itemsIdx = position - 1
+	}
+
+	if hasHeader {
+		header, err := r.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("input is empty: expected a header row")
+			}
+			return nil, err
+		}
+		reader.header = header
+		if reader.itemsIdx < 0 {
+			reader.itemsIdx = slices.Index(header, itemsColumn)
+		}
+	}
+	if reader.itemsIdx < 0 {
+		return nil, fmt.Errorf("items column %q not found in header", itemsColumn)
+	}
+	return reader, nil
+}
+
+func (c *csvBatchReader) Next() (batchRow, error) {
+	fields, err := c.r.Read()
+	if err != nil {
+		return batchRow{}, err
+	}
+	row := batchRow{fields: fields}
+	if c.itemsIdx >= len(fields) {
+		row.failure = &api.Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: "items column is missing"}
+		return row, nil
+	}
+	row.items, row.failure = parseBatchItems(strings.TrimSpace(fields[c.itemsIdx]))
+	return row, nil
+}
+
+// csvBatchWriter echoes each CSV row with the result columns appended.
+type csvBatchWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvBatchWriter) writeHeader(header []string) error {
+	return c.w.Write(append(slices.Clone(header), "packs", "total_packs", "overshoot", "error"))
+}
+
+func (c *csvBatchWriter) Write(row batchRow, outcome batchOutcome) error {
+	record := slices.Clone(row.fields)
+	if outcome.failure != nil {
+		record = append(record, "", "", "", formatBatchFailure(*outcome.failure))
+	} else {
+		record = append(record, formatBreakdown(outcome.counts), strconv.Itoa(outcome.totalPacks), strconv.Itoa(outcome.overshoot), "")
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvBatchWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonBatchReader reads one JSON object per line.
+type ndjsonBatchReader struct {
+	r          *bufio.Reader
+	itemsField string
+}
+
+func newNDJSONBatchReader(in io.Reader, itemsField string) *ndjsonBatchReader {
+	return &ndjsonBatchReader{r: bufio.NewReader(in), itemsField: itemsField}
+}
+
+func (n *ndjsonBatchReader) Next() (batchRow, error) {
+	for {
+		line, err := n.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return batchRow{}, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err != nil {
+				return batchRow{}, err
+			}
+			continue
+		}
+
+		row := batchRow{raw: line}
+		var object map[string]json.RawMessage
+		if jsonErr := json.Unmarshal(line, &object); jsonErr != nil {
+			row.failure = &api.Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: "unable to parse JSON payload"}
+			return row, nil
+		}
+		row.object = true
+		value, ok := object[n.itemsField]
+		if !ok {
+			row.failure = &api.Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: fmt.Sprintf("field %q is missing", n.itemsField)}
+			return row, nil
+		}
+		var raw string
+		if unquoteErr := json.Unmarshal(value, &raw); unquoteErr != nil {
+			raw = string(value)
+		}
+		row.items, row.failure = parseBatchItems(strings.TrimSpace(raw))
+		return row, nil
+	}
+}
+
+// ndjsonBatchWriter echoes each object with the result fields appended.
+type ndjsonBatchWriter struct {
+	w io.Writer
+}
+
+type ndjsonOutcome struct {
+	// Input echoes lines that are not JSON objects.
+	Input      string         `json:"input,omitempty"`
+	Packs      map[string]int `json:"packs,omitempty"`
+	TotalPacks *int           `json:"totalPacks,omitempty"`
+	Overshoot  *int           `json:"overshoot,omitempty"`
+	Error      *api.Failure   `json:"error,omitempty"`
+}
+
+func (n *ndjsonBatchWriter) Write(row batchRow, outcome batchOutcome) error {
+	result := ndjsonOutcome{Error: outcome.failure}
+	if outcome.failure == nil {
+		result.Packs = make(map[string]int, len(outcome.counts))
+		for size, count := range outcome.counts {
+			result.Packs[strconv.Itoa(size)] = count
+		}
+		result.TotalPacks = &outcome.totalPacks
+		result.Overshoot = &outcome.overshoot
+	}
+
+	if !row.object {
+		result.Input = string(row.raw)
+	}
+	fields, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	line := fields
+	if row.object {
+		if line, err = mergeNDJSONFields(row.raw, fields); err != nil {
+			return err
+		}
+	}
+	line = append(line, '\n')
+	_, err = n.w.Write(line)
+	return err
+}
+
+func (n *ndjsonBatchWriter) Flush() error {
+	return nil
+}
+
+// ndjsonResultFields are the keys the batch writes; input fields with the
+// same names are replaced so each output object has unique keys.
+var ndjsonResultFields = []string{"input", "packs", "totalPacks", "overshoot", "error"}
+
+// mergeNDJSONFields appends the members of the JSON object fields to the
+// input object, keeping the input's field order and dropping the input's
+// members named like result fields.
+func mergeNDJSONFields(input, fields []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		if slices.Contains(ndjsonResultFields, key) {
+			continue
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+		out.WriteByte(',')
+	}
+	if out.Len() > 1 && len(fields) == 2 {
+		out.Truncate(out.Len() - 1)
+	}
+	out.Write(fields[1:])
+	return out.Bytes(), nil
+}
+
+func parseBatchItems(raw string) (int, *api.Failure) {
+	items, err := strconv.Atoi(raw)
+	if err != nil || items <= 0 {
+		failure := api.InvalidItemsFailure()
+		return 0, &failure
+	}
+	return items, nil
+}
+
+// formatBreakdown renders a distribution as "5000x2;2000x1", largest first.
+func formatBreakdown(counts map[int]int) string {
+	sizes := make([]int, 0, len(counts))
+	for size := range counts {
+		sizes = append(sizes, size)
+	}
+	slices.Sort(sizes)
+	slices.Reverse(sizes)
+
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = fmt.Sprintf("%dx%d", size, counts[size])
+	}
+	return strings.Join(parts, ";")
+}
+
+func formatBatchFailure(f api.Failure) string {
+	return fmt.Sprintf("%s: %s", f.Error, f.Details)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+func runBatch(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("TIE_BREAK", "")
+
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"batch"}, args...), strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestBatchCommandCSV(t *testing.T) {
+	input := "order_id,quantity\nA1,750\nA2,12001\nA3,abc\nA4,250\n"
+	code, out, errOut := runBatch(t, input, "--pack-sizes", "250,500,1000", "--workers", "3")
+
+	want := "order_id,quantity,packs,total_packs,overshoot,error\n" +
+		"A1,750,500x1;250x1,2,0,\n" +
+		"A2,12001,,,,Cannot pack exactly: cannot pack items exactly with the provided pack sizes\n" +
+		"A3,abc,,,,Invalid request: items must be a positive integer\n" +
+		"A4,250,250x1,1,0,\n"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if code != exitUnprocessable {
+		t.Fatalf("expected exit code of the first failed row (%d), got %d", exitUnprocessable, code)
+	}
+	if !strings.Contains(errOut, "processed 4 rows, 2 failed") {
+		t.Fatalf("unexpected summary %q", errOut)
+	}
+}
+
+func TestBatchCommandColumnMapping(t *testing.T) {
+	code, out, errOut := runBatch(t, "A1,5,1000\nA2,7,500\n", "--no-header", "--items-column", "3", "--pack-sizes", "250,500")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if out != "A1,5,1000,500x2,2,0,\nA2,7,500,500x1,1,0,\n" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	code, _, errOut = runBatch(t, "order,qty\nA1,5\n", "--items-column", "quantity")
+	if code != exitInvalid || !strings.Contains(errOut, `items column "quantity" not found`) {
+		t.Fatalf("expected missing column to be rejected, got %d (%s)", code, errOut)
+	}
+}
+
+func TestBatchCommandNDJSONFiles(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "orders.ndjson")
+	output := filepath.Join(dir, "result.ndjson")
+	lines := `{"id":"A1","qty":750}` + "\n\n" + `{"id":"A2","qty":"263"}` + "\n" + "not json\n"
+	if err := os.WriteFile(input, []byte(lines), 0o644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+
+	code, _, errOut := runBatch(t, "", "--input", input, "--output", output, "--items-column", "qty", "--pack-sizes", "250,500")
+	if code != exitUnprocessable {
+		t.Fatalf("expected exit %d, got %d (%s)", exitUnprocessable, code, errOut)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	want := `{"id":"A1","qty":750,"packs":{"250":1,"500":1},"totalPacks":2,"overshoot":0}` + "\n" +
+		`{"id":"A2","qty":"263","error":{"error":"Cannot pack exactly","details":"cannot pack items exactly with the provided pack sizes","suggestion":"Consider adding a pack size that divides 263 or adjust the order quantity"}}` + "\n" +
+		`{"input":"not json","error":{"error":"Invalid request","details":"unable to parse JSON payload"}}` + "\n"
+	if string(got) != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestBatchNDJSONReplacesResultFields(t *testing.T) {
+	input := `{"packs":"mine","qty":750, "note":{"a": 1},"error":"old"}` + "\n"
+	code, out, errOut := runBatch(t, input, "--input-format", "ndjson", "--items-column", "qty", "--pack-sizes", "250,500")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	want := `{"qty":750,"note":{"a": 1},"packs":{"250":1,"500":1},"totalPacks":2,"overshoot":0}` + "\n"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestBatchPreservesOrderAcrossWorkers(t *testing.T) {
+	var input strings.Builder
+	var want strings.Builder
+	input.WriteString("quantity\n")
+	want.WriteString("quantity,packs,total_packs,overshoot,error\n")
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&input, "%d\n", i*250)
+		fmt.Fprintf(&want, "%d,250x%d,%d,0,\n", i*250, i, i)
+	}
+
+	code, out, errOut := runBatch(t, input.String(), "--pack-sizes", "250", "--workers", "8")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if out != want.String() {
+		t.Fatalf("output order does not match input order")
+	}
+}
+
+type failingBatchWriter struct {
+	writes int
+}
+
+func (f *failingBatchWriter) Write(batchRow, batchOutcome) error {
+	f.writes++
+	if f.writes == 3 {
+		return errors.New("disk full")
+	}
+	return nil
+}
+
+func (f *failingBatchWriter) Flush() error { return nil }
+
+func TestProcessBatchStopsOnWriteError(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("quantity\n")
+	for range 1000 {
+		input.WriteString("250\n")
+	}
+	reader, err := newCSVBatchReader(strings.NewReader(input.String()), "quantity", true)
+	if err != nil {
+		t.Fatalf("newCSVBatchReader returned error: %v", err)
+	}
+
+	writer := &failingBatchWriter{}
+	_, err = processBatch(context.Background(), reader, writer, calculator.New(), []int{250}, 4)
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("expected write error, got %v", err)
+	}
+	if writer.writes != 3 {
+		t.Fatalf("expected writing to stop after the failure, got %d writes", writer.writes)
+	}
+}
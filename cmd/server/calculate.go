@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/api"
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// calculateCommand solves a single order offline, without the HTTP server.
+type calculateCommand struct {
+	cmd       *kingpin.CmdClause
+	items     *int
+	packSizes *string
+	tieBreak  *string
+	format    *string
+}
+
+func newCalculateCommand(app *kingpin.Application) *calculateCommand {
+	c := &calculateCommand{
+		cmd: app.Command("calculate", "Calculate the packs for an order without starting the server"),
+	}
+	c.items = c.cmd.Flag("items", "Number of items to pack").Required().Int()
+	c.packSizes = c.cmd.Flag("pack-sizes", "Comma-separated pack sizes (defaults to the configured sizes)").String()
+	c.tieBreak = c.cmd.Flag("tie-break", "Tie-break policy (defaults to the configured policy)").String()
+	c.format = c.cmd.Flag("format", "Output format: table, json, or csv").Default(formatTable).Enum(formatTable, formatJSON, formatCSV)
+	return c
+}
+
+func (c *calculateCommand) run(configFile string, stdout, stderr io.Writer) int {
+	if *c.items <= 0 {
+		return reportFailure(stderr, *c.format, api.InvalidItemsFailure())
+	}
+
+	settings, failure, ok := loadCalculationSettings(configFile, *c.packSizes, *c.tieBreak)
+	if !ok {
+		return reportFailure(stderr, *c.format, failure)
+	}
+
+	ctx := calculator.WithTieBreak(context.Background(), settings.tieBreak)
+	packs, err := calculator.CalculateWithContext(ctx, calculator.New(), *c.items, settings.packSizes)
+	if err != nil {
+		return reportFailure(stderr, *c.format, api.CalculationFailure(*c.items, err))
+	}
+
+	result := newCalculationResult(*c.items, settings.packSizes, packs)
+	if err := writeCalculationResult(stdout, *c.format, result); err != nil {
+		fmt.Fprintf(stderr, "error: write output: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// calculationSettings holds the pack sizes and policy used by offline
+// calculations.
+type calculationSettings struct {
+	packSizes []int
+	tieBreak  calculator.TieBreakPolicy
+}
+
+// loadCalculationSettings resolves pack sizes and the tie-break policy from
+// flags, falling back to the configuration file, environment, and defaults.
+// Invalid values are reported with the API's messages.
+func loadCalculationSettings(configFile, rawSizes, rawTieBreak string) (calculationSettings, api.Failure, bool) {
+	cfg, err := config.Load(&config.CLIOverrides{ConfigFile: configFile})
+	if err != nil {
+		return calculationSettings{}, api.Failure{Status: http.StatusBadRequest, Error: "Invalid configuration", Details: err.Error()}, false
+	}
+
+	sizes := cfg.InitialPackSizes
+	if rawSizes != "" {
+		if sizes, err = config.ParsePackSizes(rawSizes); err != nil {
+			return calculationSettings{}, api.PackSizesFailure(err), false
+		}
+	}
+	normalized, err := storage.NormalizePackSizes(sizes)
+	if err != nil {
+		return calculationSettings{}, api.PackSizesFailure(err), false
+	}
+
+	policyName := cfg.TieBreak
+	if rawTieBreak != "" {
+		policyName = rawTieBreak
+	}
+	policy, err := calculator.ParseTieBreakPolicy(policyName)
+	if err != nil {
+		return calculationSettings{}, api.TieBreakFailure(err), false
+	}
+
+	return calculationSettings{packSizes: normalized, tieBreak: policy}, api.Failure{}, true
+}
+
+// reportFailure prints f to w in the requested format and returns the exit
+// code for its HTTP category.
+func reportFailure(w io.Writer, format string, f api.Failure) int {
+	if format == formatJSON {
+		_ = json.NewEncoder(w).Encode(f)
+	} else {
+		fmt.Fprintf(w, "error: %s: %s\n", f.Error, f.Details)
+		if f.Suggestion != "" {
+			fmt.Fprintf(w, "suggestion: %s\n", f.Suggestion)
+		}
+	}
+	return exitCodeForStatus(f.Status)
+}
+
+// calculationResult mirrors the API calculate response for CLI output.
+type calculationResult struct {
+	Items      int            `json:"items"`
+	PackSizes  []int          `json:"packSizes"`
+	Packs      map[string]int `json:"packs"`
+	TotalPacks int            `json:"totalPacks"`
+	TotalItems int            `json:"totalItems"`
+	Remainder  int            `json:"remainder"`
+
+	// counts is keyed by pack size for the table and CSV renderings.
+	counts map[int]int
+}
+
+func newCalculationResult(items int, packSizes []int, counts map[int]int) calculationResult {
+	result := calculationResult{
+		Items:     items,
+		PackSizes: packSizes,
+		Packs:     make(map[string]int, len(counts)),
+		counts:    counts,
+	}
+	for size, count := range counts {
+		result.Packs[strconv.Itoa(size)] = count
+		result.TotalPacks += count
+		result.TotalItems += size * count
+	}
+	result.Remainder = items - result.TotalItems
+	return result
+}
+
+// sizesDescending returns the pack sizes used by the result, largest first.
+func (r calculationResult) sizesDescending() []int {
+	sizes := make([]int, 0, len(r.counts))
+	for size := range r.counts {
+		sizes = append(sizes, size)
+	}
+	slices.Sort(sizes)
+	slices.Reverse(sizes)
+	return sizes
+}
+
+func writeCalculationResult(w io.Writer, format string, result calculationResult) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case formatCSV:
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"pack_size", "count"})
+		for _, size := range result.sizesDescending() {
+			_ = writer.Write([]string{strconv.Itoa(size), strconv.Itoa(result.counts[size])})
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PACK SIZE\tCOUNT")
+		for _, size := range result.sizesDescending() {
+			fmt.Fprintf(tw, "%d\t%d\n", size, result.counts[size])
+		}
+		fmt.Fprintf(tw, "TOTAL\t%d packs, %d items\n", result.TotalPacks, result.TotalItems)
+		return tw.Flush()
+	}
+}
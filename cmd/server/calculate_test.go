@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("TIE_BREAK", "")
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(""), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCalculateCommandFormats(t *testing.T) {
+	code, out, errOut := runCLI(t, "calculate", "--items", "12250", "--pack-sizes", "250,500,1000,2000,5000")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	for _, want := range []string{"PACK SIZE  COUNT", "5000       2", "2000       1", "250        1", "TOTAL      4 packs, 12250 items"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected table to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	code, out, _ = runCLI(t, "calculate", "--items", "750", "--format", "json")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	var result calculationResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("failed to decode JSON output: %v", err)
+	}
+	if result.Packs["250"] != 1 || result.Packs["500"] != 1 || result.TotalPacks != 2 || len(result.PackSizes) != 5 {
+		t.Fatalf("unexpected JSON result: %+v", result)
+	}
+
+	code, out, _ = runCLI(t, "calculate", "--items", "750", "--pack-sizes", "250,500", "--format", "csv")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	if out != "pack_size,count\n500,1\n250,1\n" {
+		t.Fatalf("unexpected CSV output:\n%s", out)
+	}
+}
+
+func TestCalculateCommandTieBreak(t *testing.T) {
+	code, out, _ := runCLI(t, "calculate", "--items", "24", "--pack-sizes", "2,3,4,7,9", "--tie-break", "fewer-sizes", "--format", "csv")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	if out != "pack_size,count\n9,2\n3,2\n" {
+		t.Fatalf("unexpected CSV output:\n%s", out)
+	}
+}
+
+func TestCalculateCommandErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "cannot fulfil",
+			args:     []string{"calculate", "--items", "12001", "--pack-sizes", "250,500,1000"},
+			wantCode: exitUnprocessable,
+			wantErr:  "error: Cannot pack exactly: cannot pack items exactly with the provided pack sizes\nsuggestion: Consider adding a pack size that divides 12001",
+		},
+		{
+			name:     "invalid items",
+			args:     []string{"calculate", "--items", "0"},
+			wantCode: exitInvalid,
+			wantErr:  "error: Invalid request: items must be a positive integer",
+		},
+		{
+			name:     "invalid pack sizes",
+			args:     []string{"calculate", "--items", "10", "--pack-sizes", "1,2,3,4,5,6,7,8,9,10,11"},
+			wantCode: exitInvalid,
+			wantErr:  "error: Invalid pack sizes: pack sizes must contain between 1 and 10 positive integers",
+		},
+		{
+			name:     "unparsable pack sizes",
+			args:     []string{"calculate", "--items", "10", "--pack-sizes", "ten"},
+			wantCode: exitInvalid,
+			wantErr:  "error: Invalid pack sizes",
+		},
+		{
+			name:     "unknown tie-break",
+			args:     []string{"calculate", "--items", "10", "--tie-break", "random"},
+			wantCode: exitInvalid,
+			wantErr:  "suggestion: Use one of: ascending-size-first",
+		},
+		{
+			name:     "missing items",
+			args:     []string{"calculate"},
+			wantCode: exitInvalid,
+			wantErr:  "required flag(s) '--items' not provided",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, _, errOut := runCLI(t, tc.args...)
+			if code != tc.wantCode {
+				t.Fatalf("expected exit %d, got %d (%s)", tc.wantCode, code, errOut)
+			}
+			if !strings.Contains(errOut, tc.wantErr) {
+				t.Fatalf("expected stderr to contain %q, got %q", tc.wantErr, errOut)
+			}
+		})
+	}
+}
+
+func TestCalculateCommandJSONError(t *testing.T) {
+	code, _, errOut := runCLI(t, "calculate", "--items", "263", "--pack-sizes", "250,500", "--format", "json")
+	if code != exitUnprocessable {
+		t.Fatalf("expected exit %d, got %d", exitUnprocessable, code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(errOut), &body); err != nil {
+		t.Fatalf("failed to decode JSON error: %v", err)
+	}
+	if body["error"] != "Cannot pack exactly" || body["suggestion"] == "" {
+		t.Fatalf("unexpected JSON error: %v", body)
+	}
+}
+
+func TestServeIsDefaultCommand(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	code, _, errOut := runCLI(t, "--config", missing, "--port", "9090")
+	if code != exitInvalid || !strings.Contains(errOut, "failed to load configuration") {
+		t.Fatalf("expected legacy flags to reach serve, got exit %d (%s)", code, errOut)
+	}
+}
+
+func TestExitCodeForStatus(t *testing.T) {
+	cases := map[int]int{
+		http.StatusOK:                  exitOK,
+		http.StatusBadRequest:          exitInvalid,
+		http.StatusUnauthorized:        exitUnauthorized,
+		http.StatusForbidden:           exitUnauthorized,
+		http.StatusNotFound:            exitNotFound,
+		http.StatusConflict:            exitConflict,
+		http.StatusUnprocessableEntity: exitUnprocessable,
+		http.StatusTooManyRequests:     exitUnavailable,
+		http.StatusServiceUnavailable:  exitUnavailable,
+		http.StatusInternalServerError: exitFailure,
+	}
+	for status, want := range cases {
+		if got := exitCodeForStatus(status); got != want {
+			t.Fatalf("status %d: expected exit %d, got %d", status, want, got)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/config"
+)
+
+// configCommand inspects the configuration the server would start with.
+type configCommand struct {
+	cmd            *kingpin.CmdClause
+	validate       *kingpin.CmdClause
+	validateFlags  *serverFlags
+	validateFormat *string
+	print          *kingpin.CmdClause
+	printFlags     *serverFlags
+	printFormat    *string
+}
+
+func newConfigCommand(app *kingpin.Application) *configCommand {
+	c := &configCommand{
+		cmd: app.Command("config", "Inspect the server configuration"),
+	}
+	c.validate = c.cmd.Command("validate", "Strictly validate the configuration file and environment, reporting every problem")
+	c.validateFlags = newServerFlags(c.validate)
+	c.validateFormat = c.validate.Flag("format", "Output format: table or json").Default(formatTable).Enum(formatTable, formatJSON)
+	c.print = c.cmd.Command("print", "Print the effective configuration and where each value came from")
+	c.printFlags = newServerFlags(c.print)
+	c.printFormat = c.print.Flag("format", "Output format: table or json").Default(formatTable).Enum(formatTable, formatJSON)
+	return c
+}
+
+// owns reports whether command is one of the config subcommands.
+func (c *configCommand) owns(command string) bool {
+	return strings.HasPrefix(command, c.cmd.FullCommand()+" ")
+}
+
+func (c *configCommand) run(command, configFile string, stdout, stderr io.Writer) int {
+	switch command {
+	case c.validate.FullCommand():
+		return c.runValidate(configFile, stdout, stderr)
+	case c.print.FullCommand():
+		return c.runPrint(configFile, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "error: unknown command %q\n", command)
+		return exitInvalid
+	}
+}
+
+type validationReport struct {
+	Valid    bool            `json:"valid"`
+	Problems []problemReport `json:"problems"`
+}
+
+type problemReport struct {
+	Source  string `json:"source"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// runValidate loads the configuration in strict mode regardless of
+// CONFIG_STRICT. Problems with individual values are reported on stdout;
+// a file that cannot be read or parsed at all is an error on stderr.
+func (c *configCommand) runValidate(configFile string, stdout, stderr io.Writer) int {
+	strict := true
+	overrides := c.validateFlags.overrides(configFile)
+	overrides.Strict = &strict
+	_, err := config.Load(overrides)
+
+	var invalid *config.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+
+	report := validationReport{Valid: err == nil, Problems: []problemReport{}}
+	if invalid != nil {
+		for _, p := range invalid.Problems {
+			report.Problems = append(report.Problems, problemReport{Source: string(p.Source), Key: p.Key, Message: p.Message})
+		}
+	}
+
+	if *c.validateFormat == formatJSON {
+		if err := json.NewEncoder(stdout).Encode(report); err != nil {
+			fmt.Fprintf(stderr, "error: write output: %v\n", err)
+			return exitFailure
+		}
+	} else if report.Valid {
+		fmt.Fprintln(stdout, "configuration is valid")
+	} else {
+		fmt.Fprintf(stdout, "configuration is invalid (%d problems):\n", len(invalid.Problems))
+		for _, p := range invalid.Problems {
+			fmt.Fprintf(stdout, "  %s\n", p)
+		}
+	}
+
+	if !report.Valid {
+		return exitInvalid
+	}
+	return exitOK
+}
+
+type effectiveSettingReport struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+	Flag   string `json:"flag,omitempty"`
+}
+
+// runPrint prints the configuration serve would start with given the same
+// flags, with the source of every value. Secrets are redacted.
+func (c *configCommand) runPrint(configFile string, stdout, stderr io.Writer) int {
+	cfg, err := config.Load(c.printFlags.overrides(configFile))
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+
+	settings := cfg.Effective()
+	if *c.printFormat == formatJSON {
+		report := make([]effectiveSettingReport, len(settings))
+		for i, s := range settings {
+			report[i] = effectiveSettingReport{Key: s.Key, Value: s.Value, Source: string(s.Source), Env: s.Env, Flag: s.Flag}
+		}
+		if err := json.NewEncoder(stdout).Encode(report); err != nil {
+			fmt.Fprintf(stderr, "error: write output: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, orDash(s.Value), describeSource(s))
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "error: write output: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// describeSource names the source along with the variable or flag that set
+// the value, e.g. "env (RATE_LIMIT_RPS)".
+func describeSource(s config.EffectiveSetting) string {
+	switch {
+	case s.Source == config.SourceEnv && s.Env != "":
+		return fmt.Sprintf("%s (%s)", s.Source, s.Env)
+	case s.Source == config.SourceFlag && s.Flag != "":
+		return fmt.Sprintf("%s (%s)", s.Source, s.Flag)
+	default:
+		return string(s.Source)
+	}
+}
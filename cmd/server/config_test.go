@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidateReportsEveryProblem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := "write_timeout: \"15 seconds\"\npack_sizes: [250, 0]\nrate_limit:\n  rps: 10\n  brust: 5\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("CONFIG_STRICT", "false")
+	t.Setenv("RATE_LIMIT_BURST", "lots")
+
+	code, out, errOut := runCLI(t, "--config", path, "config", "validate")
+	if code != exitInvalid {
+		t.Fatalf("expected exit %d, got %d (%s)", exitInvalid, code, errOut)
+	}
+	for _, want := range []string{
+		"configuration is invalid (4 problems)",
+		`yaml write_timeout: invalid duration "15 seconds"`,
+		"yaml rate_limit.brust: unknown key (line 5)",
+		`env RATE_LIMIT_BURST: invalid integer "lots"`,
+		"yaml pack_sizes: pack sizes must contain",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigValidateJSON(t *testing.T) {
+	code, out, errOut := runCLI(t, "config", "validate", "--format", "json")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	var report validationReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if !report.Valid || len(report.Problems) != 0 {
+		t.Fatalf("expected a valid report, got %+v", report)
+	}
+}
+
+func TestConfigValidateMissingFile(t *testing.T) {
+	code, _, errOut := runCLI(t, "--config", filepath.Join(t.TempDir(), "missing.yaml"), "config", "validate")
+	if code != exitInvalid || !strings.Contains(errOut, "read file") {
+		t.Fatalf("expected a read error, got %d (%s)", code, errOut)
+	}
+}
+
+func TestConfigPrintShowsSourcesAndRedactsSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("write_timeout: 20s\nadmin:\n  token: s3cret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("PORT", "")
+	t.Setenv("RATE_LIMIT_BURST", "7")
+
+	code, out, errOut := runCLI(t, "--config", path, "config", "print", "--port", "9000")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Fatalf("expected the token to be redacted, got:\n%s", out)
+	}
+	// Columns are aligned to the longest key, so compare with single spaces.
+	compact := regexp.MustCompile(` {2,}`).ReplaceAllString(out, " ")
+	for _, want := range []string{
+		"port 9000 flag (--port)",
+		"write_timeout 20s yaml",
+		"rate_limit.burst 7 env (RATE_LIMIT_BURST)",
+		"admin.token [redacted] yaml",
+	} {
+		if !strings.Contains(compact, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	code, out, _ = runCLI(t, "--config", path, "config", "print", "--format", "json")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	var settings []effectiveSettingReport
+	if err := json.Unmarshal([]byte(out), &settings); err != nil {
+		t.Fatalf("failed to decode settings: %v", err)
+	}
+	if settings[0].Key != "port" || settings[0].Source != "default" || settings[0].Env != "PORT" {
+		t.Fatalf("unexpected first setting %+v", settings[0])
+	}
+}
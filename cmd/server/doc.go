@@ -1,3 +1,4 @@
-// Package main provides the executable entry point for the Order Packs
-// Calculator HTTP server.
+// Package main provides the pack-calculator executable: the HTTP server
+// (serve, the default subcommand), offline CLI subcommands and shell, a
+// client for a running server (remote), and configuration checks (config).
 package main
@@ -0,0 +1,48 @@
+package main
+
+import "net/http"
+
+// Exit codes shared by the CLI subcommands. They follow the error categories
+// of the HTTP API so scripts can react the same way to both.
+const (
+	exitOK = 0
+	// exitFailure covers internal errors (HTTP 5xx) and unexpected failures.
+	exitFailure = 1
+	// exitInvalid covers invalid flags, input, or configuration (HTTP 400).
+	exitInvalid = 2
+	// exitUnprocessable means the order cannot be packed exactly (HTTP 422).
+	exitUnprocessable = 3
+	// exitUnavailable means the request was throttled, cancelled, or the
+	// service is unavailable (HTTP 429 and 503).
+	exitUnavailable = 4
+	// exitUnauthorized means authentication or authorisation failed (HTTP 401
+	// and 403).
+	exitUnauthorized = 5
+	// exitNotFound means the requested resource does not exist (HTTP 404).
+	exitNotFound = 6
+	// exitConflict means the request conflicts with the current state (HTTP
+	// 409).
+	exitConflict = 7
+)
+
+// exitCodeForStatus maps an HTTP status to the CLI exit code.
+func exitCodeForStatus(status int) int {
+	switch {
+	case status < http.StatusBadRequest:
+		return exitOK
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return exitUnauthorized
+	case status == http.StatusNotFound:
+		return exitNotFound
+	case status == http.StatusConflict:
+		return exitConflict
+	case status == http.StatusUnprocessableEntity:
+		return exitUnprocessable
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return exitUnavailable
+	case status < http.StatusInternalServerError:
+		return exitInvalid
+	default:
+		return exitFailure
+	}
+}
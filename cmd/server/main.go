@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
@@ -19,74 +19,255 @@ import (
 var signalNotify = signal.Notify
 
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses args, executes the selected subcommand, and returns the process
+// exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	kingpinApp := kingpin.New("pack-calculator", "Order Packs Calculator - determines minimal packs needed to fulfil orders")
+	kingpinApp.UsageWriter(stdout)
+	kingpinApp.ErrorWriter(stderr)
 	configFile := kingpinApp.Flag("config", "Path to YAML configuration file").String()
-	port := kingpinApp.Flag("port", "HTTP port exposed by the service").String()
-	packSizesStr := kingpinApp.Flag("pack-sizes", "Comma-separated initial pack sizes").String()
-	rateLimitRPSFlag := kingpinApp.Flag("rate-limit-rps", "Requests per second allowed (set 0 to disable)").Default("-1").Float64()
-	rateLimitBurstFlag := kingpinApp.Flag("rate-limit-burst", "Burst capacity for rate limiter (set 0 to disable)").Default("-1").Int()
 
-	kingpin.MustParse(kingpinApp.Parse(os.Args[1:]))
+	serve := newServeCommand(kingpinApp)
+	calculate := newCalculateCommand(kingpinApp)
+	batch := newBatchCommand(kingpinApp)
+	remote := newRemoteCommand(kingpinApp)
+	repl := newReplCommand(kingpinApp)
+	configCmd := newConfigCommand(kingpinApp)
+
+	command, err := kingpinApp.Parse(args)
+	if err != nil {
+		kingpinApp.Errorf("%s, try --help", err)
+		return exitInvalid
+	}
+
+	switch {
+	case remote.owns(command):
+		return remote.run(command, stdout, stderr)
+	case configCmd.owns(command):
+		return configCmd.run(command, *configFile, stdout, stderr)
+	case command == calculate.cmd.FullCommand():
+		return calculate.run(*configFile, stdout, stderr)
+	case command == batch.cmd.FullCommand():
+		return batch.run(*configFile, stdin, stdout, stderr)
+	case command == repl.cmd.FullCommand():
+		return repl.run(*configFile, stdin, stdout, stderr)
+	default:
+		return serve.run(*configFile, stderr)
+	}
+}
+
+// serveCommand starts the HTTP server. It is the default command, so
+// invocations without a subcommand keep working.
+type serveCommand struct {
+	cmd         *kingpin.CmdClause
+	flags       *serverFlags
+	watchConfig *time.Duration
+}
 
+func newServeCommand(app *kingpin.Application) *serveCommand {
+	s := &serveCommand{
+		cmd: app.Command("serve", "Start the HTTP server (default)").Default(),
+	}
+	s.flags = newServerFlags(s.cmd)
+	s.watchConfig = s.cmd.Flag("watch-config", "Poll the config file at this interval and reload it on change (0 disables; SIGHUP always reloads)").Default("0s").Duration()
+	return s
+}
+
+func (s *serveCommand) overrides(configFile string) *config.CLIOverrides {
+	return s.flags.overrides(configFile)
+}
+
+// serverFlags are the configuration flags of the server. They are shared by
+// serve and the config subcommands, which report what serve would use.
+type serverFlags struct {
+	port           *string
+	packSizes      *string
+	rateLimitRPS   *float64
+	rateLimitBurst *int
+	listen         *[]string
+	logLevel       *string
+	logFormat      *string
+	logOutput      *[]string
+	logSampleFirst *int
+	logSampleEvery *int
+}
+
+func newServerFlags(cmd *kingpin.CmdClause) *serverFlags {
+	return &serverFlags{
+		port:           cmd.Flag("port", "HTTP port exposed by the service").String(),
+		packSizes:      cmd.Flag("pack-sizes", "Comma-separated initial pack sizes").String(),
+		rateLimitRPS:   cmd.Flag("rate-limit-rps", "Requests per second allowed (set 0 to disable)").Default("-1").Float64(),
+		rateLimitBurst: cmd.Flag("rate-limit-burst", "Burst capacity for rate limiter (set 0 to disable)").Default("-1").Int(),
+		listen:         cmd.Flag("listen", "Address to serve the API on: host:port, unix:/path or systemd[:name] (repeatable; replaces --port)").Strings(),
+		logLevel:       cmd.Flag("log-level", "Minimum log level: debug, info, warn or error").String(),
+		logFormat:      cmd.Flag("log-format", "Log format: json or console").String(),
+		logOutput:      cmd.Flag("log-output", "Log destination: stdout, stderr or a file path (repeatable)").Strings(),
+		logSampleFirst: cmd.Flag("log-sampling-initial", "Access log entries written per second before sampling (0 with --log-sampling-thereafter 0 disables sampling)").Default("-1").Int(),
+		logSampleEvery: cmd.Flag("log-sampling-thereafter", "After the initial entries, write every Nth access log entry per second").Default("-1").Int(),
+	}
+}
+
+func (f *serverFlags) overrides(configFile string) *config.CLIOverrides {
 	overrides := &config.CLIOverrides{
-		ConfigFile: *configFile,
+		ConfigFile: configFile,
 	}
 
-	if *port != "" {
-		overrides.Port = port
+	if *f.port != "" {
+		overrides.Port = f.port
 	}
 
-	if *packSizesStr != "" {
-		overrides.PackSizesStr = packSizesStr
+	if *f.packSizes != "" {
+		overrides.PackSizesStr = f.packSizes
 	}
 
-	if *rateLimitRPSFlag >= 0 {
-		overrides.RateLimitRPS = rateLimitRPSFlag
+	if *f.rateLimitRPS >= 0 {
+		overrides.RateLimitRPS = f.rateLimitRPS
 	}
 
-	if *rateLimitBurstFlag >= 0 {
-		overrides.RateLimitBurst = rateLimitBurstFlag
+	if *f.rateLimitBurst >= 0 {
+		overrides.RateLimitBurst = f.rateLimitBurst
 	}
 
-	cfg, err := config.Load(overrides)
+	overrides.Listen = *f.listen
+	overrides.LogLevel = f.logLevel
+	overrides.LogFormat = f.logFormat
+	overrides.LogOutput = *f.logOutput
+	overrides.LogSampleInitial = f.logSampleFirst
+	overrides.LogSampleThereafter = f.logSampleEvery
+
+	return overrides
+}
+
+func (s *serveCommand) run(configFile string, stderr io.Writer) int {
+	cfg, err := config.Load(s.overrides(configFile))
 	if err != nil {
-		panic(fmt.Sprintf("failed to load configuration: %v", err))
+		fmt.Fprintf(stderr, "failed to load configuration: %v\n", err)
+		return exitInvalid
 	}
 
-	logger, err := logging.New()
+	logger, level, err := logging.New(logging.Config{
+		Level:       cfg.LogLevel,
+		Format:      cfg.LogFormat,
+		OutputPaths: cfg.LogOutput,
+	})
 	if err != nil {
-		panic(fmt.Sprintf("failed to initialize logger: %v", err))
+		fmt.Fprintf(stderr, "failed to initialize logger: %v\n", err)
+		return exitFailure
 	}
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	app, err := application.New(cfg, logger)
+	app, err := application.New(cfg, logger, application.WithLogLevel(level))
 	if err != nil {
-		logger.Fatal("failed to initialize application", zap.Error(err))
+		logger.Error("failed to initialize application", zap.Error(err))
+		return exitFailure
 	}
 
-	if err := app.Start(); err != nil {
-		logger.Fatal("failed to start server", zap.Error(err))
+	stopReloading := s.reloadOnChange(app, configFile, logger)
+	defer stopReloading()
+
+	ctx, stop := signalContext()
+	defer stop()
+	if err := app.Run(ctx); err != nil {
+		logger.Error("server stopped with error", zap.Error(err))
+		return exitFailure
+	}
+	logger.Info("server stopped")
+	return exitOK
+}
+
+// reloadOnChange reloads the configuration on SIGHUP and, with
+// --watch-config, whenever the config file's modification time changes. It
+// returns a function that stops watching.
+func (s *serveCommand) reloadOnChange(app *application.App, configFile string, logger *zap.Logger) func() {
+	hangup := make(chan os.Signal, 1)
+	signalNotify(hangup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if *s.watchConfig > 0 && configFile != "" {
+		ticker = time.NewTicker(*s.watchConfig)
+		tick = ticker.C
 	}
 
-	shutdown(app.Server(), cfg.ShutdownGracePeriod, logger)
+	done := make(chan struct{})
+	go func() {
+		modified := modTime(configFile)
+		for {
+			select {
+			case <-done:
+				return
+			case <-hangup:
+				logger.Info("reloading configuration", zap.String("trigger", "SIGHUP"))
+				s.reload(app, configFile, logger)
+			case <-tick:
+				current := modTime(configFile)
+				if current.Equal(modified) {
+					continue
+				}
+				modified = current
+				logger.Info("reloading configuration", zap.String("trigger", "file change"), zap.String("file", configFile))
+				s.reload(app, configFile, logger)
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hangup)
+		if ticker != nil {
+			ticker.Stop()
+		}
+		close(done)
+	}
 }
 
-func shutdown(server *http.Server, timeout time.Duration, logger *zap.Logger) {
-	quit := make(chan os.Signal, 1)
-	signalNotify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+// reload resolves the configuration the same way as startup and applies it.
+// An invalid configuration is logged and the running one is kept.
+func (s *serveCommand) reload(app *application.App, configFile string, logger *zap.Logger) {
+	cfg, err := config.Load(s.overrides(configFile))
+	if err != nil {
+		logger.Error("configuration reload rejected, keeping current configuration", zap.Error(err))
+		return
+	}
+	if _, err := app.Reload(cfg); err != nil {
+		logger.Error("configuration reload failed, keeping current configuration", zap.Error(err))
+	}
+}
 
-	<-quit
-	logger.Info("shutting down server")
+// modTime returns the modification time of path, or the zero time if it
+// cannot be read. A file that is briefly missing while an editor replaces it
+// therefore counts as a change once it reappears.
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM,
+// and a function that stops listening for them.
+func signalContext() (context.Context, context.CancelFunc) {
+	quit := make(chan os.Signal, 1)
+	signalNotify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Warn("graceful shutdown failed", zap.Error(err))
-		if closeErr := server.Close(); closeErr != nil {
-			logger.Error("forced close failed", zap.Error(closeErr))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
+	return ctx, func() {
+		signal.Stop(quit)
+		cancel()
 	}
 }
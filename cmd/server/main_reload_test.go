@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/application"
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"go.uber.org/zap/zaptest"
+)
+
+func writeConfigFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+}
+
+func servedPackSizes(t *testing.T, app *application.App) []int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.Server().Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/pack-sizes", nil))
+	var resp struct {
+		PackSizes []int `json:"packSizes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode pack sizes: %v", err)
+	}
+	return resp.PackSizes
+}
+
+func TestServeReloadsConfigFileOnChange(t *testing.T) {
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("RATE_LIMIT_RPS", "")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "pack_sizes: [250, 500]\nrate_limit:\n  rps: 10\n  burst: 20\n")
+
+	kingpinApp := kingpin.New("test", "")
+	serve := newServeCommand(kingpinApp)
+	if _, err := kingpinApp.Parse([]string{"serve", "--watch-config=10ms"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+	cfg, err := config.Load(serve.overrides(path))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	logger := zaptest.NewLogger(t)
+	app, err := application.New(cfg, logger)
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	stop := serve.reloadOnChange(app, path, logger)
+	defer stop()
+
+	// Invalid files are rejected and the running configuration is kept.
+	future := time.Now().Add(time.Hour)
+	writeConfigFile(t, path, "pack_sizes: [23, 31\n")
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sizes := servedPackSizes(t, app); !slices.Equal(sizes, []int{250, 500}) {
+		t.Fatalf("expected the invalid file to be ignored, got %v", sizes)
+	}
+
+	writeConfigFile(t, path, "pack_sizes: [23, 31, 53]\nrate_limit:\n  rps: 10\n  burst: 20\n")
+	future = future.Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed to touch config: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if sizes := servedPackSizes(t, app); slices.Equal(sizes, []int{23, 31, 53}) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the changed pack sizes to be applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
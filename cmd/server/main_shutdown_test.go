@@ -1,17 +1,14 @@
 package main
 
 import (
-	"net/http"
 	"os"
 	osSignal "os/signal"
 	"syscall"
 	"testing"
 	"time"
-
-	"go.uber.org/zap/zaptest"
 )
 
-func TestShutdownSignals(t *testing.T) {
+func TestSignalContextIsCancelledOnSIGTERM(t *testing.T) {
 	t.Cleanup(func() {
 		signalNotify = osSignal.Notify
 	})
@@ -22,18 +19,12 @@ func TestShutdownSignals(t *testing.T) {
 		}()
 	}
 
-	server := &http.Server{}
-	called := make(chan struct{}, 1)
-	server.RegisterOnShutdown(func() {
-		called <- struct{}{}
-	})
-
-	logger := zaptest.NewLogger(t)
-	shutdown(server, time.Millisecond, logger)
+	ctx, stop := signalContext()
+	defer stop()
 
 	select {
-	case <-called:
+	case <-ctx.Done():
 	case <-time.After(time.Second):
-		t.Fatalf("expected server shutdown callback to execute")
+		t.Fatalf("expected the context to be cancelled on SIGTERM")
 	}
 }
@@ -18,7 +18,7 @@ func TestBuildRootHandler(t *testing.T) {
 		w.WriteHeader(http.StatusNoContent)
 	})
 
-	handler, err := application.BuildRootHandler(apiHandler)
+	handler, err := application.BuildRootHandler(apiHandler, "")
 	if err != nil {
 		t.Fatalf("BuildRootHandler returned error: %v", err)
 	}
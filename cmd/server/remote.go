@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/api"
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultRemoteServer = "http://localhost:8080"
+	remoteServerEnv     = "PACK_CALCULATOR_SERVER"
+	remoteAPIKeyEnv     = "PACK_CALCULATOR_API_KEY"
+)
+
+// remoteCommand groups the client subcommands that talk to a running server.
+type remoteCommand struct {
+	cmd             *kingpin.CmdClause
+	server          *string
+	apiKey          *string
+	credentialsFile *string
+	timeout         *time.Duration
+	format          *string
+
+	getSizes  *kingpin.CmdClause
+	setSizes  *kingpin.CmdClause
+	newSizes  *[]int
+	calculate *kingpin.CmdClause
+	items     *int
+	tieBreak  *string
+	packSizes *string
+	history   *kingpin.CmdClause
+	limit     *int
+	cursor    *string
+}
+
+func newRemoteCommand(app *kingpin.Application) *remoteCommand {
+	r := &remoteCommand{
+		cmd: app.Command("remote", "Talk to a running pack-calculator server"),
+	}
+	r.server = r.cmd.Flag("server", "Server URL (default: $"+remoteServerEnv+", the credentials file, or "+defaultRemoteServer+")").String()
+	r.apiKey = r.cmd.Flag("api-key", "API key (default: $"+remoteAPIKeyEnv+" or the credentials file)").String()
+	r.credentialsFile = r.cmd.Flag("credentials-file", "YAML file with server and api_key").Default(defaultCredentialsFile()).String()
+	r.timeout = r.cmd.Flag("timeout", "Request timeout").Default("30s").Duration()
+	r.format = r.cmd.Flag("format", "Output format: table or json").Default(formatTable).Enum(formatTable, formatJSON)
+
+	r.getSizes = r.cmd.Command("get-sizes", "Show the configured pack sizes")
+
+	r.setSizes = r.cmd.Command("set-sizes", "Replace the pack sizes")
+	r.newSizes = r.setSizes.Arg("sizes", "New pack sizes").Required().Ints()
+
+	r.calculate = r.cmd.Command("calculate", "Calculate the packs for an order")
+	r.items = r.calculate.Flag("items", "Number of items to pack").Required().Int()
+	r.tieBreak = r.calculate.Flag("tie-break", "Tie-break policy (defaults to the server policy)").String()
+	r.packSizes = r.calculate.Flag("pack-sizes", "Comma-separated pack sizes for this calculation only (defaults to the server sizes)").String()
+
+	r.history = r.cmd.Command("history", "List recorded calculations (the admin token is required as --api-key when the server sets one)")
+	r.limit = r.history.Flag("limit", "Maximum number of calculations to show").Default("20").Int()
+	r.cursor = r.history.Flag("cursor", "Continue from the cursor printed by a previous call").String()
+	return r
+}
+
+// owns reports whether command is one of the remote subcommands.
+func (r *remoteCommand) owns(command string) bool {
+	return strings.HasPrefix(command, r.cmd.FullCommand()+" ")
+}
+
+func (r *remoteCommand) run(command string, stdout, stderr io.Writer) int {
+	client, err := r.client()
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *r.timeout)
+	defer cancel()
+
+	switch command {
+	case r.getSizes.FullCommand():
+		err = r.runGetSizes(ctx, client, stdout)
+	case r.setSizes.FullCommand():
+		err = r.runSetSizes(ctx, client, stdout)
+	case r.calculate.FullCommand():
+		err = r.runCalculate(ctx, client, stdout)
+	case r.history.FullCommand():
+		err = r.runHistory(ctx, client, stdout)
+	}
+	return reportRemoteError(stderr, *r.format, err)
+}
+
+func (r *remoteCommand) runGetSizes(ctx context.Context, client *remoteClient, stdout io.Writer) error {
+	var resp struct {
+		PackSizes []int     `json:"packSizes"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+	raw, err := client.do(ctx, http.MethodGet, "/api/pack-sizes", nil, &resp)
+	if err != nil || *r.format == formatJSON {
+		return printRemoteJSON(stdout, raw, err)
+	}
+	return printPackSizes(stdout, resp.PackSizes, resp.UpdatedAt, "")
+}
+
+func (r *remoteCommand) runSetSizes(ctx context.Context, client *remoteClient, stdout io.Writer) error {
+	var resp struct {
+		PackSizes []int     `json:"packSizes"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Message   string    `json:"message"`
+	}
+	body := map[string][]int{"packSizes": *r.newSizes}
+	raw, err := client.do(ctx, http.MethodPut, "/api/pack-sizes", body, &resp)
+	if err != nil || *r.format == formatJSON {
+		return printRemoteJSON(stdout, raw, err)
+	}
+	return printPackSizes(stdout, resp.PackSizes, resp.UpdatedAt, resp.Message)
+}
+
+func (r *remoteCommand) runCalculate(ctx context.Context, client *remoteClient, stdout io.Writer) error {
+	var resp struct {
+		Items     int            `json:"items"`
+		PackSizes []int          `json:"packSizes"`
+		Packs     map[string]int `json:"packs"`
+	}
+	body := map[string]any{"items": *r.items}
+	if *r.tieBreak != "" {
+		body["tieBreak"] = *r.tieBreak
+	}
+	if *r.packSizes != "" {
+		sizes, err := config.ParsePackSizes(*r.packSizes)
+		if err != nil {
+			return &remoteError{failure: api.PackSizesFailure(err)}
+		}
+		body["packSizes"] = sizes
+	}
+	raw, err := client.do(ctx, http.MethodPost, "/api/calculate", body, &resp)
+	if err != nil || *r.format == formatJSON {
+		return printRemoteJSON(stdout, raw, err)
+	}
+
+	counts := make(map[int]int, len(resp.Packs))
+	for size, count := range resp.Packs {
+		value, convErr := strconv.Atoi(size)
+		if convErr != nil {
+			return fmt.Errorf("unexpected pack size %q in response", size)
+		}
+		counts[value] = count
+	}
+	return writeCalculationResult(stdout, formatTable, newCalculationResult(resp.Items, resp.PackSizes, counts))
+}
+
+// historyEntry is one recorded calculation returned by GET /api/calculations.
+type historyEntry struct {
+	RequestID  string         `json:"requestId"`
+	Actor      string         `json:"actor"`
+	Items      int            `json:"items"`
+	Packs      map[string]int `json:"packs"`
+	TotalPacks int            `json:"totalPacks"`
+	Error      string         `json:"error"`
+	DurationMs float64        `json:"durationMs"`
+	Timestamp  time.Time      `json:"timestamp"`
+}
+
+func (r *remoteCommand) runHistory(ctx context.Context, client *remoteClient, stdout io.Writer) error {
+	query := url.Values{"limit": {strconv.Itoa(*r.limit)}}
+	if *r.cursor != "" {
+		query.Set("cursor", *r.cursor)
+	}
+
+	var resp struct {
+		Calculations []historyEntry `json:"calculations"`
+		NextCursor   string         `json:"nextCursor"`
+	}
+	raw, err := client.do(ctx, http.MethodGet, "/api/calculations?"+query.Encode(), nil, &resp)
+	if err != nil || *r.format == formatJSON {
+		return printRemoteJSON(stdout, raw, err)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tREQUEST ID\tACTOR\tITEMS\tRESULT\tDURATION")
+	for _, entry := range resp.Calculations {
+		result := fmt.Sprintf("%d packs", entry.TotalPacks)
+		if entry.Error != "" {
+			result = "error: " + entry.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%.1fms\n",
+			entry.Timestamp.Format(time.RFC3339), orDash(entry.RequestID), orDash(entry.Actor), entry.Items, result, entry.DurationMs)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if resp.NextCursor != "" {
+		fmt.Fprintf(stdout, "\nMore results: --cursor %s\n", resp.NextCursor)
+	}
+	return nil
+}
+
+// client resolves the server and API key from flags, the environment, and
+// the credentials file, in that order.
+func (r *remoteCommand) client() (*remoteClient, error) {
+	creds, err := loadCredentials(*r.credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	server := firstNonEmpty(*r.server, os.Getenv(remoteServerEnv), creds.Server, defaultRemoteServer)
+	base, err := url.Parse(strings.TrimRight(server, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", server)
+	}
+
+	return &remoteClient{
+		base:   base,
+		apiKey: firstNonEmpty(*r.apiKey, os.Getenv(remoteAPIKeyEnv), creds.APIKey),
+		http:   &http.Client{},
+	}, nil
+}
+
+// remoteCredentials is the credentials file format.
+type remoteCredentials struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api_key"`
+}
+
+func defaultCredentialsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pack-calculator", "credentials.yaml")
+}
+
+// loadCredentials reads the credentials file. A missing file is not an error.
+func loadCredentials(path string) (remoteCredentials, error) {
+	var creds remoteCredentials
+	if path == "" {
+		return creds, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return creds, nil
+		}
+		return creds, fmt.Errorf("read credentials file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &creds); err != nil {
+		return creds, fmt.Errorf("parse credentials file %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+// remoteClient is a minimal JSON client for the pack-calculator API.
+type remoteClient struct {
+	base   *url.URL
+	apiKey string
+	http   *http.Client
+}
+
+// remoteError is a non-2xx API response.
+type remoteError struct {
+	failure api.Failure
+}
+
+func (e *remoteError) Error() string {
+	return fmt.Sprintf("%s: %s", e.failure.Error, e.failure.Details)
+}
+
+// do sends a JSON request and decodes a successful response into out. It
+// returns the raw response body for JSON output, and a *remoteError for API
+// errors.
+func (c *remoteClient) do(ctx context.Context, method, path string, body, out any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base.String()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &remoteError{failure: api.Failure{
+			Status:  http.StatusServiceUnavailable,
+			Error:   "Server unavailable",
+			Details: err.Error(),
+		}}
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		failure := api.Failure{Status: resp.StatusCode}
+		if jsonErr := json.Unmarshal(raw, &failure); jsonErr != nil || failure.Error == "" {
+			failure.Error = http.StatusText(resp.StatusCode)
+			failure.Details = strings.TrimSpace(string(raw))
+		}
+		return raw, &remoteError{failure: failure}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return raw, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return raw, nil
+}
+
+// reportRemoteError prints err and returns the exit code for its HTTP
+// category.
+func reportRemoteError(stderr io.Writer, format string, err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var remoteErr *remoteError
+	if errors.As(err, &remoteErr) {
+		return reportFailure(stderr, format, remoteErr.failure)
+	}
+	fmt.Fprintf(stderr, "error: %v\n", err)
+	return exitFailure
+}
+
+// printRemoteJSON re-indents a successful raw response.
+func printRemoteJSON(stdout io.Writer, raw []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if indentErr := json.Indent(&buf, raw, "", "  "); indentErr != nil {
+		_, writeErr := stdout.Write(raw)
+		return writeErr
+	}
+	buf.WriteByte('\n')
+	_, writeErr := buf.WriteTo(stdout)
+	return writeErr
+}
+
+func printPackSizes(stdout io.Writer, sizes []int, updatedAt time.Time, message string) error {
+	if message != "" {
+		fmt.Fprintln(stdout, message)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "PACK SIZES\t%s\n", joinSizes(sizes))
+	fmt.Fprintf(tw, "UPDATED\t%s\n", updatedAt.Format(time.RFC3339))
+	return tw.Flush()
+}
+
+func joinSizes(sizes []int) string {
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = strconv.Itoa(size)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
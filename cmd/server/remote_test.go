@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/api"
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/history"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func newRemoteTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	handler := api.NewHandler(calculator.New(), storage.NewMemoryStorage())
+	server := httptest.NewServer(api.NewRouter(handler, zaptest.NewLogger(t)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func runRemote(t *testing.T, server string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv(remoteServerEnv, "")
+	t.Setenv(remoteAPIKeyEnv, "")
+	base := []string{"remote", "--server", server, "--credentials-file", ""}
+	return runCLI(t, append(base, args...)...)
+}
+
+func TestRemoteGetAndSetSizes(t *testing.T) {
+	server := newRemoteTestServer(t)
+
+	code, out, errOut := runRemote(t, server.URL, "get-sizes")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if !strings.Contains(out, "PACK SIZES  250, 500, 1000, 2000, 5000") {
+		t.Fatalf("unexpected get-sizes output:\n%s", out)
+	}
+
+	code, out, errOut = runRemote(t, server.URL, "set-sizes", "23", "31", "53")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if !strings.Contains(out, "PACK SIZES  23, 31, 53") {
+		t.Fatalf("unexpected set-sizes output:\n%s", out)
+	}
+
+	code, out, _ = runRemote(t, server.URL, "--format", "json", "get-sizes")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	var resp struct {
+		PackSizes []int `json:"packSizes"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to decode JSON output: %v", err)
+	}
+	if len(resp.PackSizes) != 3 || resp.PackSizes[2] != 53 {
+		t.Fatalf("unexpected pack sizes: %v", resp.PackSizes)
+	}
+}
+
+func TestRemoteCalculate(t *testing.T) {
+	server := newRemoteTestServer(t)
+
+	code, out, errOut := runRemote(t, server.URL, "calculate", "--items", "12250")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	for _, want := range []string{"5000       2", "2000       1", "250        1", "TOTAL      4 packs, 12250 items"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected table to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	code, out, errOut = runRemote(t, server.URL, "--format", "json", "calculate", "--items", "263", "--pack-sizes", "23,31,53")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	var resp struct {
+		PackSizes []int          `json:"packSizes"`
+		Packs     map[string]int `json:"packs"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to decode JSON output: %v", err)
+	}
+	if len(resp.PackSizes) != 3 || resp.Packs["31"] != 7 {
+		t.Fatalf("unexpected override result: %+v", resp)
+	}
+
+	code, _, _ = runRemote(t, server.URL, "calculate", "--items", "263", "--pack-sizes", "23,x")
+	if code != exitInvalid {
+		t.Fatalf("expected exit %d for invalid sizes, got %d", exitInvalid, code)
+	}
+}
+
+func TestRemoteHistory(t *testing.T) {
+	handler := api.NewHandler(calculator.New(), storage.NewMemoryStorage(), api.WithHistory(history.NewMemoryStore(10)))
+	server := httptest.NewServer(api.NewRouter(handler, zaptest.NewLogger(t), api.WithCalculationsToken("s3cret")))
+	t.Cleanup(server.Close)
+
+	for _, items := range []string{"12250", "12001", "750"} {
+		if code, _, errOut := runRemote(t, server.URL, "calculate", "--items", items); code != exitOK && code != exitUnprocessable {
+			t.Fatalf("calculate %s: unexpected exit %d (%s)", items, code, errOut)
+		}
+	}
+
+	code, out, errOut := runRemote(t, server.URL, "--api-key", "s3cret", "history", "--limit", "2")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "TIME") {
+		t.Fatalf("expected a header, two rows, and a cursor hint, got:\n%s", out)
+	}
+	if !strings.Contains(lines[1], "750") || !strings.Contains(lines[1], "2 packs") {
+		t.Fatalf("expected the newest calculation first, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "12001") || !strings.Contains(lines[2], "error: Cannot pack exactly") {
+		t.Fatalf("expected the failed calculation second, got %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[4], "More results: --cursor ") {
+		t.Fatalf("expected a cursor hint, got %q", lines[4])
+	}
+
+	cursor := strings.TrimPrefix(lines[4], "More results: --cursor ")
+	code, out, _ = runRemote(t, server.URL, "--api-key", "s3cret", "history", "--cursor", cursor)
+	if code != exitOK || !strings.Contains(out, "12250") || strings.Contains(out, "More results") {
+		t.Fatalf("unexpected second page (exit %d):\n%s", code, out)
+	}
+}
+
+func TestRemoteErrorExitCodes(t *testing.T) {
+	server := newRemoteTestServer(t)
+
+	code, _, errOut := runRemote(t, server.URL, "calculate", "--items", "0")
+	if code != exitInvalid {
+		t.Fatalf("expected exit %d, got %d", exitInvalid, code)
+	}
+	if !strings.Contains(errOut, "error: Invalid request: items must be a positive integer") {
+		t.Fatalf("unexpected error output: %s", errOut)
+	}
+
+	code, _, _ = runRemote(t, server.URL, "calculate", "--items", "12001")
+	if code != exitUnprocessable {
+		t.Fatalf("expected exit %d, got %d", exitUnprocessable, code)
+	}
+
+	code, _, _ = runRemote(t, server.URL, "history")
+	if code != exitNotFound {
+		t.Fatalf("expected exit %d for a server without history, got %d", exitNotFound, code)
+	}
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	code, _, errOut = runRemote(t, unreachable.URL, "get-sizes")
+	if code != exitUnavailable {
+		t.Fatalf("expected exit %d, got %d (%s)", exitUnavailable, code, errOut)
+	}
+}
+
+func TestRemoteCredentials(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if gotAuth != "Bearer from-file" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Unauthorized","details":"invalid API key"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"packSizes":[10],"updatedAt":"2024-01-01T00:00:00Z"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+	content := "server: " + server.URL + "\napi_key: from-file\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write credentials: %v", err)
+	}
+
+	t.Setenv(remoteServerEnv, "")
+	t.Setenv(remoteAPIKeyEnv, "")
+	code, out, errOut := runCLI(t, "remote", "--credentials-file", path, "get-sizes")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	if !strings.Contains(out, "PACK SIZES  10") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	t.Setenv(remoteAPIKeyEnv, "from-env")
+	code, _, errOut = runCLI(t, "remote", "--credentials-file", path, "get-sizes")
+	if code != exitUnauthorized || gotAuth != "Bearer from-env" {
+		t.Fatalf("expected env key to take precedence and fail with %d, got %d (%s, %q)", exitUnauthorized, code, errOut, gotAuth)
+	}
+
+	code, _, _ = runCLI(t, "remote", "--credentials-file", path, "--api-key", "from-file", "get-sizes")
+	if code != exitOK {
+		t.Fatalf("expected flag key to take precedence, got %d", code)
+	}
+}
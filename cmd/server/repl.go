@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+)
+
+const (
+	replPrompt = "packs> "
+	// maxReplRows bounds the quantities a single calc or compare prints.
+	maxReplRows = 1000
+	// maxReplUnreachable bounds the unreachable quantities listed at once.
+	maxReplUnreachable = 200
+	// maxReplLimit bounds the range scanned for unreachable quantities.
+	maxReplLimit = 10_000_000
+)
+
+// replCommand starts an interactive shell for exploring pack-size sets.
+type replCommand struct {
+	cmd       *kingpin.CmdClause
+	packSizes *string
+	tieBreak  *string
+}
+
+func newReplCommand(app *kingpin.Application) *replCommand {
+	r := &replCommand{
+		cmd: app.Command("repl", "Explore pack-size sets in an interactive shell"),
+	}
+	r.packSizes = r.cmd.Flag("pack-sizes", "Comma-separated starting pack sizes (defaults to the configured sizes)").String()
+	r.tieBreak = r.cmd.Flag("tie-break", "Starting tie-break policy (defaults to the configured policy)").String()
+	return r
+}
+
+func (r *replCommand) run(configFile string, stdin io.Reader, stdout, stderr io.Writer) int {
+	settings, failure, ok := loadCalculationSettings(configFile, *r.packSizes, *r.tieBreak)
+	if !ok {
+		return reportFailure(stderr, formatTable, failure)
+	}
+
+	session := newReplSession(settings, stdout)
+	fmt.Fprintln(stdout, `Pack calculator shell. Type "help" for commands.`)
+	if err := session.loop(stdin); err != nil {
+		fmt.Fprintf(stderr, "error: read input: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// replSession is the state of one shell: the current pack sizes, saved sets,
+// and the commands entered so far.
+type replSession struct {
+	calc     calculator.Calculator
+	sizes    []int
+	tieBreak calculator.TieBreakPolicy
+	sets     map[string][]int
+	history  []string
+	out      io.Writer
+}
+
+func newReplSession(settings calculationSettings, out io.Writer) *replSession {
+	return &replSession{
+		calc:     calculator.New(),
+		sizes:    settings.packSizes,
+		tieBreak: settings.tieBreak,
+		sets:     make(map[string][]int),
+		out:      out,
+	}
+}
+
+// errReplExit ends the session.
+var errReplExit = errors.New("exit")
+
+type replHandler struct {
+	usage string
+	help  string
+	run   func(s *replSession, args []string) error
+}
+
+var replHandlers map[string]replHandler
+
+func init() {
+	replHandlers = map[string]replHandler{
+		"sizes":       {"sizes [SIZES...]", "show or replace the current pack sizes", (*replSession).cmdSizes},
+		"calc":        {"calc QTY|FROM-TO...", "calculate the packs for quantities", (*replSession).cmdCalc},
+		"unreachable": {"unreachable [LIMIT]", "list quantities that cannot be packed exactly", (*replSession).cmdUnreachable},
+		"compare":     {"compare SET SET FROM TO [STEP]", "compare two size sets on a range of quantities", (*replSession).cmdCompare},
+		"save":        {"save NAME", "save the current sizes under NAME", (*replSession).cmdSave},
+		"use":         {"use NAME", "make a saved set the current sizes", (*replSession).cmdUse},
+		"sets":        {"sets", "list saved sets", (*replSession).cmdSets},
+		"tie-break":   {"tie-break [POLICY]", "show or change the tie-break policy", (*replSession).cmdTieBreak},
+		"history":     {"history", "list the commands entered in this session", (*replSession).cmdHistory},
+		"help":        {"help", "show this help", (*replSession).cmdHelp},
+		"exit":        {"exit", "leave the shell (also quit or Ctrl-D)", func(*replSession, []string) error { return errReplExit }},
+	}
+	replHandlers["quit"] = replHandlers["exit"]
+}
+
+func (s *replSession) loop(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, replPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+		if err := s.execute(scanner.Text()); errors.Is(err, errReplExit) {
+			return nil
+		}
+	}
+}
+
+// execute runs one input line and prints its result or error.
+func (s *replSession) execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	s.history = append(s.history, strings.Join(fields, " "))
+
+	handler, ok := replHandlers[strings.ToLower(fields[0])]
+	if !ok {
+		fmt.Fprintf(s.out, "error: unknown command %q, try help\n", fields[0])
+		return nil
+	}
+	err := handler.run(s, fields[1:])
+	if err != nil && !errors.Is(err, errReplExit) {
+		fmt.Fprintf(s.out, "error: %v\n", err)
+	}
+	return err
+}
+
+func (s *replSession) cmdSizes(args []string) error {
+	if len(args) > 0 {
+		sizes, err := parseReplSizes(strings.Join(args, ","))
+		if err != nil {
+			return err
+		}
+		s.sizes = sizes
+	}
+	fmt.Fprintf(s.out, "sizes: %s\n", joinSizes(s.sizes))
+	return nil
+}
+
+func (s *replSession) cmdCalc(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: " + replHandlers["calc"].usage)
+	}
+	var quantities []int
+	for _, arg := range args {
+		from, to, err := parseReplRange(arg)
+		if err != nil {
+			return err
+		}
+		for q := from; q <= to; q++ {
+			quantities = append(quantities, q)
+			if len(quantities) > maxReplRows {
+				return fmt.Errorf("at most %d quantities per calc", maxReplRows)
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QTY\tPACKS\tTOTAL")
+	for _, q := range quantities {
+		packs, total, err := s.calculate(s.sizes, q)
+		if err != nil {
+			fmt.Fprintf(tw, "%d\t%s\t-\n", q, describeReplResult("", 0, err))
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", q, packs, total)
+	}
+	return tw.Flush()
+}
+
+func (s *replSession) cmdUnreachable(args []string) error {
+	gcd, bound, err := calculator.UnreachableBound(s.sizes)
+	if err != nil {
+		return err
+	}
+
+	limit := bound
+	if len(args) > 0 {
+		if limit, err = parseReplQuantity(args[0]); err != nil {
+			return err
+		}
+	}
+	if limit > maxReplLimit {
+		return fmt.Errorf("limit must be <= %d", maxReplLimit)
+	}
+
+	missing, err := calculator.Unreachable(context.Background(), s.sizes, limit)
+	if err != nil {
+		return err
+	}
+	if gcd > 1 {
+		fmt.Fprintf(s.out, "only multiples of %d can be packed\n", gcd)
+		missing = slices.DeleteFunc(missing, func(q int) bool { return q%gcd != 0 })
+	}
+
+	listed := missing
+	if len(listed) > maxReplUnreachable {
+		listed = listed[:maxReplUnreachable]
+	}
+	if len(missing) == 0 {
+		fmt.Fprintf(s.out, "no unreachable quantities up to %d\n", limit)
+	} else {
+		fmt.Fprintf(s.out, "%d unreachable up to %d: %s", len(missing), limit, joinSizes(listed))
+		if len(listed) < len(missing) {
+			fmt.Fprintf(s.out, ", ... (%d more)", len(missing)-len(listed))
+		}
+		fmt.Fprintln(s.out)
+	}
+	switch {
+	case limit < bound:
+		fmt.Fprintf(s.out, "the list may continue up to %d\n", bound)
+	case gcd == 1:
+		fmt.Fprintf(s.out, "every quantity above %d can be packed\n", bound)
+	default:
+		fmt.Fprintf(s.out, "every multiple of %d above %d can be packed\n", gcd, bound)
+	}
+	return nil
+}
+
+func (s *replSession) cmdCompare(args []string) error {
+	if len(args) < 4 || len(args) > 5 {
+		return errors.New("usage: " + replHandlers["compare"].usage)
+	}
+	left, err := s.resolveSet(args[0])
+	if err != nil {
+		return err
+	}
+	right, err := s.resolveSet(args[1])
+	if err != nil {
+		return err
+	}
+	from, err := parseReplQuantity(args[2])
+	if err != nil {
+		return err
+	}
+	to, err := parseReplQuantity(args[3])
+	if err != nil {
+		return err
+	}
+	step := 1
+	if len(args) == 5 {
+		if step, err = parseReplQuantity(args[4]); err != nil {
+			return err
+		}
+	}
+	if from > to || step == 0 {
+		return errors.New("range must satisfy FROM <= TO and STEP > 0")
+	}
+	if (to-from)/step+1 > maxReplRows {
+		return fmt.Errorf("at most %d quantities per compare, increase STEP", maxReplRows)
+	}
+
+	var leftBetter, rightBetter, equal int
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "QTY\t%s\t%s\tBETTER\n", args[0], args[1])
+	for q := from; q <= to; q += step {
+		leftPacks, leftTotal, leftErr := s.calculate(left, q)
+		rightPacks, rightTotal, rightErr := s.calculate(right, q)
+
+		better := "="
+		switch {
+		case leftErr != nil && rightErr != nil:
+			better = "-"
+		case rightErr != nil, leftErr == nil && leftTotal < rightTotal:
+			better = args[0]
+			leftBetter++
+		case leftErr != nil, rightTotal < leftTotal:
+			better = args[1]
+			rightBetter++
+		default:
+			equal++
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", q, describeReplResult(leftPacks, leftTotal, leftErr), describeReplResult(rightPacks, rightTotal, rightErr), better)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "%s better: %d, %s better: %d, equal: %d\n", args[0], leftBetter, args[1], rightBetter, equal)
+	return nil
+}
+
+func (s *replSession) cmdSave(args []string) error {
+	if len(args) != 1 || args[0] == "current" {
+		return errors.New("usage: " + replHandlers["save"].usage + ` (NAME cannot be "current")`)
+	}
+	s.sets[args[0]] = slices.Clone(s.sizes)
+	fmt.Fprintf(s.out, "saved %s: %s\n", args[0], joinSizes(s.sizes))
+	return nil
+}
+
+func (s *replSession) cmdUse(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: " + replHandlers["use"].usage)
+	}
+	sizes, ok := s.sets[args[0]]
+	if !ok {
+		return fmt.Errorf("no saved set %q", args[0])
+	}
+	s.sizes = slices.Clone(sizes)
+	fmt.Fprintf(s.out, "sizes: %s\n", joinSizes(s.sizes))
+	return nil
+}
+
+func (s *replSession) cmdSets([]string) error {
+	fmt.Fprintf(s.out, "current: %s\n", joinSizes(s.sizes))
+	for _, name := range slices.Sorted(maps.Keys(s.sets)) {
+		fmt.Fprintf(s.out, "%s: %s\n", name, joinSizes(s.sets[name]))
+	}
+	return nil
+}
+
+func (s *replSession) cmdTieBreak(args []string) error {
+	if len(args) > 0 {
+		policy, err := calculator.ParseTieBreakPolicy(args[0])
+		if err != nil {
+			return err
+		}
+		s.tieBreak = policy
+	}
+	fmt.Fprintf(s.out, "tie-break: %s\n", s.tieBreak)
+	return nil
+}
+
+func (s *replSession) cmdHistory([]string) error {
+	for i, line := range s.history {
+		fmt.Fprintf(s.out, "%4d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (s *replSession) cmdHelp([]string) error {
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(replHandlers)) {
+		if name == "quit" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", replHandlers[name].usage, replHandlers[name].help)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, `SIZES and SET accept comma-separated lists such as 250,500,1000; SET also accepts a saved name or "current".`)
+	return nil
+}
+
+// calculate returns the packs for q as "5000x2 250x1" and the pack count.
+func (s *replSession) calculate(sizes []int, q int) (string, int, error) {
+	ctx := calculator.WithTieBreak(context.Background(), s.tieBreak)
+	packs, err := calculator.CalculateWithContext(ctx, s.calc, q, sizes)
+	if err != nil {
+		return "", 0, err
+	}
+	result := newCalculationResult(q, sizes, packs)
+	parts := make([]string, 0, len(packs))
+	for _, size := range result.sizesDescending() {
+		parts = append(parts, fmt.Sprintf("%dx%d", size, packs[size]))
+	}
+	return strings.Join(parts, " "), result.TotalPacks, nil
+}
+
+// resolveSet returns the sizes for a saved name, "current", or a
+// comma-separated list.
+func (s *replSession) resolveSet(name string) ([]int, error) {
+	if name == "current" {
+		return s.sizes, nil
+	}
+	if sizes, ok := s.sets[name]; ok {
+		return sizes, nil
+	}
+	sizes, err := parseReplSizes(name)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a saved set nor a size list: %w", name, err)
+	}
+	return sizes, nil
+}
+
+func describeReplResult(packs string, total int, err error) string {
+	if errors.Is(err, calculator.ErrCannotFulfill) {
+		return "unreachable"
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s (%d)", packs, total)
+}
+
+func parseReplSizes(raw string) ([]int, error) {
+	sizes, err := config.ParsePackSizes(raw)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NormalizePackSizes(sizes)
+}
+
+// parseReplRange parses "N" or "FROM-TO".
+func parseReplRange(raw string) (int, int, error) {
+	fromRaw, toRaw, isRange := strings.Cut(raw, "-")
+	from, err := parseReplQuantity(fromRaw)
+	if err != nil || !isRange {
+		return from, from, err
+	}
+	to, err := parseReplQuantity(toRaw)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > to {
+		return 0, 0, fmt.Errorf("invalid range %q", raw)
+	}
+	return from, to, nil
+}
+
+func parseReplQuantity(raw string) (int, error) {
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid quantity %q", raw)
+	}
+	return value, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func runRepl(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("TIE_BREAK", "")
+
+	var stdout, stderr strings.Builder
+	code := run(append([]string{"repl"}, args...), strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestReplSizesAndCalc(t *testing.T) {
+	code, out, errOut := runRepl(t, "sizes\nsizes 23 31 53\ncalc 263 101\nsizes 0\nexit\ncalc 1\n")
+	if code != exitOK {
+		t.Fatalf("expected exit 0, got %d (%s)", code, errOut)
+	}
+	for _, want := range []string{
+		"sizes: 250, 500, 1000, 2000, 5000",
+		"sizes: 23, 31, 53",
+		"263  31x7 23x2    9",
+		"101  unreachable  -",
+		"error: pack size must be positive, got 0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "QTY") != 1 {
+		t.Fatalf("expected commands after exit to be ignored, got:\n%s", out)
+	}
+}
+
+func TestReplUnreachable(t *testing.T) {
+	_, out, _ := runRepl(t, "unreachable\nunreachable 1000\n", "--pack-sizes", "6,9,20")
+	for _, want := range []string{
+		"22 unreachable up to 94: 1, 2, 3, 4, 5, 7, 8, 10, 11, 13, 14, 16, 17, 19, 22, 23, 25, 28, 31, 34, 37, 43",
+		"every quantity above 94 can be packed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	_, out, _ = runRepl(t, "unreachable 1500\n", "--pack-sizes", "500,750")
+	for _, want := range []string{
+		"only multiples of 250 can be packed",
+		"1 unreachable up to 1500: 250",
+		"every multiple of 250 above 250 can be packed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestReplCompareAndSets(t *testing.T) {
+	input := strings.Join([]string{
+		"save standard",
+		"sizes 23,31,53",
+		"compare standard current 250 500 250",
+		"use standard",
+		"sets",
+		"use missing",
+		"history",
+	}, "\n")
+	_, out, _ := runRepl(t, input)
+	for _, want := range []string{
+		"saved standard: 250, 500, 1000, 2000, 5000",
+		"250  250x1 (1)  unreachable     standard",
+		"500  500x1 (1)  53x9 23x1 (10)  standard",
+		"standard better: 2, current better: 0, equal: 0",
+		"standard: 250, 500, 1000, 2000, 5000",
+		`error: no saved set "missing"`,
+		"   3  compare standard current 250 500 250",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestReplTieBreak(t *testing.T) {
+	_, out, _ := runRepl(t, "calc 24\ntie-break fewer-sizes\ncalc 24\ntie-break nope\n", "--pack-sizes", "2,3,4,7,9")
+	for _, want := range []string{
+		"24   7x3 3x1  4",
+		"tie-break: fewer-sizes",
+		"24   9x2 3x2  4",
+		"error: unknown tie-break policy",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -4,6 +4,7 @@ go 1.25.1
 
 require (
 	github.com/alecthomas/kingpin/v2 v2.4.0
+	github.com/klauspost/compress v1.18.0
 	go.uber.org/zap v1.27.0
 	golang.org/x/time v0.14.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"github.com/eugenenazirov/re-partners/internal/logging"
+	"go.uber.org/zap"
+)
+
+// AdminOption configures the behaviour of NewAdminRouter.
+type AdminOption func(*adminConfig)
+
+// WithEffectiveConfig enables GET /admin/config, which reports the settings
+// returned by effective. It is called on every request so the response
+// follows configuration reloads.
+func WithEffectiveConfig(effective func() []config.EffectiveSetting) AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.effective = effective
+	}
+}
+
+// WithLogLevel enables GET and PUT /admin/log-level, which report and
+// change level while the server runs.
+func WithLogLevel(level zap.AtomicLevel) AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.logLevel = &level
+	}
+}
+
+type adminConfig struct {
+	effective   func() []config.EffectiveSetting
+	diagnostics *diagnostics
+	logLevel    *zap.AtomicLevel
+}
+
+// NewAdminRouter creates the router for the operator endpoints under
+// /admin/. Every request must present token as a bearer token; an empty
+// token rejects all requests.
+func NewAdminRouter(token string, logger *zap.Logger, opts ...AdminOption) http.Handler {
+	var cfg adminConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	mux := http.NewServeMux()
+	if cfg.effective != nil {
+		mux.Handle("GET /admin/config", handleEffectiveConfig(cfg.effective))
+	}
+	if cfg.diagnostics != nil {
+		cfg.diagnostics.register(mux)
+	}
+	if cfg.logLevel != nil {
+		mux.Handle("GET /admin/log-level", handleGetLogLevel(*cfg.logLevel))
+		mux.Handle("PUT /admin/log-level", handlePutLogLevel(*cfg.logLevel))
+	}
+
+	var root http.Handler = mux
+	root = adminAuthMiddleware(token, root)
+	root = recoveryMiddleware(root)
+	root = requestLoggerMiddleware(logger, mux, root)
+	root = clientIdentityMiddleware(root)
+	root = requestIDMiddleware(root)
+	return root
+}
+
+// adminAuthMiddleware rejects requests without the admin bearer token.
+func adminAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeError(w, http.StatusUnauthorized, "Unauthorized", "a valid admin token is required", "Send Authorization: Bearer <admin token>")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type effectiveSettingResponse struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env,omitempty"`
+	Flag   string `json:"flag,omitempty"`
+}
+
+type effectiveConfigResponse struct {
+	Settings []effectiveSettingResponse `json:"settings"`
+}
+
+func handleEffectiveConfig(effective func() []config.EffectiveSetting) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		settings := effective()
+		resp := effectiveConfigResponse{Settings: make([]effectiveSettingResponse, len(settings))}
+		for i, s := range settings {
+			resp.Settings[i] = effectiveSettingResponse{
+				Key:    s.Key,
+				Value:  s.Value,
+				Source: string(s.Source),
+				Env:    s.Env,
+				Flag:   s.Flag,
+			}
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+func handleGetLogLevel(level zap.AtomicLevel) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, logLevelResponse{Level: level.Level().String()})
+	})
+}
+
+// handlePutLogLevel changes the level until the next change or restart. A
+// configuration reload changes it again only if log.level was edited.
+func handlePutLogLevel(level zap.AtomicLevel) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req logLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request", "unable to parse JSON payload", `Send {"level":"debug"}`)
+			return
+		}
+		parsed, err := logging.ParseLevel(req.Level)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid log level", err.Error())
+			return
+		}
+
+		previous := level.Level()
+		level.SetLevel(parsed)
+		logging.FromContext(r.Context()).Info("log level changed",
+			zap.Stringer("old", previous),
+			zap.Stringer("new", parsed),
+		)
+		writeJSON(w, http.StatusOK, logLevelResponse{Level: parsed.String()})
+	})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestAdminRouter(t *testing.T) http.Handler {
+	t.Helper()
+	cfg := config.Config{
+		Port:       "8080",
+		AdminToken: "s3cret",
+		Sources:    map[string]config.Source{"port": config.SourceFlag, "admin.token": config.SourceEnv},
+	}
+	return NewAdminRouter("s3cret", zaptest.NewLogger(t), WithEffectiveConfig(cfg.Effective))
+}
+
+func TestAdminConfigRequiresToken(t *testing.T) {
+	router := newTestAdminRouter(t)
+
+	for _, header := range []string{"", "Bearer wrong", "s3cret", "Basic s3cret"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("%q: expected 401 with a challenge, got %d", header, rec.Code)
+		}
+	}
+}
+
+func TestAdminConfigReportsProvenanceAndRedactsSecrets(t *testing.T) {
+	router := newTestAdminRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "s3cret") {
+		t.Fatalf("expected the token to be redacted, got %s", rec.Body.String())
+	}
+
+	var resp effectiveConfigResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	settings := make(map[string]effectiveSettingResponse, len(resp.Settings))
+	for _, s := range resp.Settings {
+		settings[s.Key] = s
+	}
+	if port := settings["port"]; port.Value != "8080" || port.Source != "flag" || port.Env != "PORT" || port.Flag != "--port" {
+		t.Fatalf("unexpected port setting %+v", port)
+	}
+	if token := settings["admin.token"]; token.Value != config.Redacted || token.Source != "env" {
+		t.Fatalf("unexpected token setting %+v", token)
+	}
+	if ttl := settings["idempotency_ttl"]; ttl.Value != "0s" || ttl.Source != "default" {
+		t.Fatalf("unexpected idempotency setting %+v", ttl)
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	core, logs := observer.New(zapcore.InfoLevel)
+	router := NewAdminRouter("s3cret", zap.New(core), WithLogLevel(level))
+
+	send := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(http.MethodGet, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"level":"info"`) {
+		t.Fatalf("expected the current level, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodPut, `{"level":"debug"}`); rec.Code != http.StatusOK || level.Level() != zapcore.DebugLevel {
+		t.Fatalf("expected the level to change to debug, got %d (%s)", rec.Code, level.Level())
+	}
+	for _, body := range []string{`{"level":"loud"}`, `level=warn`} {
+		if rec := send(http.MethodPut, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+	if level.Level() != zapcore.DebugLevel {
+		t.Fatalf("expected rejected requests to keep the level, got %s", level.Level())
+	}
+
+	changes := logs.FilterMessage("log level changed").All()
+	if len(changes) != 1 {
+		t.Fatalf("expected one log level change to be logged, got %d", len(changes))
+	}
+	requestIDs := 0
+	for _, field := range changes[0].Context {
+		if field.Key == "request_id" {
+			requestIDs++
+		}
+	}
+	if requestIDs != 1 {
+		t.Fatalf("expected a single request_id field, got %d", requestIDs)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeJSONConditional writes payload with an ETag derived from its
+// encoding and modTime as Last-Modified, or 304 Not Modified when the
+// request's If-None-Match or If-Modified-Since shows the client already
+// has it. Clients are asked to revalidate before reusing a copy.
+func writeJSONConditional(w http.ResponseWriter, r *http.Request, payload any, modTime time.Time) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "no-cache")
+	if !modTime.IsZero() {
+		h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the
+// former is absent, as described in RFC 9110 section 13.2.2. ETags are
+// compared weakly, so validators weakened by response compression match.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// Last-Modified has a resolution of one second.
+	return !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetPackSizesSupportsConditionalRequests(t *testing.T) {
+	router, clock := setupTestRouter(t)
+	get := func(headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/pack-sizes", nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get()
+	etag, lastModified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if first.Code != http.StatusOK || etag == "" || lastModified != clock.Now().Format(http.TimeFormat) {
+		t.Fatalf("expected validators, got %d %v", first.Code, first.Header())
+	}
+
+	for _, headers := range [][]string{
+		{"If-None-Match", etag},
+		{"If-None-Match", `"other", W/` + etag},
+		{"If-Modified-Since", lastModified},
+	} {
+		if rec := get(headers...); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Fatalf("%v: expected 304 without a body, got %d", headers, rec.Code)
+		}
+	}
+	// If-None-Match takes precedence over If-Modified-Since.
+	if rec := get("If-None-Match", `"other"`, "If-Modified-Since", lastModified); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a stale ETag, got %d", rec.Code)
+	}
+
+	clock.Advance(time.Minute)
+	req := httptest.NewRequest(http.MethodPut, "/api/pack-sizes", strings.NewReader(`{"packSizes":[10,20]}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	for _, headers := range [][]string{{"If-None-Match", etag}, {"If-Modified-Since", lastModified}} {
+		if rec := get(headers...); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+			t.Fatalf("%v: expected the updated sizes with a new ETag, got %d", headers, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+// recentGCPauses is the number of most recent GC pauses reported.
+const recentGCPauses = 16
+
+// WithDiagnostics enables the profiling and diagnostics endpoints:
+// net/http/pprof under /admin/debug/pprof/, GET /admin/runtime,
+// GET /admin/build and GET /admin/state, which reports the rate limiter of
+// rt and the result cache of handler. Profiles expose the process's
+// internals, so the admin router should only be served on a private
+// listener when they are enabled.
+func WithDiagnostics(handler *Handler, rt *Runtime) AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.diagnostics = &diagnostics{handler: handler, runtime: rt}
+	}
+}
+
+type diagnostics struct {
+	handler *Handler
+	runtime *Runtime
+}
+
+func (d *diagnostics) register(mux *http.ServeMux) {
+	// The pprof handlers expect the /debug/pprof/ prefix.
+	profiles := http.NewServeMux()
+	profiles.HandleFunc("/debug/pprof/", pprof.Index)
+	profiles.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	profiles.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	profiles.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	profiles.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/admin/debug/pprof/", http.StripPrefix("/admin", withoutWriteDeadline(profiles)))
+
+	mux.Handle("GET /admin/runtime", http.HandlerFunc(handleRuntimeStats))
+	mux.Handle("GET /admin/build", http.HandlerFunc(handleBuildInfo))
+	mux.Handle("GET /admin/state", http.HandlerFunc(d.handleState))
+}
+
+// withoutWriteDeadline lifts the write deadline, since CPU profiles and
+// traces take as long as the caller asks for (?seconds=).
+func withoutWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		next.ServeHTTP(w, r)
+	})
+}
+
+type runtimeStatsResponse struct {
+	Goroutines int               `json:"goroutines"`
+	GOMAXPROCS int               `json:"gomaxprocs"`
+	NumCPU     int               `json:"numCpu"`
+	Heap       heapStatsResponse `json:"heap"`
+	GC         gcStatsResponse   `json:"gc"`
+}
+
+type heapStatsResponse struct {
+	AllocBytes    uint64 `json:"allocBytes"`
+	InuseBytes    uint64 `json:"inuseBytes"`
+	IdleBytes     uint64 `json:"idleBytes"`
+	ReleasedBytes uint64 `json:"releasedBytes"`
+	Objects       uint64 `json:"objects"`
+	SysBytes      uint64 `json:"sysBytes"`
+}
+
+type gcStatsResponse struct {
+	Count        uint32     `json:"count"`
+	LastRunAt    *time.Time `json:"lastRunAt,omitempty"`
+	PauseTotalMs float64    `json:"pauseTotalMs"`
+	// RecentPausesMs lists the most recent pauses, newest first.
+	RecentPausesMs []float64 `json:"recentPausesMs"`
+	NextGCBytes    uint64    `json:"nextGcBytes"`
+}
+
+func handleRuntimeStats(w http.ResponseWriter, _ *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	gc := gcStatsResponse{
+		Count:          mem.NumGC,
+		PauseTotalMs:   durationMs(time.Duration(mem.PauseTotalNs)),
+		RecentPausesMs: []float64{},
+		NextGCBytes:    mem.NextGC,
+	}
+	if mem.LastGC > 0 {
+		last := time.Unix(0, int64(mem.LastGC)).UTC()
+		gc.LastRunAt = &last
+	}
+	// PauseNs is a circular buffer; the most recent pause is at
+	// (NumGC+255)%256.
+	for i := uint32(0); i < min(mem.NumGC, recentGCPauses); i++ {
+		pause := mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))]
+		gc.RecentPausesMs = append(gc.RecentPausesMs, durationMs(time.Duration(pause)))
+	}
+
+	writeJSON(w, http.StatusOK, runtimeStatsResponse{
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Heap: heapStatsResponse{
+			AllocBytes:    mem.HeapAlloc,
+			InuseBytes:    mem.HeapInuse,
+			IdleBytes:     mem.HeapIdle,
+			ReleasedBytes: mem.HeapReleased,
+			Objects:       mem.HeapObjects,
+			SysBytes:      mem.Sys,
+		},
+		GC: gc,
+	})
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type buildInfoResponse struct {
+	GoVersion string            `json:"goVersion"`
+	Path      string            `json:"path,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+// handleBuildInfo reports how the binary was built, including the VCS
+// revision when it was built from a checkout.
+func handleBuildInfo(w http.ResponseWriter, _ *http.Request) {
+	resp := buildInfoResponse{GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		resp.Path = info.Main.Path
+		resp.Version = info.Main.Version
+		resp.Settings = make(map[string]string, len(info.Settings))
+		for _, s := range info.Settings {
+			resp.Settings[s.Key] = s.Value
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type stateResponse struct {
+	RateLimit rateLimitStateResponse `json:"rateLimit"`
+	// Cache is omitted when result caching is disabled.
+	Cache *calculator.CacheStats `json:"cache,omitempty"`
+}
+
+type rateLimitStateResponse struct {
+	Enabled bool    `json:"enabled"`
+	RPS     float64 `json:"rps"`
+	Burst   int     `json:"burst"`
+	// Tokens is the number of requests that may be made right now.
+	Tokens float64 `json:"tokens"`
+}
+
+func (d *diagnostics) handleState(w http.ResponseWriter, _ *http.Request) {
+	var resp stateResponse
+	if d.runtime != nil {
+		resp.RateLimit = d.runtime.rateLimitState()
+	}
+	if d.handler != nil {
+		if cache, ok := d.handler.calculator.(cachingCalculator); ok {
+			stats := cache.Stats()
+			resp.Cache = &stats
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
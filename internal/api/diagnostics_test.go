@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func newDiagnosticsRouter(t *testing.T) (http.Handler, *Runtime) {
+	t.Helper()
+	calc := calculator.NewCache(calculator.New(), calculator.CacheConfig{MaxEntries: 10})
+	handler := NewHandler(calc, storage.NewMemoryStorage())
+	rt := NewRuntime(RuntimeSettings{RateLimitRPS: 5, RateLimitBurst: 10})
+	return NewAdminRouter("s3cret", zaptest.NewLogger(t), WithDiagnostics(handler, rt)), rt
+}
+
+func adminGet(t *testing.T, router http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDiagnosticsRequireToken(t *testing.T) {
+	router, _ := newDiagnosticsRouter(t)
+	for _, path := range []string{"/admin/debug/pprof/", "/admin/runtime", "/admin/build", "/admin/state"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestDiagnosticsServesProfiles(t *testing.T) {
+	router, _ := newDiagnosticsRouter(t)
+
+	rec := adminGet(t, router, "/admin/debug/pprof/")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Fatalf("expected the profile index, got %d", rec.Code)
+	}
+	rec = adminGet(t, router, "/admin/debug/pprof/goroutine?debug=1")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile") {
+		t.Fatalf("expected a goroutine profile, got %d", rec.Code)
+	}
+}
+
+func TestDiagnosticsReportRuntimeAndBuild(t *testing.T) {
+	router, _ := newDiagnosticsRouter(t)
+
+	var stats runtimeStatsResponse
+	rec := adminGet(t, router, "/admin/runtime")
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode runtime stats: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.Heap.AllocBytes == 0 || stats.GOMAXPROCS == 0 {
+		t.Fatalf("unexpected runtime stats %+v", stats)
+	}
+	if len(stats.GC.RecentPausesMs) > recentGCPauses || uint32(len(stats.GC.RecentPausesMs)) > stats.GC.Count {
+		t.Fatalf("unexpected GC pauses %+v", stats.GC)
+	}
+
+	var build buildInfoResponse
+	rec = adminGet(t, router, "/admin/build")
+	if err := json.NewDecoder(rec.Body).Decode(&build); err != nil {
+		t.Fatalf("failed to decode build info: %v", err)
+	}
+	if !strings.HasPrefix(build.GoVersion, "go") {
+		t.Fatalf("unexpected build info %+v", build)
+	}
+}
+
+func TestDiagnosticsReportRateLimiterAndCache(t *testing.T) {
+	router, rt := newDiagnosticsRouter(t)
+	rt.state.Load().limiter.Allow()
+
+	var state stateResponse
+	if err := json.NewDecoder(adminGet(t, router, "/admin/state").Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	limit := state.RateLimit
+	if !limit.Enabled || limit.RPS != 5 || limit.Burst != 10 || limit.Tokens >= 10 {
+		t.Fatalf("unexpected rate limit state %+v", limit)
+	}
+	if state.Cache == nil || state.Cache.Entries != 0 {
+		t.Fatalf("expected empty cache stats, got %+v", state.Cache)
+	}
+
+	rt.Apply(RuntimeSettings{})
+	state = stateResponse{}
+	if err := json.NewDecoder(adminGet(t, router, "/admin/state").Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode state: %v", err)
+	}
+	if state.RateLimit.Enabled {
+		t.Fatalf("expected the disabled rate limiter to be reported, got %+v", state.RateLimit)
+	}
+}
+
+func TestAdminRouterWithoutDiagnostics(t *testing.T) {
+	router := NewAdminRouter("s3cret", zaptest.NewLogger(t))
+	if rec := adminGet(t, router, "/admin/debug/pprof/"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected profiles to be opt-in, got %d", rec.Code)
+	}
+}
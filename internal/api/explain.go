@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+)
+
+// explanationResponse is the calculator explanation annotated with the
+// version of the pack sizes it was computed against.
+type explanationResponse struct {
+	calculator.Explanation
+	PackSizeVersion uint64 `json:"packSizeVersion"`
+}
+
+// packSizeSnapshot returns the pack sizes and their version in one read when
+// the storage supports it. The version is zero otherwise.
+func (h *Handler) packSizeSnapshot() (storage.Snapshot, error) {
+	if versioned, ok := h.storage.(storage.Versioned); ok {
+		return versioned.Snapshot(), nil
+	}
+	sizes, err := h.storage.GetPackSizes()
+	if err != nil {
+		return storage.Snapshot{}, err
+	}
+	return storage.Snapshot{PackSizes: sizes}, nil
+}
+
+// requestPackSizes returns the sizes a calculate request runs against: its
+// own packSizes when present, validated with the storage rules, or the stored
+// snapshot otherwise. Request sizes carry version zero and are never stored.
+func (h *Handler) requestPackSizes(override []int) (storage.Snapshot, Failure, bool) {
+	if override != nil {
+		sizes, err := storage.NormalizePackSizes(override)
+		if err != nil {
+			return storage.Snapshot{}, PackSizesFailure(err), false
+		}
+		return storage.Snapshot{PackSizes: sizes}, Failure{}, true
+	}
+
+	snapshot, err := h.packSizeSnapshot()
+	if err != nil {
+		return storage.Snapshot{}, Failure{Status: http.StatusInternalServerError, Error: "Internal error", Details: err.Error()}, false
+	}
+	return snapshot, Failure{}, true
+}
+
+// writeExplanation answers a calculate request that asked for an explanation,
+// as JSON or as plain text when the client prefers it.
+func (h *Handler) writeExplanation(w http.ResponseWriter, r *http.Request, audit *calculationAudit, items int, snapshot storage.Snapshot) {
+	start := time.Now()
+	exp, err := calculator.Explain(r.Context(), h.calculator, items, snapshot.PackSizes)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		if errors.Is(err, calculator.ErrExplainUnsupported) {
+			audit.fail(w, Failure{Status: http.StatusNotImplemented, Error: "Explain unavailable", Details: err.Error()})
+			return
+		}
+		audit.fail(w, CalculationFailure(items, err))
+		return
+	}
+	audit.succeed(exp.Packs)
+
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Pack-size version: %d\n", snapshot.Version)
+		_, _ = w.Write([]byte(exp.Text()))
+		return
+	}
+
+	resp := newCalculateResponse(items, snapshot.PackSizes, exp.Packs, elapsed)
+	resp.Explanation = &explanationResponse{Explanation: exp, PackSizeVersion: snapshot.Version}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// wantsText reports whether the client asked for the text rendering, either
+// with ?format=text or an Accept header preferring text/plain.
+func wantsText(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "text")
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/plain":
+			return true
+		case "application/json":
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func postExplain(t *testing.T, router http.Handler, target, accept string, items int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{"items": items, "explain": true})
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCalculateExplainReturnsTrace(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	put := httptest.NewRequest(http.MethodPut, "/api/pack-sizes", strings.NewReader(`{"packSizes":[23,31,53]}`))
+	put.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), put)
+
+	rec := postExplain(t, router, "/api/calculate", "", 500000)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body calculateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Packs["53"] != 9429 || body.Packs["31"] != 7 || body.Packs["23"] != 2 {
+		t.Fatalf("unexpected packs %v", body.Packs)
+	}
+	exp := body.Explanation
+	if exp == nil {
+		t.Fatalf("expected an explanation in the response")
+	}
+	if exp.PackSizeVersion != 2 {
+		t.Fatalf("expected pack-size version 2, got %d", exp.PackSizeVersion)
+	}
+	if exp.Objective != body.TotalPacks || len(exp.Path) == 0 || len(exp.Predecessors) != 3 {
+		t.Fatalf("unexpected explanation %+v", exp.Explanation)
+	}
+}
+
+func TestCalculateExplainTextRendering(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	for name, tc := range map[string]struct{ target, accept string }{
+		"query":  {target: "/api/calculate?format=text"},
+		"accept": {target: "/api/calculate", accept: "text/plain"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := postExplain(t, router, tc.target, tc.accept, 750)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+				t.Fatalf("expected text/plain, got %q", ct)
+			}
+			text := rec.Body.String()
+			if !strings.HasPrefix(text, "Pack-size version: 1\n") || !strings.Contains(text, "Result: 2 packs (1 x 500, 1 x 250)") {
+				t.Fatalf("unexpected text rendering:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestCalculateExplainErrors(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := postExplain(t, router, "/api/calculate", "", 263)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", rec.Code)
+	}
+	rec = postExplain(t, router, "/api/calculate", "", 2_000_000_000)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "Order too large") {
+		t.Fatalf("expected an oversized explanation to be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	handler := NewHandler(plainCalculator{}, storage.NewMemoryStorage())
+	router = NewRouter(handler, zaptest.NewLogger(t), WithLogging(false))
+	rec = postExplain(t, router, "/api/calculate", "", 750)
+	if rec.Code != http.StatusNotImplemented {
+		t.Fatalf("expected status 501, got %d", rec.Code)
+	}
+}
+
+// plainCalculator does not implement calculator.Explainer.
+type plainCalculator struct{}
+
+func (plainCalculator) CalculatePacks(items int, packSizes []int) (map[int]int, error) {
+	return calculator.New().CalculatePacks(items, packSizes)
+}
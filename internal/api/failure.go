@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+// Failure describes an error the way the HTTP API reports it, so other front
+// ends such as the CLI can reuse the same status categories and messages.
+type Failure struct {
+	Status     int    `json:"-"`
+	Error      string `json:"error"`
+	Details    string `json:"details,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// InvalidItemsFailure is reported when items is not a positive integer.
+func InvalidItemsFailure() Failure {
+	return Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: "items must be a positive integer"}
+}
+
+// PackSizesFailure is reported when pack sizes fail validation.
+func PackSizesFailure(err error) Failure {
+	return Failure{Status: http.StatusBadRequest, Error: "Invalid pack sizes", Details: err.Error()}
+}
+
+// CalculationFailure maps calculator failures to an HTTP status and message.
+func CalculationFailure(items int, err error) Failure {
+	switch {
+	case errors.Is(err, calculator.ErrInvalidItems):
+		return Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: err.Error()}
+	case errors.Is(err, calculator.ErrCannotFulfill):
+		return Failure{
+			Status:     http.StatusUnprocessableEntity,
+			Error:      "Cannot pack exactly",
+			Details:    err.Error(),
+			Suggestion: fmt.Sprintf("Consider adding a pack size that divides %d or adjust the order quantity", items),
+		}
+	case errors.Is(err, calculator.ErrOrderTooLarge):
+		return Failure{
+			Status:     http.StatusUnprocessableEntity,
+			Error:      "Order too large",
+			Details:    err.Error(),
+			Suggestion: "Reduce the order quantity, or request it without explain or tieBreak",
+		}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return Failure{Status: http.StatusServiceUnavailable, Error: "Calculation cancelled", Details: err.Error()}
+	default:
+		return Failure{Status: http.StatusInternalServerError, Error: "Internal error", Details: err.Error()}
+	}
+}
+
+func (f Failure) response() errorResponse {
+	return errorResponse{Error: f.Error, Details: f.Details, Suggestion: f.Suggestion}
+}
+
+func writeFailure(w http.ResponseWriter, f Failure) {
+	writeJSON(w, f.Status, f.response())
+}
+
+// TieBreakFailure is reported for an unknown tie-break policy.
+func TieBreakFailure(err error) Failure {
+	return Failure{
+		Status:     http.StatusBadRequest,
+		Error:      "Invalid request",
+		Details:    err.Error(),
+		Suggestion: fmt.Sprintf("Use one of: %s", tieBreakNames()),
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/history"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+	"github.com/eugenenazirov/re-partners/internal/logging"
 	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap"
 )
 
 type contextKey string
@@ -23,11 +28,29 @@ const requestIDContextKey contextKey = "requestID"
 type Handler struct {
 	calculator calculator.Calculator
 	storage    storage.Storage
+	jobs       *jobs.Manager
+	history    history.Store
+	// calculatorOptions configure the private calculators of simulations.
+	calculatorOptions []calculator.Option
+
+	// jobAudits holds the audits of submitted jobs until they finish.
+	jobAuditsMu sync.Mutex
+	jobAudits   map[string]*calculationAudit
 
 	clock func() time.Time
 
 	mu                 sync.RWMutex
 	packSizesUpdatedAt time.Time
+
+	// draining switches readiness off while the server shuts down.
+	draining atomic.Bool
+}
+
+// cachingCalculator is implemented by calculators that can report whether a
+// result was served from a cache.
+type cachingCalculator interface {
+	CalculatePacksCached(ctx context.Context, items int, packSizes []int) (map[int]int, bool, error)
+	Stats() calculator.CacheStats
 }
 
 // HandlerOption configures Handler behaviour.
@@ -53,6 +76,15 @@ func NewHandler(calc calculator.Calculator, store storage.Storage, opts ...Handl
 		opt(h)
 	}
 	h.packSizesUpdatedAt = h.clock()
+	// Pack sizes also change outside the API, for example when the
+	// configuration is reloaded.
+	if observable, ok := store.(storage.Observable); ok {
+		observable.Subscribe(func(storage.Snapshot) { h.markPackSizesUpdated() })
+	}
+	if h.jobs != nil && h.history != nil {
+		h.jobAudits = make(map[string]*calculationAudit)
+		h.jobs.Subscribe(h.recordJob)
+	}
 	return h
 }
 
@@ -65,11 +97,25 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// Drain marks the server as shutting down: GET /api/ready answers 503 from
+// then on, so that load balancers stop sending new requests. Other endpoints
+// keep working.
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+func (h *Handler) handleReady(w http.ResponseWriter, _ *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, healthResponse{Status: "draining", Timestamp: h.clock()})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ready", Timestamp: h.clock()})
+}
+
 func (h *Handler) handleGetPackSizes(w http.ResponseWriter, r *http.Request) {
-	_ = r
 	sizes, err := h.storage.GetPackSizes()
 	if err != nil {
-		writeInternalError(w, err)
+		writeInternalError(w, r, err)
 		return
 	}
 
@@ -77,7 +123,7 @@ func (h *Handler) handleGetPackSizes(w http.ResponseWriter, r *http.Request) {
 		PackSizes: sizes,
 		UpdatedAt: h.currentPackSizesUpdatedAt(),
 	}
-	writeJSON(w, http.StatusOK, resp)
+	writeJSONConditional(w, r, resp, resp.UpdatedAt)
 }
 
 func (h *Handler) handlePutPackSizes(w http.ResponseWriter, r *http.Request) {
@@ -92,20 +138,22 @@ func (h *Handler) handlePutPackSizes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.storage.SetPackSizes(req.PackSizes); err != nil {
+	if err := storage.SetPackSizesWithContext(r.Context(), h.storage, req.PackSizes); err != nil {
 		if errors.Is(err, storage.ErrInvalidPackSizes) {
-			writeError(w, http.StatusBadRequest, "Invalid pack sizes", err.Error())
+			writeFailure(w, PackSizesFailure(err))
 			return
 		}
-		writeInternalError(w, err)
+		writeInternalError(w, r, err)
 		return
 	}
 
-	h.markPackSizesUpdated()
+	if _, ok := h.storage.(storage.Observable); !ok {
+		h.markPackSizesUpdated()
+	}
 
 	sizes, err := h.storage.GetPackSizes()
 	if err != nil {
-		writeInternalError(w, err)
+		writeInternalError(w, r, err)
 		return
 	}
 
@@ -118,42 +166,105 @@ func (h *Handler) handlePutPackSizes(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) handleCalculate(w http.ResponseWriter, r *http.Request) {
+	audit := h.beginAudit(r)
+	defer audit.finish()
+
 	var req calculateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request", "unable to parse JSON payload")
+		audit.fail(w, Failure{Status: http.StatusBadRequest, Error: "Invalid request", Details: "unable to parse JSON payload"})
 		return
 	}
+	audit.record.Items = req.Items
 
 	if req.Items <= 0 {
-		writeError(w, http.StatusBadRequest, "Invalid request", "items must be a positive integer")
+		audit.fail(w, InvalidItemsFailure())
 		return
 	}
 
-	packSizes, err := h.storage.GetPackSizes()
-	if err != nil {
-		writeInternalError(w, err)
+	ctx := r.Context()
+	if req.TieBreak != "" {
+		policy, err := calculator.ParseTieBreakPolicy(req.TieBreak)
+		if err != nil {
+			audit.fail(w, TieBreakFailure(err))
+			return
+		}
+		ctx = calculator.WithTieBreak(ctx, policy)
+	}
+
+	snapshot, failure, ok := h.requestPackSizes(req.PackSizes)
+	if !ok {
+		audit.fail(w, failure)
+		return
+	}
+	audit.record.PackSizes = snapshot.PackSizes
+	audit.record.PackSizeVersion = snapshot.Version
+
+	if req.Explain {
+		h.writeExplanation(w, r.WithContext(ctx), audit, req.Items, snapshot)
 		return
 	}
 
 	start := time.Now()
-	result, calcErr := h.calculator.CalculatePacks(req.Items, packSizes)
+	result, cached, calcErr := h.calculate(ctx, req.Items, snapshot.PackSizes)
 	elapsed := time.Since(start)
 
+	if h.cachingEnabled() {
+		w.Header().Set("X-Cache", cacheHeaderValue(cached))
+	}
+
 	if calcErr != nil {
-		switch {
-		case errors.Is(calcErr, calculator.ErrInvalidItems):
-			writeError(w, http.StatusBadRequest, "Invalid request", calcErr.Error())
-		case errors.Is(calcErr, calculator.ErrCannotFulfill):
-			suggestion := fmt.Sprintf("Consider adding a pack size that divides %d or adjust the order quantity", req.Items)
-			writeError(w, http.StatusUnprocessableEntity, "Cannot pack exactly", calcErr.Error(), suggestion)
-		case errors.Is(calcErr, calculator.ErrInvalidPackSizes):
-			writeError(w, http.StatusInternalServerError, "Internal error", calcErr.Error())
-		default:
-			writeInternalError(w, calcErr)
-		}
+		audit.fail(w, CalculationFailure(req.Items, calcErr))
 		return
 	}
 
+	audit.succeed(result)
+	resp := newCalculateResponse(req.Items, snapshot.PackSizes, result, elapsed)
+	resp.Cached = cached
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	_ = r
+	cache, ok := h.calculator.(cachingCalculator)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found", "result caching is disabled")
+		return
+	}
+	writeJSON(w, http.StatusOK, cache.Stats())
+}
+
+// calculate runs the calculator and reports whether the result came from a cache.
+func (h *Handler) calculate(ctx context.Context, items int, packSizes []int) (map[int]int, bool, error) {
+	if cache, ok := h.calculator.(cachingCalculator); ok {
+		return cache.CalculatePacksCached(ctx, items, packSizes)
+	}
+	result, err := calculator.CalculateWithContext(ctx, h.calculator, items, packSizes)
+	return result, false, err
+}
+
+func (h *Handler) cachingEnabled() bool {
+	_, ok := h.calculator.(cachingCalculator)
+	return ok
+}
+
+func tieBreakNames() string {
+	policies := calculator.TieBreakPolicies()
+	names := make([]string, len(policies))
+	for i, policy := range policies {
+		names[i] = string(policy)
+	}
+	return strings.Join(names, ", ")
+}
+
+func cacheHeaderValue(hit bool) string {
+	if hit {
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// newCalculateResponse converts a calculator distribution into the API response shape.
+func newCalculateResponse(items int, packSizes []int, result map[int]int, elapsed time.Duration) calculateResponse {
 	packs := make(map[string]int, len(result))
 	sizes := make([]int, 0, len(result))
 	for size := range result {
@@ -170,15 +281,25 @@ func (h *Handler) handleCalculate(w http.ResponseWriter, r *http.Request) {
 		totalPacks += count
 	}
 
-	resp := calculateResponse{
-		Items:             req.Items,
+	return calculateResponse{
+		Items:             items,
+		PackSizes:         packSizes,
 		Packs:             packs,
 		TotalPacks:        totalPacks,
 		TotalItems:        totalItems,
-		Remainder:         req.Items - totalItems,
+		Remainder:         items - totalItems,
 		CalculationTimeMs: elapsed.Milliseconds(),
 	}
-	writeJSON(w, http.StatusOK, resp)
+}
+
+// calculationError maps calculator failures to an HTTP status and error body.
+func calculationError(items int, err error) (int, errorResponse) {
+	f := CalculationFailure(items, err)
+	return f.Status, f.response()
+}
+
+func writeCalculationError(w http.ResponseWriter, items int, err error) {
+	writeFailure(w, CalculationFailure(items, err))
 }
 
 func (h *Handler) currentPackSizesUpdatedAt() time.Time {
@@ -207,16 +328,24 @@ type packSizesRequest struct {
 }
 
 type calculateRequest struct {
-	Items int `json:"items"`
+	Items    int    `json:"items"`
+	Explain  bool   `json:"explain,omitempty"`
+	TieBreak string `json:"tieBreak,omitempty"`
+	// PackSizes overrides the stored sizes for this request only.
+	PackSizes []int `json:"packSizes,omitempty"`
 }
 
 type calculateResponse struct {
 	Items             int            `json:"items"`
+	PackSizes         []int          `json:"packSizes"`
 	Packs             map[string]int `json:"packs"`
 	TotalPacks        int            `json:"totalPacks"`
 	TotalItems        int            `json:"totalItems"`
 	Remainder         int            `json:"remainder"`
 	CalculationTimeMs int64          `json:"calculationTimeMs"`
+	Cached            bool           `json:"cached"`
+	// Explanation is only present when the request set explain.
+	Explanation *explanationResponse `json:"explanation,omitempty"`
 }
 
 type packSizesResponse struct {
@@ -255,6 +384,9 @@ func writeError(w http.ResponseWriter, status int, message, details string, sugg
 	writeJSON(w, status, resp)
 }
 
-func writeInternalError(w http.ResponseWriter, err error) {
+// writeInternalError logs err with the request's logger and reports it as a
+// 500 response.
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("request failed", zap.Error(err))
 	writeError(w, http.StatusInternalServerError, "Internal error", err.Error())
 }
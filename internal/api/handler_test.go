@@ -7,11 +7,14 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/cors"
 	"github.com/eugenenazirov/re-partners/internal/storage"
 	"go.uber.org/zap/zaptest"
 )
@@ -57,7 +60,7 @@ func TestRequestIDHelpers(t *testing.T) {
 		t.Fatalf("expected abc, got %s", got)
 	}
 	resp := httptest.NewRecorder()
-	writeInternalError(resp, assertError("boom"))
+	writeInternalError(resp, httptest.NewRequest(http.MethodGet, "/", nil), assertError("boom"))
 	if resp.Code != http.StatusInternalServerError {
 		t.Fatalf("expected 500 status, got %d", resp.Code)
 	}
@@ -94,6 +97,24 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestReadyEndpointReportsDraining(t *testing.T) {
+	handler := NewHandler(calculator.New(), storage.NewMemoryStorage())
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false))
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+		return rec.Code
+	}
+
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("expected ready, got %d", code)
+	}
+	handler.Drain()
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", code)
+	}
+}
+
 func TestGetPackSizesReturnsDefaults(t *testing.T) {
 	router, clock := setupTestRouter(t)
 
@@ -177,6 +198,36 @@ func TestPutPackSizesUpdatesStorage(t *testing.T) {
 	}
 }
 
+func TestPackSizesUpdatedAtFollowsStorage(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	clock := newControllableClock(time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC))
+	router := NewRouter(NewHandler(calculator.New(), store, WithClock(clock.Now)), zaptest.NewLogger(t), WithLogging(false))
+	since := clock.Now().Format(http.TimeFormat)
+
+	// A configuration reload replaces the sizes without going through the API.
+	clock.Advance(time.Hour)
+	if err := store.SetPackSizes([]int{23, 31, 53}); err != nil {
+		t.Fatalf("SetPackSizes returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pack-sizes", nil)
+	req.Header.Set("If-Modified-Since", since)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the changed sizes, got %d", rec.Code)
+	}
+	var body struct {
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !body.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("expected updatedAt %s, got %s", clock.Now(), body.UpdatedAt)
+	}
+}
+
 func TestPutPackSizesValidatesInput(t *testing.T) {
 	router, _ := setupTestRouter(t)
 
@@ -397,7 +448,11 @@ func TestCalculateEndpointEdgeCase(t *testing.T) {
 }
 
 func TestCorsPreflight(t *testing.T) {
-	router, _ := setupTestRouter(t)
+	policy, err := cors.New(cors.Config{AllowedOrigins: []string{"https://example.com"}, AllowedMethods: cors.DefaultMethods})
+	if err != nil {
+		t.Fatalf("cors.New returned error: %v", err)
+	}
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zaptest.NewLogger(t), WithLogging(false), WithCORS(policy))
 
 	req := httptest.NewRequest(http.MethodOptions, "/api/calculate", nil)
 	req.Header.Set("Origin", "https://example.com")
@@ -498,3 +553,145 @@ func (s *stubStorage) SetPackSizes(sizes []int) error {
 	}
 	return nil
 }
+
+func TestCalculateEndpointReportsCacheStatus(t *testing.T) {
+	handler := NewHandler(calculator.NewCache(calculator.New(), calculator.CacheConfig{MaxEntries: 10}), storage.NewMemoryStorage())
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false), WithRateLimit(0, 0))
+
+	for i, want := range []string{"MISS", "HIT"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/calculate", bytes.NewBufferString(`{"items":750}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("X-Cache"); got != want {
+			t.Fatalf("request %d: expected X-Cache %s, got %s", i, want, got)
+		}
+		var body struct {
+			Cached bool `json:"cached"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if body.Cached != (want == "HIT") {
+			t.Fatalf("request %d: unexpected cached flag %v", i, body.Cached)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cache/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 from cache stats, got %d", rec.Code)
+	}
+	var stats calculator.CacheStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+}
+
+func TestCacheStatsDisabledWithoutCache(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/cache/stats", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 when caching is disabled, got %d", rec.Code)
+	}
+}
+
+func TestCalculateEndpointTieBreakPolicy(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	put := httptest.NewRequest(http.MethodPut, "/api/pack-sizes", bytes.NewReader([]byte(`{"packSizes":[2,3,4,7,9]}`)))
+	router.ServeHTTP(httptest.NewRecorder(), put)
+
+	calculate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/calculate", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for policy, want := range map[string]map[string]int{
+		"":                     {"3": 1, "7": 3},
+		"ascending-size-first": {"3": 1, "7": 3},
+		"larger-packs":         {"2": 1, "4": 1, "9": 2},
+		"fewer-sizes":          {"3": 2, "9": 2},
+		"lexicographic":        {"4": 2, "7": 1, "9": 1},
+	} {
+		rec := calculate(`{"items":24,"tieBreak":"` + policy + `"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected status 200, got %d", policy, rec.Code)
+		}
+		var body calculateResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%q: failed to decode response: %v", policy, err)
+		}
+		if len(body.Packs) != len(want) {
+			t.Fatalf("%q: expected %v, got %v", policy, want, body.Packs)
+		}
+		for size, count := range want {
+			if body.Packs[size] != count {
+				t.Fatalf("%q: expected %v, got %v", policy, want, body.Packs)
+			}
+		}
+	}
+
+	rec := calculate(`{"items":24,"tieBreak":"random"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown policy, got %d", rec.Code)
+	}
+}
+
+func TestCalculateEndpointPackSizesOverride(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	handler := NewHandler(calculator.New(), store)
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false))
+
+	calculate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/calculate", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := calculate(`{"items":263,"packSizes":[53,31,23,31]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body calculateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(body.PackSizes, []int{23, 31, 53}) {
+		t.Fatalf("expected normalised override sizes to be echoed, got %v", body.PackSizes)
+	}
+	if body.Packs["23"] != 2 || body.Packs["31"] != 7 {
+		t.Fatalf("unexpected packs %v", body.Packs)
+	}
+	if snapshot := store.Snapshot(); snapshot.Version != 1 || snapshot.PackSizes[0] != 250 {
+		t.Fatalf("expected stored sizes to be untouched, got %+v", snapshot)
+	}
+
+	rec = calculate(`{"items":750}`)
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(body.PackSizes, []int{250, 500, 1000, 2000, 5000}) {
+		t.Fatalf("expected stored sizes to be echoed, got %v", body.PackSizes)
+	}
+
+	for _, payload := range []string{`{"items":10,"packSizes":[]}`, `{"items":10,"packSizes":[0,5]}`, `{"items":10,"packSizes":[1,2,3,4,5,6,7,8,9,10,11]}`} {
+		rec = calculate(payload)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Invalid pack sizes") {
+			t.Fatalf("%s: expected 400 Invalid pack sizes, got %d: %s", payload, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/history"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+)
+
+const (
+	// actorHeader names the caller in the audit log. Authenticating proxies
+	// can set it; the client address is recorded otherwise.
+	actorHeader = "X-Actor"
+	// maxActorLength bounds the recorded actor.
+	maxActorLength = 128
+)
+
+// WithHistory records every calculate request and finished calculation job
+// in store and enables GET /api/calculations.
+func WithHistory(store history.Store) HandlerOption {
+	return func(h *Handler) {
+		h.history = store
+	}
+}
+
+// calculationAudit collects the audit record of one calculate request.
+type calculationAudit struct {
+	store  history.Store
+	start  time.Time
+	record history.Record
+}
+
+func (h *Handler) beginAudit(r *http.Request) *calculationAudit {
+	return &calculationAudit{
+		store: h.history,
+		start: time.Now(),
+		record: history.Record{
+			RequestID: requestIDFromContext(r.Context()),
+			Actor:     actorFromRequest(r),
+			Timestamp: h.clock(),
+		},
+	}
+}
+
+// fail records f as the outcome and writes it to the client.
+func (a *calculationAudit) fail(w http.ResponseWriter, f Failure) {
+	a.failed(f)
+	writeFailure(w, f)
+}
+
+// failed records f as the outcome.
+func (a *calculationAudit) failed(f Failure) {
+	a.record.Outcome = history.OutcomeError
+	a.record.Status = f.Status
+	a.record.Error = f.Error
+	a.record.Details = f.Details
+}
+
+// succeed records the distribution returned to the client.
+func (a *calculationAudit) succeed(packs map[int]int) {
+	a.record.Outcome = history.OutcomeSuccess
+	a.record.Status = http.StatusOK
+	a.record.Packs = packs
+	for _, count := range packs {
+		a.record.TotalPacks += count
+	}
+}
+
+// finish stores the record. A store failure must not fail the request, so
+// it is dropped.
+func (a *calculationAudit) finish() {
+	if a.store == nil {
+		return
+	}
+	a.record.Duration = time.Since(a.start)
+	_ = a.store.Append(a.record)
+}
+
+// submitJob queues a calculation job and, when the audit log is enabled,
+// records it once the job finishes. The lock is held across Submit so the
+// audit is registered before the job can finish.
+func (h *Handler) submitJob(audit *calculationAudit, items int, packSizes []int, policy calculator.TieBreakPolicy) (jobs.Job, error) {
+	h.jobAuditsMu.Lock()
+	defer h.jobAuditsMu.Unlock()
+
+	job, err := h.jobs.Submit(items, packSizes, policy)
+	if err == nil && h.history != nil {
+		h.jobAudits[job.ID] = audit
+	}
+	return job, err
+}
+
+// recordJob completes the audit of a finished job. The duration covers the
+// time the job spent queued.
+func (h *Handler) recordJob(job jobs.Job) {
+	h.jobAuditsMu.Lock()
+	audit, ok := h.jobAudits[job.ID]
+	delete(h.jobAudits, job.ID)
+	h.jobAuditsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if job.Status == jobs.StatusSucceeded {
+		audit.succeed(job.Result)
+	} else {
+		audit.failed(CalculationFailure(job.Items, job.Err))
+	}
+	audit.finish()
+}
+
+// actorFromRequest names the caller: the verified client certificate when
+// mutual TLS is in use, then the X-Actor header, then the client address.
+func actorFromRequest(r *http.Request) string {
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		if identity.CommonName != "" {
+			return identity.CommonName
+		}
+		return identity.Subject
+	}
+	if actor := strings.TrimSpace(r.Header.Get(actorHeader)); actor != "" {
+		if len(actor) > maxActorLength {
+			actor = actor[:maxActorLength]
+		}
+		return actor
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+type calculationRecordResponse struct {
+	ID              uint64         `json:"id"`
+	RequestID       string         `json:"requestId,omitempty"`
+	Actor           string         `json:"actor,omitempty"`
+	Items           int            `json:"items"`
+	PackSizes       []int          `json:"packSizes,omitempty"`
+	PackSizeVersion uint64         `json:"packSizeVersion"`
+	Outcome         string         `json:"outcome"`
+	Status          int            `json:"status"`
+	Packs           map[string]int `json:"packs,omitempty"`
+	TotalPacks      int            `json:"totalPacks"`
+	Error           string         `json:"error,omitempty"`
+	Details         string         `json:"details,omitempty"`
+	DurationMs      float64        `json:"durationMs"`
+	Timestamp       time.Time      `json:"timestamp"`
+}
+
+type calculationsResponse struct {
+	Calculations []calculationRecordResponse `json:"calculations"`
+	NextCursor   string                      `json:"nextCursor,omitempty"`
+}
+
+func newCalculationRecordResponse(r history.Record) calculationRecordResponse {
+	resp := calculationRecordResponse{
+		ID:              r.ID,
+		RequestID:       r.RequestID,
+		Actor:           r.Actor,
+		Items:           r.Items,
+		PackSizes:       r.PackSizes,
+		PackSizeVersion: r.PackSizeVersion,
+		Outcome:         string(r.Outcome),
+		Status:          r.Status,
+		TotalPacks:      r.TotalPacks,
+		Error:           r.Error,
+		Details:         r.Details,
+		DurationMs:      float64(r.Duration.Microseconds()) / 1000,
+		Timestamp:       r.Timestamp,
+	}
+	if r.Packs != nil {
+		resp.Packs = make(map[string]int, len(r.Packs))
+		for size, count := range r.Packs {
+			resp.Packs[strconv.Itoa(size)] = count
+		}
+	}
+	return resp
+}
+
+func (h *Handler) handleListCalculations(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	asCSV := wantsCSV(r)
+
+	query, err := parseHistoryQuery(params, asCSV)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request", err.Error())
+		return
+	}
+
+	page, err := h.history.List(query)
+	if err != nil {
+		if errors.Is(err, history.ErrInvalidCursor) {
+			writeError(w, http.StatusBadRequest, "Invalid request", err.Error(), "Use the nextCursor value from a previous response")
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	if asCSV {
+		writeCalculationsCSV(w, page)
+		return
+	}
+
+	resp := calculationsResponse{
+		Calculations: make([]calculationRecordResponse, len(page.Records)),
+		NextCursor:   page.NextCursor,
+	}
+	for i, record := range page.Records {
+		resp.Calculations[i] = newCalculationRecordResponse(record)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// parseHistoryQuery reads the filters and pagination parameters. CSV exports
+// default to the largest page so one request exports everything retained.
+func parseHistoryQuery(params url.Values, asCSV bool) (history.Query, error) {
+	var q history.Query
+	var err error
+
+	if q.From, err = parseTimeParam(params, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTimeParam(params, "to"); err != nil {
+		return q, err
+	}
+
+	switch outcome := history.Outcome(params.Get("outcome")); outcome {
+	case "", history.OutcomeSuccess, history.OutcomeError:
+		q.Outcome = outcome
+	default:
+		return q, fmt.Errorf("outcome must be %q or %q", history.OutcomeSuccess, history.OutcomeError)
+	}
+
+	if q.MinItems, err = parsePositiveParam(params, "minItems"); err != nil {
+		return q, err
+	}
+	if q.MaxItems, err = parsePositiveParam(params, "maxItems"); err != nil {
+		return q, err
+	}
+	items, err := parsePositiveParam(params, "items")
+	if err != nil {
+		return q, err
+	}
+	if items > 0 {
+		q.MinItems, q.MaxItems = items, items
+	}
+
+	if q.Limit, err = parsePositiveParam(params, "limit"); err != nil {
+		return q, err
+	}
+	if q.Limit == 0 && asCSV {
+		q.Limit = history.MaxLimit
+	}
+	q.Cursor = params.Get("cursor")
+	return q, nil
+}
+
+func parseTimeParam(params url.Values, name string) (time.Time, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return value, nil
+}
+
+func parsePositiveParam(params url.Values, name string) (int, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return value, nil
+}
+
+// wantsCSV reports whether the client asked for CSV, either with
+// ?format=csv or an Accept header of text/csv.
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/csv")
+}
+
+func writeCalculationsCSV(w http.ResponseWriter, page history.Page) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calculations.csv"`)
+	if page.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{
+		"id", "timestamp", "request_id", "actor", "items", "pack_sizes", "pack_size_version",
+		"outcome", "status", "packs", "total_packs", "error", "details", "duration_ms",
+	})
+	for _, r := range page.Records {
+		_ = writer.Write([]string{
+			strconv.FormatUint(r.ID, 10),
+			r.Timestamp.Format(time.RFC3339Nano),
+			csvText(r.RequestID),
+			csvText(r.Actor),
+			strconv.Itoa(r.Items),
+			joinInts(r.PackSizes, ";"),
+			strconv.FormatUint(r.PackSizeVersion, 10),
+			string(r.Outcome),
+			strconv.Itoa(r.Status),
+			formatPackCounts(r.Packs),
+			strconv.Itoa(r.TotalPacks),
+			csvText(r.Error),
+			csvText(r.Details),
+			strconv.FormatFloat(float64(r.Duration.Microseconds())/1000, 'f', 3, 64),
+		})
+	}
+	writer.Flush()
+}
+
+// csvText neutralises text that spreadsheets would evaluate as a formula by
+// prefixing it with a quote. Actors and request IDs come from clients.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func joinInts(values []int, sep string) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(parts, sep)
+}
+
+// formatPackCounts renders a distribution largest pack first, e.g.
+// "5000x2;250x1".
+func formatPackCounts(packs map[int]int) string {
+	sizes := make([]int, 0, len(packs))
+	for size := range packs {
+		sizes = append(sizes, size)
+	}
+	slices.Sort(sizes)
+	slices.Reverse(sizes)
+
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = fmt.Sprintf("%dx%d", size, packs[size])
+	}
+	return strings.Join(parts, ";")
+}
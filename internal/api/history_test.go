@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/history"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func setupHistoryRouter(t *testing.T) (http.Handler, *controllableClock) {
+	t.Helper()
+	clock := newControllableClock(time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC))
+	handler := NewHandler(calculator.New(), storage.NewMemoryStorage(),
+		WithClock(clock.Now),
+		WithHistory(history.NewMemoryStore(100)),
+	)
+	return NewRouter(handler, zaptest.NewLogger(t), WithLogging(false)), clock
+}
+
+func calculationsRequest(query string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, "/api/calculations"+query, nil)
+}
+
+func postCalculation(t *testing.T, router http.Handler, body, actor string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/calculate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if actor != "" {
+		req.Header.Set(actorHeader, actor)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func listCalculations(t *testing.T, router http.Handler, query string) calculationsResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, calculationsRequest(query))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp calculationsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestCalculationsRecordsEveryCalculation(t *testing.T) {
+	router, clock := setupHistoryRouter(t)
+
+	postCalculation(t, router, `{"items":12250}`, "warehouse-7")
+	clock.Advance(time.Minute)
+	postCalculation(t, router, `{"items":12001}`, "")
+	clock.Advance(time.Minute)
+	postCalculation(t, router, `{"items":0}`, "")
+	clock.Advance(time.Minute)
+	postCalculation(t, router, `{"items":263,"packSizes":[23,31,53],"explain":true}`, "")
+
+	resp := listCalculations(t, router, "")
+	if len(resp.Calculations) != 4 || resp.NextCursor != "" {
+		t.Fatalf("expected 4 records on one page, got %d (cursor %q)", len(resp.Calculations), resp.NextCursor)
+	}
+
+	explained, rejected, unpackable, packed := resp.Calculations[0], resp.Calculations[1], resp.Calculations[2], resp.Calculations[3]
+	if packed.Actor != "warehouse-7" || packed.RequestID == "" || packed.Outcome != "success" || packed.Status != http.StatusOK {
+		t.Fatalf("unexpected success record %+v", packed)
+	}
+	if packed.Items != 12250 || packed.TotalPacks != 4 || packed.Packs["5000"] != 2 || packed.PackSizeVersion != 1 || len(packed.PackSizes) != 5 {
+		t.Fatalf("unexpected success record %+v", packed)
+	}
+	if unpackable.Outcome != "error" || unpackable.Status != http.StatusUnprocessableEntity || unpackable.Error != "Cannot pack exactly" || unpackable.Actor != "192.0.2.1" {
+		t.Fatalf("unexpected error record %+v", unpackable)
+	}
+	if rejected.Status != http.StatusBadRequest || rejected.Items != 0 {
+		t.Fatalf("unexpected rejected record %+v", rejected)
+	}
+	if explained.Outcome != "success" || explained.PackSizeVersion != 0 || explained.Packs["31"] != 7 {
+		t.Fatalf("unexpected explained record %+v", explained)
+	}
+}
+
+func TestCalculationsFiltersAndPaginates(t *testing.T) {
+	router, clock := setupHistoryRouter(t)
+	for _, items := range []int{250, 251, 500, 750, 1000} {
+		postCalculation(t, router, `{"items":`+strconv.Itoa(items)+`}`, "")
+		clock.Advance(time.Minute)
+	}
+
+	if resp := listCalculations(t, router, "?outcome=error"); len(resp.Calculations) != 1 || resp.Calculations[0].Items != 251 {
+		t.Fatalf("unexpected error filter result %+v", resp.Calculations)
+	}
+	if resp := listCalculations(t, router, "?minItems=500&maxItems=750"); len(resp.Calculations) != 2 {
+		t.Fatalf("unexpected quantity filter result %+v", resp.Calculations)
+	}
+	if resp := listCalculations(t, router, "?items=1000"); len(resp.Calculations) != 1 || resp.Calculations[0].Items != 1000 {
+		t.Fatalf("unexpected exact quantity result %+v", resp.Calculations)
+	}
+	resp := listCalculations(t, router, "?from=2024-11-01T12:01:00Z&to=2024-11-01T12:03:00Z")
+	if len(resp.Calculations) != 2 || resp.Calculations[0].Items != 500 || resp.Calculations[1].Items != 251 {
+		t.Fatalf("unexpected time filter result %+v", resp.Calculations)
+	}
+
+	first := listCalculations(t, router, "?limit=3")
+	if len(first.Calculations) != 3 || first.NextCursor == "" {
+		t.Fatalf("expected a full first page with a cursor, got %+v", first)
+	}
+	second := listCalculations(t, router, "?limit=3&cursor="+first.NextCursor)
+	if len(second.Calculations) != 2 || second.NextCursor != "" || second.Calculations[1].Items != 250 {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+}
+
+func TestCalculationsCSVExport(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+	postCalculation(t, router, `{"items":12250}`, "ops")
+	postCalculation(t, router, `{"items":12001}`, "ops")
+
+	req := calculationsRequest("")
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected CSV response, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "id" {
+		t.Fatalf("expected header and two rows, got %v", rows)
+	}
+	if rows[1][7] != "error" || rows[2][9] != "5000x2;2000x1;250x1" || rows[2][5] != "250;500;1000;2000;5000" {
+		t.Fatalf("unexpected CSV rows %v", rows)
+	}
+}
+
+func TestCalculationsValidatesQuery(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+
+	for _, query := range []string{"?from=yesterday", "?outcome=maybe", "?limit=-1", "?minItems=x", "?cursor=%21"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, calculationsRequest(query))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestCalculationsRequireConfiguredToken(t *testing.T) {
+	handler := NewHandler(calculator.New(), storage.NewMemoryStorage(), WithHistory(history.NewMemoryStore(10)))
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false), WithCalculationsToken("s3cret"))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, calculationsRequest(""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without the token, got %d", rec.Code)
+	}
+
+	req := calculationsRequest("")
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with the token, got %d", rec.Code)
+	}
+}
+
+func TestCalculationsDisabledWithoutHistory(t *testing.T) {
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zaptest.NewLogger(t), WithLogging(false))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, calculationsRequest(""))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a history store, got %d", rec.Code)
+	}
+}
+
+func TestCalculationsCSVNeutralisesFormulas(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+	postCalculation(t, router, `{"items":250}`, "=HYPERLINK(\"http://example.com\")")
+	postCalculation(t, router, `{"items":250}`, "-ops")
+
+	req := calculationsRequest("")
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	rows, err := csv.NewReader(bytes.NewReader(rec.Body.Bytes())).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[1][3] != "'-ops" || rows[2][3] != `'=HYPERLINK("http://example.com")` {
+		t.Fatalf("expected actors to be quoted, got %v", rows)
+	}
+}
+
+func TestCalculationsRecordsJobs(t *testing.T) {
+	calc := calculator.New()
+	manager := jobs.NewManager(calc, jobs.Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(manager.Close)
+	store := history.NewMemoryStore(10)
+	router := NewRouter(NewHandler(calc, storage.NewMemoryStorage(), WithJobs(manager), WithHistory(store)),
+		zaptest.NewLogger(t), WithLogging(false))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", strings.NewReader(`{"items":263,"packSizes":[250,500]}`))
+	req.Header.Set(actorHeader, "batch-runner")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+
+	var page history.Page
+	deadline := time.Now().Add(2 * time.Second)
+	for len(page.Records) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		var err error
+		if page, err = store.List(history.Query{}); err != nil {
+			t.Fatalf("List returned error: %v", err)
+		}
+	}
+	if len(page.Records) != 1 {
+		t.Fatalf("expected the finished job to be recorded, got %+v", page.Records)
+	}
+	record := page.Records[0]
+	if record.Actor != "batch-runner" || record.Items != 263 || record.Outcome != history.OutcomeError || record.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected job record %+v", record)
+	}
+}
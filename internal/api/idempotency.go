@@ -0,0 +1,343 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencySweepInterval = time.Minute
+	defaultIdempotencyTTL    = 24 * time.Hour
+	// maxIdempotentBodyBytes bounds the request bodies buffered for
+	// fingerprinting; every idempotent endpoint takes a small JSON document.
+	maxIdempotentBodyBytes = 1 << 20
+	// defaultIdempotencyMaxEntries and defaultIdempotencyMaxBytes bound the
+	// stored responses; the least recently used ones are evicted first.
+	defaultIdempotencyMaxEntries = 10_000
+	defaultIdempotencyMaxBytes   = 32 << 20
+	// idempotencyEntryOverhead approximates the bookkeeping bytes of an
+	// entry (list element, map slot, key and fingerprint).
+	idempotencyEntryOverhead = 512
+)
+
+// storedResponse is a captured HTTP response that can be replayed verbatim.
+type storedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r *storedResponse) size() int64 {
+	size := int64(len(r.body))
+	for key, values := range r.header {
+		size += int64(len(key))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+type idempotencyEntry struct {
+	key         string
+	fingerprint string
+	expiresAt   time.Time
+	inFlight    bool
+	response    *storedResponse
+	size        int64
+}
+
+type idempotencyOutcome int
+
+const (
+	idempotencyProceed idempotencyOutcome = iota
+	idempotencyReplay
+	idempotencyMismatch
+	idempotencyInFlight
+)
+
+// idempotencyStore remembers the first response produced for each
+// Idempotency-Key until its TTL elapses or, once the store is full, until
+// it is the least recently used one.
+type idempotencyStore struct {
+	ttl        time.Duration
+	now        func() time.Time
+	maxEntries int
+	maxBytes   int64
+
+	mu        sync.Mutex
+	lru       *list.List
+	entries   map[string]*list.Element
+	bytes     int64
+	nextSweep time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:        ttl,
+		now:        time.Now,
+		maxEntries: defaultIdempotencyMaxEntries,
+		maxBytes:   defaultIdempotencyMaxBytes,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// begin reserves key for the request identified by fingerprint. When a
+// completed response already exists for the same fingerprint it is returned
+// for replay.
+func (s *idempotencyStore) begin(key, fingerprint string) (idempotencyOutcome, *storedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweepLocked(now)
+
+	elem, ok := s.entries[key]
+	if ok {
+		if entry := elem.Value.(*idempotencyEntry); !entry.inFlight && !now.Before(entry.expiresAt) {
+			s.removeLocked(elem)
+			ok = false
+		}
+	}
+	if !ok {
+		entry := &idempotencyEntry{
+			key:         key,
+			fingerprint: fingerprint,
+			inFlight:    true,
+			size:        idempotencyEntryOverhead,
+		}
+		s.entries[key] = s.lru.PushFront(entry)
+		s.bytes += entry.size
+		s.evictLocked()
+		return idempotencyProceed, nil
+	}
+
+	s.lru.MoveToFront(elem)
+	entry := elem.Value.(*idempotencyEntry)
+	switch {
+	case entry.fingerprint != fingerprint:
+		return idempotencyMismatch, nil
+	case entry.inFlight:
+		return idempotencyInFlight, nil
+	default:
+		return idempotencyReplay, entry.response
+	}
+}
+
+// complete stores resp for key. A nil response releases the reservation so the
+// request can be retried.
+func (s *idempotencyStore) complete(key string, resp *storedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	if resp == nil {
+		s.removeLocked(elem)
+		return
+	}
+	entry := elem.Value.(*idempotencyEntry)
+	entry.inFlight = false
+	entry.response = resp
+	entry.expiresAt = s.now().Add(s.ttl)
+	s.bytes -= entry.size
+	entry.size = idempotencyEntryOverhead + resp.size()
+	s.bytes += entry.size
+	s.evictLocked()
+}
+
+func (s *idempotencyStore) sweepLocked(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for _, elem := range s.entries {
+		entry := elem.Value.(*idempotencyEntry)
+		if !entry.inFlight && !now.Before(entry.expiresAt) {
+			s.removeLocked(elem)
+		}
+	}
+	s.nextSweep = now.Add(idempotencySweepInterval)
+}
+
+// evictLocked drops the least recently used completed entries until the
+// store is within its limits. Reservations of requests still in flight are
+// kept, so a concurrent duplicate is always detected.
+func (s *idempotencyStore) evictLocked() {
+	elem := s.lru.Back()
+	for elem != nil && (s.lru.Len() > s.maxEntries || (s.maxBytes > 0 && s.bytes > s.maxBytes)) {
+		prev := elem.Prev()
+		if !elem.Value.(*idempotencyEntry).inFlight {
+			s.removeLocked(elem)
+		}
+		elem = prev
+	}
+}
+
+func (s *idempotencyStore) removeLocked(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*idempotencyEntry)
+	delete(s.entries, entry.key)
+	s.bytes -= entry.size
+}
+
+func idempotencyMiddleware(store *idempotencyStore, next http.Handler) http.Handler {
+	if store == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+		if key == "" || !isIdempotentCandidate(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "Invalid request", "Idempotency-Key must not exceed 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, "Invalid request", "request body must not exceed 1 MiB")
+				return
+			}
+			writeError(w, http.StatusBadRequest, "Invalid request", "unable to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = scopedIdempotencyKey(r, key)
+		outcome, stored := store.begin(key, requestFingerprint(r, body))
+		switch outcome {
+		case idempotencyReplay:
+			replayResponse(w, stored)
+			return
+		case idempotencyMismatch:
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency key reused",
+				"Idempotency-Key was already used with a different request payload")
+			return
+		case idempotencyInFlight:
+			writeError(w, http.StatusConflict, "Request in progress",
+				"a request with this Idempotency-Key is still being processed", "Retry after the original request completes")
+			return
+		}
+
+		capture := newCaptureWriter()
+		var result *storedResponse
+		defer func() {
+			store.complete(key, result)
+		}()
+
+		next.ServeHTTP(capture, r)
+
+		// Server errors are not remembered so that clients can retry them.
+		if capture.status < http.StatusInternalServerError {
+			result = capture.response()
+		}
+		copyHeader(w.Header(), capture.header)
+		w.WriteHeader(capture.status)
+		_, _ = w.Write(capture.body.Bytes())
+	})
+}
+
+func isIdempotentCandidate(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// scopedIdempotencyKey scopes key to its caller, so that clients can neither
+// replay nor detect each other's keys: the verified client certificate, or
+// otherwise the actor and the credential the request presents.
+func scopedIdempotencyKey(r *http.Request, key string) string {
+	if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+		return "cert:" + identity.Fingerprint + "\n" + key
+	}
+	credential := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+	return "actor:" + actorFromRequest(r) + "\n" + hex.EncodeToString(credential[:]) + "\n" + key
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, r.Method)
+	_, _ = io.WriteString(hash, "\n")
+	_, _ = io.WriteString(hash, r.URL.Path)
+	_, _ = io.WriteString(hash, "\n")
+	_, _ = io.WriteString(hash, r.URL.RawQuery)
+	_, _ = io.WriteString(hash, "\n")
+	_, _ = hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, resp *storedResponse) {
+	copyHeader(w.Header(), resp.header)
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(resp.status)
+	_, _ = w.Write(resp.body)
+}
+
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst[key] = append([]string(nil), values...)
+	}
+}
+
+// captureWriter buffers a handler's response so it can be stored before being
+// forwarded to the client.
+type captureWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newCaptureWriter() *captureWriter {
+	return &captureWriter{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (c *captureWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.status = status
+	c.wroteHeader = true
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	return c.body.Write(p)
+}
+
+func (c *captureWriter) response() *storedResponse {
+	header := make(http.Header, len(c.header))
+	copyHeader(header, c.header)
+	return &storedResponse{
+		status: c.status,
+		header: header,
+		body:   bytes.Clone(c.body.Bytes()),
+	}
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-Call", strings.Repeat("x", int(n)))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+
+	first := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{"items":10}`)
+	second := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{"items":10}`)
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls.Load())
+	}
+	if second.Code != http.StatusCreated {
+		t.Fatalf("expected replayed status 201, got %d", second.Code)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected replayed body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if got := second.Header().Get("X-Call"); got != "x" {
+		t.Fatalf("expected stored header to be replayed, got %q", got)
+	}
+	if second.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("expected %s header on replay", idempotentReplayedHeader)
+	}
+	if first.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatalf("did not expect %s header on first response", idempotentReplayedHeader)
+	}
+}
+
+func TestIdempotencyRejectsDifferentPayload(t *testing.T) {
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodPut, "/api/pack-sizes", "key-1", `{"packSizes":[1]}`)
+	rec := performIdempotentRequest(handler, http.MethodPut, "/api/pack-sizes", "key-1", `{"packSizes":[2]}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for reused key, got %d", rec.Code)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+	rec := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+
+	if rec.Code != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected retry after server error to re-run handler, got status %d after %d calls", rec.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyEntriesExpire(t *testing.T) {
+	clock := newControllableClock(time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC))
+	store := newIdempotencyStore(time.Minute)
+	store.now = clock.Now
+
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(store, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+	clock.Advance(2 * time.Minute)
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{"items":1}`)
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected expired key to be reusable, handler ran %d times", calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsConcurrentDuplicate(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	original := httptest.NewRequest(http.MethodPost, "/api/calculate", nil)
+	if outcome, _ := store.begin(scopedIdempotencyKey(original, "key-1"), requestFingerprint(original, nil)); outcome != idempotencyProceed {
+		t.Fatalf("expected first request to proceed, got %v", outcome)
+	}
+
+	handler := idempotencyMiddleware(store, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatalf("handler should not execute while the key is in flight")
+	}))
+	rec := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", "")
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while original request is in flight, got %d", rec.Code)
+	}
+}
+
+func TestIdempotencyIgnoresSafeMethods(t *testing.T) {
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodGet, "/api/pack-sizes", "key-1", "")
+	performIdempotentRequest(handler, http.MethodGet, "/api/pack-sizes", "key-1", "")
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected GET requests to bypass idempotency, handler ran %d times", calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsOversizedKey(t *testing.T) {
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatalf("handler should not execute for invalid key")
+	}))
+
+	rec := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for oversized key, got %d", rec.Code)
+	}
+}
+
+func TestRouterReplaysPutPackSizes(t *testing.T) {
+	router := newTestRouter(t, WithLogging(false), WithRateLimit(0, 0))
+
+	first := performIdempotentRequest(router, http.MethodPut, "/api/pack-sizes", "put-1", `{"packSizes":[10,20]}`)
+	performIdempotentRequest(router, http.MethodPut, "/api/pack-sizes", "put-2", `{"packSizes":[30]}`)
+	replay := performIdempotentRequest(router, http.MethodPut, "/api/pack-sizes", "put-1", `{"packSizes":[10,20]}`)
+
+	if replay.Code != http.StatusOK || replay.Body.String() != first.Body.String() {
+		t.Fatalf("expected stored response to be replayed, got %d %q", replay.Code, replay.Body.String())
+	}
+
+	current := httptest.NewRecorder()
+	router.ServeHTTP(current, httptest.NewRequest(http.MethodGet, "/api/pack-sizes", nil))
+	if !strings.Contains(current.Body.String(), `"packSizes":[30]`) {
+		t.Fatalf("expected replay not to re-apply pack sizes, got %s", current.Body.String())
+	}
+}
+
+func TestWithIdempotencyDisabled(t *testing.T) {
+	router := newTestRouter(t, WithLogging(false), WithRateLimit(0, 0), WithIdempotency(0))
+
+	performIdempotentRequest(router, http.MethodPut, "/api/pack-sizes", "put-1", `{"packSizes":[10]}`)
+	rec := performIdempotentRequest(router, http.MethodPut, "/api/pack-sizes", "put-1", `{"packSizes":[20]}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected key reuse to be ignored when disabled, got %d", rec.Code)
+	}
+}
+
+func performIdempotentRequest(handler http.Handler, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyFingerprintIncludesQuery(t *testing.T) {
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodPost, "/api/simulate?format=json", "key-1", `{}`)
+	rec := performIdempotentRequest(handler, http.MethodPost, "/api/simulate?format=csv", "key-1", `{}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a key reused with another query, got %d", rec.Code)
+	}
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatalf("handler should not execute for an oversized body")
+	}))
+
+	rec := performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", strings.Repeat(" ", maxIdempotentBodyBytes+1))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized body, got %d", rec.Code)
+	}
+}
+
+func TestIdempotencyEvictsLeastRecentlyUsed(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	store.maxEntries = 2
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(store, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-2", `{}`)
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-3", `{}`)
+
+	if store.lru.Len() != 2 || store.entries[scopedIdempotencyKey(httptest.NewRequest(http.MethodPost, "/", nil), "key-2")] != nil {
+		t.Fatalf("expected key-2 to be evicted, have %d entries", store.lru.Len())
+	}
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-1", `{}`)
+	if calls.Load() != 3 {
+		t.Fatalf("expected key-1 to be replayed, handler ran %d times", calls.Load())
+	}
+
+	store.maxBytes = idempotencyEntryOverhead
+	performIdempotentRequest(handler, http.MethodPost, "/api/calculate", "key-4", `{}`)
+	if store.lru.Len() != 1 || store.bytes > store.maxBytes {
+		t.Fatalf("expected the byte limit to be enforced, have %d entries and %d bytes", store.lru.Len(), store.bytes)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToCallers(t *testing.T) {
+	var calls atomic.Int32
+	handler := idempotencyMiddleware(newIdempotencyStore(time.Hour), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(actor, authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/calculate", strings.NewReader(body))
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+		req.Header.Set(actorHeader, actor)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	send("client-a", "", `{"items":10}`)
+	if rec := send("client-b", "", `{"items":10}`); rec.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatalf("expected another caller not to get a stored response")
+	}
+	if rec := send("client-c", "", `{"items":20}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected another caller's key not to be reported as reused, got %d", rec.Code)
+	}
+	if rec := send("client-a", "Bearer other", `{"items":10}`); rec.Header().Get(idempotentReplayedHeader) != "" {
+		t.Fatalf("expected another credential not to get a stored response")
+	}
+	if rec := send("client-a", "", `{"items":10}`); rec.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("expected the original caller to get its stored response")
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("expected four distinct calls, got %d", calls.Load())
+	}
+}
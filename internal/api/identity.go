@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+)
+
+const clientIdentityContextKey contextKey = "clientIdentity"
+
+// ClientIdentity describes the verified certificate a client presented over
+// mutual TLS.
+type ClientIdentity struct {
+	Subject        string
+	CommonName     string
+	DNSNames       []string
+	URIs           []string
+	EmailAddresses []string
+	SerialNumber   string
+	// Fingerprint is the hex SHA-256 of the certificate.
+	Fingerprint string
+}
+
+// ClientIdentityFromContext returns the identity of the client certificate
+// verified for the request, if any.
+func ClientIdentityFromContext(ctx context.Context) (ClientIdentity, bool) {
+	identity, ok := ctx.Value(clientIdentityContextKey).(ClientIdentity)
+	return identity, ok
+}
+
+// clientIdentityMiddleware stores the verified client certificate identity
+// in the request context. Unverified certificates are ignored.
+func clientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			identity := newClientIdentity(r.TLS.VerifiedChains[0][0])
+			r = r.WithContext(context.WithValue(r.Context(), clientIdentityContextKey, identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newClientIdentity(cert *x509.Certificate) ClientIdentity {
+	uris := make([]string, len(cert.URIs))
+	for i, uri := range cert.URIs {
+		uris[i] = uri.String()
+	}
+	fingerprint := sha256.Sum256(cert.Raw)
+	return ClientIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		URIs:           uris,
+		EmailAddresses: cert.EmailAddresses,
+		SerialNumber:   cert.SerialNumber.String(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// withClientCertificate marks req as having presented a verified client
+// certificate, as the TLS server does for mutual TLS.
+func withClientCertificate(req *http.Request, commonName string) *http.Request {
+	cert := &x509.Certificate{
+		Raw:          []byte(commonName),
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Warehouses"}},
+		DNSNames:     []string{"wh7.example.com"},
+		URIs:         []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/warehouse/7"}},
+	}
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	return req
+}
+
+func TestClientIdentityMiddleware(t *testing.T) {
+	var identity ClientIdentity
+	var ok bool
+	handler := clientIdentityMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		identity, ok = ClientIdentityFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), withClientCertificate(httptest.NewRequest(http.MethodGet, "/", nil), "warehouse-7"))
+	if !ok {
+		t.Fatalf("expected a client identity")
+	}
+	if identity.CommonName != "warehouse-7" || identity.Subject != "CN=warehouse-7,O=Warehouses" || identity.SerialNumber != "42" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+	if len(identity.URIs) != 1 || identity.URIs[0] != "spiffe://example.com/warehouse/7" || len(identity.Fingerprint) != 64 {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// Certificates the server did not verify carry no identity.
+	req := withClientCertificate(httptest.NewRequest(http.MethodGet, "/", nil), "mallory")
+	req.TLS.VerifiedChains = nil
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if ok {
+		t.Fatalf("expected no identity for an unverified certificate, got %+v", identity)
+	}
+}
+
+func TestCalculationsActorPrefersClientCertificate(t *testing.T) {
+	router, _ := setupHistoryRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/calculate", strings.NewReader(`{"items":250}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(actorHeader, "spoofed")
+	router.ServeHTTP(httptest.NewRecorder(), withClientCertificate(req, "warehouse-7"))
+
+	resp := listCalculations(t, router, "")
+	if len(resp.Calculations) != 1 || resp.Calculations[0].Actor != "warehouse-7" {
+		t.Fatalf("expected the certificate identity as actor, got %+v", resp.Calculations)
+	}
+}
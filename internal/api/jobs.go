@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+)
+
+// WithJobs enables the asynchronous calculation endpoints backed by manager.
+func WithJobs(manager *jobs.Manager) HandlerOption {
+	return func(h *Handler) {
+		h.jobs = manager
+	}
+}
+
+func (h *Handler) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req calculateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request", "unable to parse JSON payload")
+		return
+	}
+
+	if req.Items <= 0 {
+		writeFailure(w, InvalidItemsFailure())
+		return
+	}
+	if req.Explain {
+		writeError(w, http.StatusBadRequest, "Invalid request", "explain is not supported for jobs", "Use POST /api/calculate with explain")
+		return
+	}
+	var policy calculator.TieBreakPolicy
+	if req.TieBreak != "" {
+		var err error
+		if policy, err = calculator.ParseTieBreakPolicy(req.TieBreak); err != nil {
+			writeFailure(w, TieBreakFailure(err))
+			return
+		}
+	}
+
+	snapshot, failure, ok := h.requestPackSizes(req.PackSizes)
+	if !ok {
+		writeFailure(w, failure)
+		return
+	}
+
+	audit := h.beginAudit(r)
+	audit.record.Items = req.Items
+	audit.record.PackSizes = snapshot.PackSizes
+	audit.record.PackSizeVersion = snapshot.Version
+	job, err := h.submitJob(audit, req.Items, snapshot.PackSizes, policy)
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrClosed) {
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, "Job queue unavailable", err.Error(), "Retry shortly or submit a smaller order")
+			return
+		}
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, newJobResponse(job))
+}
+
+func (h *Handler) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newJobResponse(job))
+}
+
+func (h *Handler) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newJobResponse(job))
+}
+
+func writeJobError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, "Job not found", "job does not exist or has expired")
+	case errors.Is(err, jobs.ErrFinished):
+		writeError(w, http.StatusConflict, "Job already finished", err.Error())
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+type jobResponse struct {
+	ID         string             `json:"id"`
+	Status     jobs.Status        `json:"status"`
+	Progress   float64            `json:"progress"`
+	Items      int                `json:"items"`
+	PackSizes  []int              `json:"packSizes"`
+	TieBreak   string             `json:"tieBreak,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	StartedAt  *time.Time         `json:"startedAt,omitempty"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Result     *calculateResponse `json:"result,omitempty"`
+	Error      *errorResponse     `json:"error,omitempty"`
+}
+
+func newJobResponse(job jobs.Job) jobResponse {
+	resp := jobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Progress:   math.Round(job.Progress*10) / 10,
+		Items:      job.Items,
+		PackSizes:  job.PackSizes,
+		TieBreak:   string(job.TieBreak),
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  optionalTime(job.StartedAt),
+		FinishedAt: optionalTime(job.FinishedAt),
+	}
+
+	switch job.Status {
+	case jobs.StatusSucceeded:
+		result := newCalculateResponse(job.Items, job.PackSizes, job.Result, job.Duration)
+		resp.Result = &result
+	case jobs.StatusFailed, jobs.StatusCanceled:
+		if job.Err != nil {
+			_, errResp := calculationError(job.Items, job.Err)
+			resp.Error = &errResp
+		}
+	}
+	return resp
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
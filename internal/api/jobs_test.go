@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func setupJobsRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	calc := calculator.New()
+	manager := jobs.NewManager(calc, jobs.Config{Workers: 1, QueueSize: 4})
+	t.Cleanup(manager.Close)
+
+	handler := NewHandler(calc, storage.NewMemoryStorage(), WithJobs(manager))
+	return NewRouter(handler, zaptest.NewLogger(t), WithLogging(false), WithRateLimit(0, 0))
+}
+
+func TestJobsEndpointsLifecycle(t *testing.T) {
+	router := setupJobsRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(`{"items":750}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	var submitted jobResponse
+	if err := json.NewDecoder(rec.Body).Decode(&submitted); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if submitted.ID == "" {
+		t.Fatalf("expected job ID in response")
+	}
+	if got := rec.Header().Get("Location"); got != "/api/jobs/"+submitted.ID {
+		t.Fatalf("unexpected Location header %q", got)
+	}
+
+	var job jobResponse
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs/"+submitted.ID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if job.Status.Finished() {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if job.Status != jobs.StatusSucceeded {
+		t.Fatalf("expected job to succeed, got %s", job.Status)
+	}
+	if job.Progress != 100 {
+		t.Fatalf("expected progress 100, got %f", job.Progress)
+	}
+	if job.Result == nil || job.Result.TotalPacks != 2 || job.Result.TotalItems != 750 {
+		t.Fatalf("unexpected result: %+v", job.Result)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/jobs/"+submitted.ID, nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 when cancelling finished job, got %d", rec.Code)
+	}
+}
+
+func TestJobsEndpointsPackSizesOverride(t *testing.T) {
+	router := setupJobsRouter(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(`{"items":263,"packSizes":[23,31,53]}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", rec.Code)
+	}
+	var submitted jobResponse
+	if err := json.NewDecoder(rec.Body).Decode(&submitted); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(submitted.PackSizes) != 3 || submitted.PackSizes[0] != 23 {
+		t.Fatalf("expected the job to capture the request sizes, got %v", submitted.PackSizes)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(`{"items":263,"packSizes":[-1]}`))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid sizes, got %d", rec.Code)
+	}
+}
+
+func TestJobsEndpointsRejectInvalidItems(t *testing.T) {
+	router := setupJobsRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(`{"items":0}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestJobsEndpointsUnknownJob(t *testing.T) {
+	router := setupJobsRouter(t)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, "/api/jobs/missing", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404 for %s, got %d", method, rec.Code)
+		}
+	}
+}
+
+func TestJobsEndpointsDisabledWithoutManager(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(`{"items":750}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 when jobs are disabled, got %d", rec.Code)
+	}
+}
+
+func TestSubmitJobValidatesCalculationOptions(t *testing.T) {
+	router := setupJobsRouter(t)
+
+	cases := map[string]int{
+		`{"items":4,"packSizes":[1,2,3],"tieBreak":"larger-packs"}`: http.StatusAccepted,
+		`{"items":4,"tieBreak":"random"}`:                           http.StatusBadRequest,
+		`{"items":4,"explain":true}`:                                http.StatusBadRequest,
+	}
+	for body, status := range cases {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/jobs/calculate", bytes.NewBufferString(body)))
+		if rec.Code != status {
+			t.Fatalf("%s: expected %d, got %d", body, status, rec.Code)
+		}
+		if status != http.StatusAccepted {
+			continue
+		}
+		var job jobResponse
+		if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if job.TieBreak != "larger-packs" {
+			t.Fatalf("expected the tie-break policy to be kept, got %q", job.TieBreak)
+		}
+	}
+}
@@ -27,6 +27,15 @@ func newTokenBucketLimiter(ratePerSecond float64, burst int) rateLimiter {
 	}
 }
 
+// newRateLimiter returns a token bucket limiter, or nil when a non-positive
+// rate or burst disables rate limiting.
+func newRateLimiter(ratePerSecond float64, burst int) rateLimiter {
+	if ratePerSecond <= 0 || burst <= 0 {
+		return nil
+	}
+	return newTokenBucketLimiter(ratePerSecond, burst)
+}
+
 func (l *limiterAdapter) Allow() bool {
 	if l == nil || l.limiter == nil {
 		return true
@@ -43,6 +52,10 @@ func rateLimitMiddleware(limiter rateLimiter, next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		writeError(w, http.StatusTooManyRequests, "Too many requests", "rate limit exceeded, please retry shortly")
+		writeRateLimited(w)
 	})
 }
+
+func writeRateLimited(w http.ResponseWriter) {
+	writeError(w, http.StatusTooManyRequests, "Too many requests", "rate limit exceeded, please retry shortly")
+}
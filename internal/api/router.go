@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eugenenazirov/re-partners/internal/cors"
+	"github.com/eugenenazirov/re-partners/internal/logging"
 	"go.uber.org/zap"
 )
 
@@ -22,6 +24,14 @@ func WithLogging(enabled bool) RouterOption {
 	}
 }
 
+// WithAccessLogger writes access logs to logger instead of the router's
+// logger, e.g. a sampled one.
+func WithAccessLogger(logger *zap.Logger) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.accessLogger = logger
+	}
+}
+
 // WithRateLimiter overrides the default request rate limiter (primarily for tests).
 func WithRateLimiter(limiter rateLimiter) RouterOption {
 	return func(cfg *routerConfig) {
@@ -33,18 +43,58 @@ func WithRateLimiter(limiter rateLimiter) RouterOption {
 // Supplying a non-positive rate or burst disables rate limiting.
 func WithRateLimit(rate float64, burst int) RouterOption {
 	return func(cfg *routerConfig) {
-		if rate <= 0 || burst <= 0 {
-			cfg.rateLimiter = nil
+		cfg.rateLimiter = newRateLimiter(rate, burst)
+	}
+}
+
+// WithRuntime reads request logging and rate limiting from rt on every
+// request, so they can be changed while the server runs. It takes precedence
+// over WithLogging, WithRateLimiter and WithRateLimit.
+func WithRuntime(rt *Runtime) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.runtime = rt
+	}
+}
+
+// WithIdempotency configures how long responses to requests carrying an
+// Idempotency-Key header are retained for replay. A non-positive TTL disables
+// idempotency handling.
+func WithIdempotency(ttl time.Duration) RouterOption {
+	return func(cfg *routerConfig) {
+		if ttl <= 0 {
+			cfg.idempotency = nil
 			return
 		}
-		cfg.rateLimiter = newTokenBucketLimiter(rate, burst)
+		cfg.idempotency = newIdempotencyStore(ttl)
+	}
+}
+
+// WithCORS allows the cross-origin requests permitted by policy. Without
+// it, browsers only allow requests from the API's own origin.
+func WithCORS(policy *cors.Policy) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.cors = policy
+	}
+}
+
+// WithCalculationsToken requires token as a Bearer credential for
+// GET /api/calculations, whose records name callers and their orders. An
+// empty token leaves the audit log readable by every client.
+func WithCalculationsToken(token string) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.calculationsToken = token
 	}
 }
 
 type routerConfig struct {
-	enableLogging bool
-	logger        *zap.Logger
-	rateLimiter   rateLimiter
+	enableLogging     bool
+	logger            *zap.Logger
+	accessLogger      *zap.Logger
+	rateLimiter       rateLimiter
+	idempotency       *idempotencyStore
+	runtime           *Runtime
+	cors              *cors.Policy
+	calculationsToken string
 }
 
 // NewRouter creates an HTTP router with standard middleware.
@@ -53,46 +103,145 @@ func NewRouter(handler *Handler, logger *zap.Logger, opts ...RouterOption) http.
 		enableLogging: true,
 		logger:        logger,
 		rateLimiter:   newTokenBucketLimiter(25, 50),
+		idempotency:   newIdempotencyStore(defaultIdempotencyTTL),
 	}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
+	if cfg.accessLogger == nil {
+		cfg.accessLogger = cfg.logger
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("GET /api/health", http.HandlerFunc(handler.handleHealth))
+	mux.Handle("GET /api/ready", http.HandlerFunc(handler.handleReady))
 	mux.Handle("GET /api/pack-sizes", http.HandlerFunc(handler.handleGetPackSizes))
 	mux.Handle("PUT /api/pack-sizes", http.HandlerFunc(handler.handlePutPackSizes))
 	mux.Handle("POST /api/calculate", http.HandlerFunc(handler.handleCalculate))
+	mux.Handle("POST /api/simulate", http.HandlerFunc(handler.handleSimulate))
+	if handler.cachingEnabled() {
+		mux.Handle("GET /api/cache/stats", http.HandlerFunc(handler.handleCacheStats))
+	}
+	if handler.history != nil {
+		var calculations http.Handler = http.HandlerFunc(handler.handleListCalculations)
+		if cfg.calculationsToken != "" {
+			calculations = adminAuthMiddleware(cfg.calculationsToken, calculations)
+		}
+		mux.Handle("GET /api/calculations", calculations)
+	}
+	if handler.jobs != nil {
+		mux.Handle("POST /api/jobs/calculate", http.HandlerFunc(handler.handleSubmitJob))
+		mux.Handle("GET /api/jobs/{id}", http.HandlerFunc(handler.handleGetJob))
+		mux.Handle("DELETE /api/jobs/{id}", http.HandlerFunc(handler.handleCancelJob))
+	}
 
 	var root http.Handler = mux
-	root = corsMiddleware(root)
-	root = recoveryMiddleware(cfg.logger, root)
-	if cfg.enableLogging {
-		root = loggingMiddleware(cfg.logger, root)
+	root = idempotencyMiddleware(cfg.idempotency, root)
+	if cfg.cors != nil && cfg.cors.Enabled() {
+		root = corsMiddleware(cfg.cors, mux, root)
+	}
+	root = recoveryMiddleware(root)
+	if cfg.runtime != nil {
+		root = cfg.runtime.middleware(cfg.accessLogger, root)
+	} else {
+		if cfg.enableLogging {
+			root = loggingMiddleware(cfg.accessLogger, root)
+		}
+		root = rateLimitMiddleware(cfg.rateLimiter, root)
 	}
-	root = rateLimitMiddleware(cfg.rateLimiter, root)
+	root = requestLoggerMiddleware(cfg.logger, mux, root)
+	root = clientIdentityMiddleware(root)
 	root = requestIDMiddleware(root)
 
 	return root
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
+// ExposedHeaders are the response headers that scripts on other origins may
+// read; see cors.Config.
+var ExposedHeaders = []string{"X-Request-ID", "Idempotent-Replayed", "X-Cache", "X-Next-Cursor"}
+
+// corsMiddleware applies policy to requests carrying an Origin header.
+// Preflight requests are answered here: they are rejected unless the
+// origin is allowed, routes has a route for the requested method and path,
+// and the policy allows the method and headers. Other requests are served
+// as usual, with CORS headers only when the origin is allowed, so browsers
+// withhold the responses from other origins.
+func corsMiddleware(policy *cors.Policy, routes *http.ServeMux, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,X-Requested-With")
-		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
 			return
 		}
+		w.Header().Add("Vary", "Origin")
+		allowOrigin, allowed := policy.AllowOrigin(origin)
 
-		next.ServeHTTP(w, r)
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || requestedMethod == "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+				policy.SetResponseHeaders(w.Header())
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !allowed {
+			writeError(w, http.StatusForbidden, "Origin not allowed", "cross-origin requests from "+origin+" are not allowed")
+			return
+		}
+		probe := r.Clone(r.Context())
+		probe.Method = requestedMethod
+		if h, pattern := routes.Handler(probe); pattern == "" {
+			rec := &probeWriter{header: http.Header{}}
+			h.ServeHTTP(rec, probe)
+			if rec.status == http.StatusMethodNotAllowed {
+				w.Header().Set("Allow", rec.header.Get("Allow"))
+				writeError(w, http.StatusMethodNotAllowed, "Method not allowed", requestedMethod+" is not supported on "+r.URL.Path)
+				return
+			}
+			writeError(w, http.StatusNotFound, "Not found", "no route for "+requestedMethod+" "+r.URL.Path)
+			return
+		}
+		if !policy.AllowsMethod(requestedMethod) {
+			writeError(w, http.StatusForbidden, "Method not allowed", requestedMethod+" is not allowed for cross-origin requests")
+			return
+		}
+		if requested := r.Header.Get("Access-Control-Request-Headers"); !policy.AllowsHeaders(requested) {
+			writeError(w, http.StatusForbidden, "Headers not allowed", "cross-origin requests may not send "+requested)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		policy.SetPreflightHeaders(w.Header())
+		w.WriteHeader(http.StatusNoContent)
 	})
 }
 
+// probeWriter records the status and headers of a handler whose response
+// is not sent.
+type probeWriter struct {
+	header http.Header
+	status int
+}
+
+func (p *probeWriter) Header() http.Header { return p.header }
+
+func (p *probeWriter) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (p *probeWriter) WriteHeader(status int) {
+	if p.status == 0 {
+		p.status = status
+	}
+}
+
 func loggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
@@ -101,21 +250,42 @@ func loggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 		requestID := requestIDFromContext(r.Context())
-		logger.Info("request completed",
+		fields := []zap.Field{
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.Int("status", rec.status),
 			zap.Duration("duration", duration),
 			zap.String("request_id", requestID),
-		)
+		}
+		if identity, ok := ClientIdentityFromContext(r.Context()); ok {
+			fields = append(fields, zap.String("client", identity.Subject))
+		}
+		logger.Info("request completed", fields...)
+	})
+}
+
+// requestLoggerMiddleware attaches a child of logger to the request context
+// carrying the request ID, the matched route and the actor, so that every
+// line logged while handling the request can be correlated (see
+// logging.FromContext).
+func requestLoggerMiddleware(logger *zap.Logger, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := []zap.Field{zap.String("request_id", requestIDFromContext(r.Context()))}
+		if _, route := mux.Handler(r); route != "" {
+			fields = append(fields, zap.String("route", route))
+		}
+		fields = append(fields, zap.String("actor", actorFromRequest(r)))
+
+		ctx := logging.NewContext(r.Context(), logger.With(fields...))
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func recoveryMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
+func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if rec := recover(); rec != nil {
-				logger.Error("panic recovered", zap.Any("error", rec))
+				logging.FromContext(r.Context()).Error("panic recovered", zap.Any("error", rec), zap.Stack("stack"))
 				writeError(w, http.StatusInternalServerError, "Internal error", "unexpected server error")
 			}
 		}()
@@ -4,11 +4,18 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/cors"
+	"github.com/eugenenazirov/re-partners/internal/logging"
 	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestLoggingMiddleware(t *testing.T) {
@@ -33,12 +40,13 @@ func TestLoggingMiddleware(t *testing.T) {
 }
 
 func TestRecoveryMiddleware(t *testing.T) {
-	logger := zaptest.NewLogger(t)
-	handler := recoveryMiddleware(logger, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := recoveryMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
 		panic(errors.New("boom"))
 	}))
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logging.NewContext(req.Context(), zap.New(core)))
 	rec := httptest.NewRecorder()
 
 	handler.ServeHTTP(rec, req)
@@ -46,6 +54,39 @@ func TestRecoveryMiddleware(t *testing.T) {
 	if rec.Code != http.StatusInternalServerError {
 		t.Fatalf("expected 500 after panic, got %d", rec.Code)
 	}
+	entries := logs.FilterMessage("panic recovered").All()
+	if len(entries) != 1 || !strings.Contains(entries[0].ContextMap()["stack"].(string), "TestRecoveryMiddleware") {
+		t.Fatalf("expected the panic to be logged with its stack, got %+v", entries)
+	}
+}
+
+func TestRouterAttachesRequestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	calc := calculator.New()
+	router := NewRouter(NewHandler(calc, storage.NewMemoryStorage()), zap.New(core), WithLogging(false))
+
+	req := httptest.NewRequest(http.MethodPut, "/api/pack-sizes", strings.NewReader(`{"packSizes":[23,31,53]}`))
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Actor", "alice")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/calculate", strings.NewReader(`{"items":500000}`))
+	req.Header.Set("X-Request-ID", "req-2")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	replaced := logs.FilterMessage("pack sizes replaced").All()
+	if len(replaced) != 1 {
+		t.Fatalf("expected the storage to log the change, got %d entries", len(replaced))
+	}
+	fields := replaced[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["route"] != "PUT /api/pack-sizes" || fields["actor"] != "alice" {
+		t.Fatalf("unexpected storage log fields %v", fields)
+	}
+
+	grown := logs.FilterMessage("dp table grown").All()
+	if len(grown) != 1 || grown[0].ContextMap()["request_id"] != "req-2" || grown[0].ContextMap()["route"] != "POST /api/calculate" {
+		t.Fatalf("expected the calculator to log with the request fields, got %+v", grown)
+	}
 }
 
 func TestResponseRecorderWriteHeader(t *testing.T) {
@@ -111,3 +152,101 @@ func newTestRouter(t *testing.T, opts ...RouterOption) http.Handler {
 	logger := zaptest.NewLogger(t)
 	return NewRouter(handler, logger, opts...)
 }
+
+func TestRouterWritesAccessLogsToAccessLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zaptest.NewLogger(t),
+		WithRateLimit(0, 0),
+		WithAccessLogger(zap.New(core)),
+	)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if logs.FilterMessage("request completed").Len() != 1 {
+		t.Fatalf("expected the access log on the access logger, got %v", logs.All())
+	}
+}
+
+func newCORSRouter(t *testing.T, cfg cors.Config) http.Handler {
+	t.Helper()
+	policy, err := cors.New(cfg)
+	if err != nil {
+		t.Fatalf("cors.New returned error: %v", err)
+	}
+	return NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zaptest.NewLogger(t), WithLogging(false), WithCORS(policy))
+}
+
+func corsRequest(router http.Handler, method, path, origin string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflightChecksOriginRouteMethodAndHeaders(t *testing.T) {
+	router := newCORSRouter(t, cors.Config{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.partners.example"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type", "X-Actor"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+
+	rec := corsRequest(router, http.MethodOptions, "/api/calculate", "https://shop.partners.example",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "content-type, x-actor")
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://shop.partners.example" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Max-Age") != "3600" ||
+		h.Get("Access-Control-Allow-Methods") != "GET,POST" {
+		t.Fatalf("unexpected preflight response %d %v", rec.Code, h)
+	}
+
+	cases := []struct {
+		name    string
+		path    string
+		origin  string
+		method  string
+		headers string
+		want    int
+	}{
+		{"unknown origin", "/api/calculate", "https://evil.example", "POST", "", http.StatusForbidden},
+		{"wildcard does not match the domain itself", "/api/calculate", "https://partners.example", "POST", "", http.StatusForbidden},
+		{"unknown route", "/api/missing", "https://app.example.com", "POST", "", http.StatusNotFound},
+		{"unregistered method", "/api/calculate", "https://app.example.com", "DELETE", "", http.StatusMethodNotAllowed},
+		{"method not allowed by policy", "/api/pack-sizes", "https://app.example.com", "PUT", "", http.StatusForbidden},
+		{"header not allowed", "/api/calculate", "https://app.example.com", "POST", "X-Secret", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		rec := corsRequest(router, http.MethodOptions, tc.path, tc.origin,
+			"Access-Control-Request-Method", tc.method, "Access-Control-Request-Headers", tc.headers)
+		if rec.Code != tc.want || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("%s: expected %d without CORS headers, got %d %v", tc.name, tc.want, rec.Code, rec.Header())
+		}
+	}
+}
+
+func TestCORSResponseHeaders(t *testing.T) {
+	router := newCORSRouter(t, cors.Config{AllowedOrigins: []string{"https://app.example.com"}, ExposedHeaders: ExposedHeaders})
+
+	rec := corsRequest(router, http.MethodGet, "/api/health", "https://app.example.com")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Fatalf("expected CORS headers for an allowed origin, got %v", rec.Header())
+	}
+	if rec.Header().Get("Vary") != "Origin" {
+		t.Fatalf("expected responses to vary by origin, got %q", rec.Header().Get("Vary"))
+	}
+
+	rec = corsRequest(router, http.MethodGet, "/api/health", "https://evil.example")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected no CORS headers for another origin, got %d %v", rec.Code, rec.Header())
+	}
+
+	// Without a policy, only same-origin requests are allowed.
+	rec = corsRequest(newTestRouter(t, WithLogging(false)), http.MethodOptions, "/api/calculate", "https://app.example.com", "Access-Control-Request-Method", "POST")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected cross-origin requests to be disallowed by default, got %v", rec.Header())
+	}
+}
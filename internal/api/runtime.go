@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RuntimeSettings are the router settings that can change while the server
+// runs.
+type RuntimeSettings struct {
+	EnableLogging bool
+	// RateLimitRPS and RateLimitBurst configure the token bucket; a
+	// non-positive value disables rate limiting.
+	RateLimitRPS   float64
+	RateLimitBurst int
+	// WriteTimeout bounds how long writing a response may take; zero means
+	// no limit.
+	WriteTimeout time.Duration
+}
+
+type runtimeState struct {
+	settings RuntimeSettings
+	limiter  rateLimiter
+}
+
+// Runtime holds the current RuntimeSettings. Requests read them without
+// locking, and Apply swaps them in one step, so a request never observes a
+// mix of old and new settings.
+type Runtime struct {
+	mu    sync.Mutex
+	state atomic.Pointer[runtimeState]
+}
+
+// NewRuntime creates a Runtime with the provided settings.
+func NewRuntime(settings RuntimeSettings) *Runtime {
+	rt := &Runtime{}
+	rt.state.Store(&runtimeState{
+		settings: settings,
+		limiter:  newRateLimiter(settings.RateLimitRPS, settings.RateLimitBurst),
+	})
+	return rt
+}
+
+// Settings returns the settings currently in effect.
+func (rt *Runtime) Settings() RuntimeSettings {
+	return rt.state.Load().settings
+}
+
+// Apply replaces the settings. The rate limiter is rebuilt only when its rate
+// or burst changed, so unrelated changes keep the tokens already spent.
+func (rt *Runtime) Apply(settings RuntimeSettings) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	current := rt.state.Load()
+	limiter := current.limiter
+	if settings.RateLimitRPS != current.settings.RateLimitRPS || settings.RateLimitBurst != current.settings.RateLimitBurst {
+		limiter = newRateLimiter(settings.RateLimitRPS, settings.RateLimitBurst)
+	}
+	rt.state.Store(&runtimeState{settings: settings, limiter: limiter})
+}
+
+// rateLimitState reports the rate limiter currently in effect.
+func (rt *Runtime) rateLimitState() rateLimitStateResponse {
+	state := rt.state.Load()
+	resp := rateLimitStateResponse{
+		RPS:   state.settings.RateLimitRPS,
+		Burst: state.settings.RateLimitBurst,
+	}
+	if limiter, ok := state.limiter.(*limiterAdapter); ok && limiter != nil {
+		resp.Enabled = true
+		resp.Tokens = limiter.limiter.Tokens()
+	}
+	return resp
+}
+
+// WriteDeadline applies the current write timeout to every request it
+// wraps. Unlike http.Server.WriteTimeout, which the server reads without
+// synchronisation, it can change while connections are open.
+func (rt *Runtime) WriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if timeout := rt.Settings().WriteTimeout; timeout > 0 {
+			// Writers that cannot set deadlines, such as test recorders,
+			// serve the request without one.
+			_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middleware rate limits and logs requests according to the settings in
+// effect when each request arrives.
+func (rt *Runtime) middleware(logger *zap.Logger, next http.Handler) http.Handler {
+	logged := loggingMiddleware(logger, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := rt.state.Load()
+		if state.limiter != nil && !state.limiter.Allow() {
+			writeRateLimited(w)
+			return
+		}
+		if state.settings.EnableLogging {
+			logged.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func serveHealth(router http.Handler) int {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	return rec.Code
+}
+
+func TestRuntimeApplyChangesRateLimit(t *testing.T) {
+	rt := NewRuntime(RuntimeSettings{RateLimitRPS: 1, RateLimitBurst: 1})
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zap.NewNop(), WithRuntime(rt))
+
+	if code := serveHealth(router); code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", code)
+	}
+	if code := serveHealth(router); code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request to be limited, got %d", code)
+	}
+
+	rt.Apply(RuntimeSettings{})
+	for range 5 {
+		if code := serveHealth(router); code != http.StatusOK {
+			t.Fatalf("expected rate limiting to be disabled, got %d", code)
+		}
+	}
+}
+
+func TestRuntimeApplyKeepsLimiterWhenRateUnchanged(t *testing.T) {
+	rt := NewRuntime(RuntimeSettings{RateLimitRPS: 1, RateLimitBurst: 1})
+	before := rt.state.Load().limiter
+
+	rt.Apply(RuntimeSettings{RateLimitRPS: 1, RateLimitBurst: 1, EnableLogging: true})
+	if rt.state.Load().limiter != before {
+		t.Fatalf("expected the limiter to survive an unrelated change")
+	}
+	if !rt.Settings().EnableLogging {
+		t.Fatalf("expected the new settings to be in effect")
+	}
+}
+
+func TestRuntimeApplyTogglesLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	rt := NewRuntime(RuntimeSettings{})
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zap.New(core), WithRuntime(rt))
+
+	serveHealth(router)
+	if n := logs.FilterMessage("request completed").Len(); n != 0 {
+		t.Fatalf("expected no access logs while disabled, got %d", n)
+	}
+
+	rt.Apply(RuntimeSettings{EnableLogging: true})
+	serveHealth(router)
+	if n := logs.FilterMessage("request completed").Len(); n != 1 {
+		t.Fatalf("expected one access log after enabling, got %d", n)
+	}
+}
+
+func TestRuntimeConcurrentApply(t *testing.T) {
+	rt := NewRuntime(RuntimeSettings{RateLimitRPS: 1000, RateLimitBurst: 1000})
+	router := NewRouter(NewHandler(calculator.New(), storage.NewMemoryStorage()), zap.NewNop(), WithRuntime(rt))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				if i%2 == 0 {
+					rt.Apply(RuntimeSettings{RateLimitRPS: float64(1000 + j), RateLimitBurst: 1000, EnableLogging: j%2 == 0})
+					continue
+				}
+				serveHealth(router)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRuntimeWriteDeadlineServesWithoutDeadlineSupport(t *testing.T) {
+	rt := NewRuntime(RuntimeSettings{WriteTimeout: time.Second})
+	var called bool
+	handler := rt.WriteDeadline(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Fatalf("expected the wrapped handler to run")
+	}
+}
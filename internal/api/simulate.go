@@ -0,0 +1,275 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+)
+
+const (
+	// maxSimulationCandidates bounds the pack-size sets compared per request.
+	maxSimulationCandidates = 5
+	// maxSimulationQuantities bounds the orders simulated per set.
+	maxSimulationQuantities = 10000
+)
+
+// WithCalculatorOptions configures the private calculators used by
+// POST /api/simulate. Pass the options of the served calculator, such as
+// its default tie-break and table memory limit, so simulated distributions
+// match POST /api/calculate.
+func WithCalculatorOptions(opts ...calculator.Option) HandlerOption {
+	return func(h *Handler) {
+		h.calculatorOptions = opts
+	}
+}
+
+type simulateRequest struct {
+	Candidates []simulationCandidate `json:"candidates"`
+	Range      *quantityRange        `json:"range,omitempty"`
+	Quantities []int                 `json:"quantities,omitempty"`
+}
+
+type simulationCandidate struct {
+	Name      string `json:"name,omitempty"`
+	PackSizes []int  `json:"packSizes"`
+}
+
+type quantityRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	Step int `json:"step,omitempty"`
+}
+
+type simulateResponse struct {
+	Quantities int                  `json:"quantities"`
+	Current    simulatedSet         `json:"current"`
+	Candidates []simulatedCandidate `json:"candidates"`
+}
+
+type simulatedSet struct {
+	Name      string            `json:"name,omitempty"`
+	PackSizes []int             `json:"packSizes"`
+	Version   uint64            `json:"packSizeVersion,omitempty"`
+	Summary   simulationSummary `json:"summary"`
+}
+
+type simulatedCandidate struct {
+	simulatedSet
+	// Unchanged counts the orders packed exactly as with the current sizes.
+	Unchanged int              `json:"unchanged"`
+	Diff      []simulationDiff `json:"diff"`
+}
+
+type simulationSummary struct {
+	Orders         int     `json:"orders"`
+	Infeasible     int     `json:"infeasible"`
+	AveragePacks   float64 `json:"averagePacks"`
+	MaxPacks       int     `json:"maxPacks"`
+	TotalOvershoot int     `json:"totalOvershoot"`
+}
+
+// simulatedOrder is one order's distribution. It is nil for infeasible orders.
+type simulatedOrder struct {
+	Packs      map[string]int `json:"packs"`
+	TotalPacks int            `json:"totalPacks"`
+	Overshoot  int            `json:"overshoot"`
+}
+
+// simulationDiff is an order whose distribution changes with the candidate.
+type simulationDiff struct {
+	Items     int             `json:"items"`
+	Current   *simulatedOrder `json:"current"`
+	Candidate *simulatedOrder `json:"candidate"`
+	// PacksDelta is the candidate's pack count minus the current one, present
+	// when both can pack the order.
+	PacksDelta *int `json:"packsDelta,omitempty"`
+}
+
+// handleSimulate compares candidate pack-size sets with the stored one over
+// a set of quantities. Every set is solved with its own calculator, so the
+// stored sizes, the shared DP table, and the result cache are left alone.
+func (h *Handler) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	var req simulateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request", "unable to parse JSON payload")
+		return
+	}
+
+	quantities, err := req.quantities()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request", err.Error(),
+			fmt.Sprintf("Send either range or quantities with at most %d orders", maxSimulationQuantities))
+		return
+	}
+	candidates, failure, ok := req.normalizedCandidates()
+	if !ok {
+		writeFailure(w, failure)
+		return
+	}
+
+	snapshot, err := h.packSizeSnapshot()
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	currentOrders, currentSummary, err := h.simulateSet(ctx, snapshot.PackSizes, quantities)
+	if err != nil {
+		writeCalculationError(w, 0, err)
+		return
+	}
+
+	resp := simulateResponse{
+		Quantities: len(quantities),
+		Current:    simulatedSet{PackSizes: snapshot.PackSizes, Version: snapshot.Version, Summary: currentSummary},
+		Candidates: make([]simulatedCandidate, 0, len(candidates)),
+	}
+	for _, candidate := range candidates {
+		orders, summary, err := h.simulateSet(ctx, candidate.PackSizes, quantities)
+		if err != nil {
+			writeCalculationError(w, 0, err)
+			return
+		}
+		result := simulatedCandidate{
+			simulatedSet: simulatedSet{Name: candidate.Name, PackSizes: candidate.PackSizes, Summary: summary},
+			Diff:         []simulationDiff{},
+		}
+		for i, items := range quantities {
+			diff, changed := diffOrders(items, currentOrders[i], orders[i])
+			if !changed {
+				result.Unchanged++
+				continue
+			}
+			result.Diff = append(result.Diff, diff)
+		}
+		resp.Candidates = append(resp.Candidates, result)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// quantities expands the request into the list of orders to simulate.
+func (req simulateRequest) quantities() ([]int, error) {
+	switch {
+	case req.Range == nil && len(req.Quantities) == 0:
+		return nil, errors.New("range or quantities is required")
+	case req.Range != nil && len(req.Quantities) > 0:
+		return nil, errors.New("range and quantities are mutually exclusive")
+	case req.Range == nil:
+		if len(req.Quantities) > maxSimulationQuantities {
+			return nil, fmt.Errorf("quantities must contain at most %d orders", maxSimulationQuantities)
+		}
+		for _, items := range req.Quantities {
+			if items <= 0 {
+				return nil, errors.New("quantities must be positive integers")
+			}
+		}
+		return req.Quantities, nil
+	}
+
+	rng := *req.Range
+	if rng.Step == 0 {
+		rng.Step = 1
+	}
+	if rng.From <= 0 || rng.To < rng.From || rng.Step < 0 {
+		return nil, errors.New("range must satisfy 0 < from <= to and step > 0")
+	}
+	if (rng.To-rng.From)/rng.Step+1 > maxSimulationQuantities {
+		return nil, fmt.Errorf("range must cover at most %d orders", maxSimulationQuantities)
+	}
+	// Computing each order from its index cannot overflow near math.MaxInt,
+	// unlike stepping past To.
+	quantities := make([]int, (rng.To-rng.From)/rng.Step+1)
+	for i := range quantities {
+		quantities[i] = rng.From + i*rng.Step
+	}
+	return quantities, nil
+}
+
+// normalizedCandidates validates the candidate sets and sorts their sizes.
+func (req simulateRequest) normalizedCandidates() ([]simulationCandidate, Failure, bool) {
+	if len(req.Candidates) == 0 || len(req.Candidates) > maxSimulationCandidates {
+		return nil, Failure{
+			Status:  http.StatusBadRequest,
+			Error:   "Invalid request",
+			Details: fmt.Sprintf("candidates must contain between 1 and %d pack-size sets", maxSimulationCandidates),
+		}, false
+	}
+	normalized := make([]simulationCandidate, len(req.Candidates))
+	for i, candidate := range req.Candidates {
+		sizes, err := storage.NormalizePackSizes(candidate.PackSizes)
+		if err != nil {
+			return nil, PackSizesFailure(fmt.Errorf("candidates[%d]: %w", i, err)), false
+		}
+		normalized[i] = simulationCandidate{Name: candidate.Name, PackSizes: sizes}
+	}
+	return normalized, Failure{}, true
+}
+
+// simulateSet packs every quantity with sizes using a private calculator
+// configured like the served one. Its table grows to the largest quantity
+// once and answers the others; quantities that do not fit within the table
+// memory ceiling are rejected rather than solved one temporary table at a
+// time. Infeasible orders are reported as nil entries.
+func (h *Handler) simulateSet(ctx context.Context, sizes []int, quantities []int) ([]*simulatedOrder, simulationSummary, error) {
+	opts := append(slices.Clone(h.calculatorOptions), calculator.WithStrictTableLimit())
+	calc := calculator.New(opts...)
+	orders := make([]*simulatedOrder, len(quantities))
+	summary := simulationSummary{Orders: len(quantities)}
+	totalPacks := 0
+
+	for i, items := range quantities {
+		result, err := calculator.CalculateWithContext(ctx, calc, items, sizes)
+		if errors.Is(err, calculator.ErrCannotFulfill) {
+			summary.Infeasible++
+			continue
+		}
+		if err != nil {
+			return nil, simulationSummary{}, fmt.Errorf("simulate %d items: %w", items, err)
+		}
+
+		order := &simulatedOrder{Packs: make(map[string]int, len(result))}
+		packed := 0
+		for size, count := range result {
+			order.Packs[strconv.Itoa(size)] = count
+			order.TotalPacks += count
+			packed += size * count
+		}
+		order.Overshoot = packed - items
+		orders[i] = order
+
+		totalPacks += order.TotalPacks
+		summary.TotalOvershoot += order.Overshoot
+		summary.MaxPacks = max(summary.MaxPacks, order.TotalPacks)
+	}
+
+	if feasible := summary.Orders - summary.Infeasible; feasible > 0 {
+		summary.AveragePacks = float64(totalPacks) / float64(feasible)
+	}
+	return orders, summary, nil
+}
+
+// diffOrders reports whether the candidate packs items differently.
+func diffOrders(items int, current, candidate *simulatedOrder) (simulationDiff, bool) {
+	diff := simulationDiff{Items: items, Current: current, Candidate: candidate}
+	switch {
+	case current == nil && candidate == nil:
+		return diff, false
+	case current == nil || candidate == nil:
+		return diff, true
+	case maps.Equal(current.Packs, candidate.Packs):
+		return diff, false
+	}
+	delta := candidate.TotalPacks - current.TotalPacks
+	diff.PacksDelta = &delta
+	return diff, true
+}
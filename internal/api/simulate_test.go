@@ -0,0 +1,183 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+func postSimulate(t *testing.T, router http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/simulate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSimulateComparesCandidates(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := postSimulate(t, router, `{
+		"candidates": [{"name": "no-250", "packSizes": [5000, 500, 1000, 2000]}],
+		"range": {"from": 250, "to": 1500, "step": 250}
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body simulateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Quantities != 6 {
+		t.Fatalf("expected 6 quantities, got %d", body.Quantities)
+	}
+
+	current := body.Current.Summary
+	if current.Infeasible != 0 || current.MaxPacks != 2 || current.AveragePacks != 1.5 || body.Current.Version != 1 {
+		t.Fatalf("unexpected current summary %+v (version %d)", current, body.Current.Version)
+	}
+
+	if len(body.Candidates) != 1 {
+		t.Fatalf("expected one candidate, got %d", len(body.Candidates))
+	}
+	candidate := body.Candidates[0]
+	if candidate.Name != "no-250" || candidate.PackSizes[0] != 500 {
+		t.Fatalf("expected normalised candidate sizes, got %+v", candidate.simulatedSet)
+	}
+	if candidate.Summary.Infeasible != 3 || candidate.Summary.MaxPacks != 2 || candidate.Summary.TotalOvershoot != 0 {
+		t.Fatalf("unexpected candidate summary %+v", candidate.Summary)
+	}
+	if candidate.Unchanged != 3 || len(candidate.Diff) != 3 {
+		t.Fatalf("expected 3 unchanged orders and 3 diffs, got %d and %d", candidate.Unchanged, len(candidate.Diff))
+	}
+	first := candidate.Diff[0]
+	if first.Items != 250 || first.Current == nil || first.Candidate != nil || first.PacksDelta != nil {
+		t.Fatalf("expected 250 to become infeasible, got %+v", first)
+	}
+}
+
+func TestSimulateReportsPackDelta(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := postSimulate(t, router, `{"candidates": [{"packSizes": [250, 500, 750]}], "quantities": [750, 1000]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body simulateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	diff := body.Candidates[0].Diff
+	if len(diff) != 2 {
+		t.Fatalf("expected two diffs, got %+v", diff)
+	}
+	if diff[0].Items != 750 || diff[0].PacksDelta == nil || *diff[0].PacksDelta != -1 || diff[0].Candidate.Packs["750"] != 1 {
+		t.Fatalf("unexpected diff for 750: %+v", diff[0])
+	}
+	if diff[1].Items != 1000 || diff[1].PacksDelta == nil || *diff[1].PacksDelta != 1 {
+		t.Fatalf("unexpected diff for 1000: %+v", diff[1])
+	}
+}
+
+func TestSimulateLeavesLiveConfigurationAlone(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	cache := calculator.NewCache(calculator.New(), calculator.CacheConfig{MaxEntries: 100})
+	router := NewRouter(NewHandler(cache, store), zaptest.NewLogger(t), WithLogging(false))
+
+	rec := postSimulate(t, router, `{"candidates": [{"packSizes": [23, 31, 53]}], "range": {"from": 1, "to": 500}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if snapshot := store.Snapshot(); snapshot.Version != 1 || len(snapshot.PackSizes) != 5 {
+		t.Fatalf("expected stored sizes to be untouched, got %+v", snapshot)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Misses != 0 {
+		t.Fatalf("expected the result cache to be untouched, got %+v", stats)
+	}
+}
+
+func TestSimulateValidation(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing quantities", `{"candidates": [{"packSizes": [1]}]}`, "range or quantities is required"},
+		{"both quantities", `{"candidates": [{"packSizes": [1]}], "quantities": [1], "range": {"from": 1, "to": 2}}`, "mutually exclusive"},
+		{"bad range", `{"candidates": [{"packSizes": [1]}], "range": {"from": 5, "to": 2}}`, "range must satisfy"},
+		{"too many", `{"candidates": [{"packSizes": [1]}], "range": {"from": 1, "to": 20000}}`, "at most 10000"},
+		{"bad quantity", `{"candidates": [{"packSizes": [1]}], "quantities": [0]}`, "positive integers"},
+		{"no candidates", `{"candidates": [], "quantities": [1]}`, "between 1 and 5"},
+		{"bad sizes", `{"candidates": [{"packSizes": [1]}, {"packSizes": [-1]}], "quantities": [1]}`, "candidates[1]"},
+		{"bad json", `{`, "unable to parse"},
+	}
+	for _, tc := range tests {
+		rec := postSimulate(t, router, tc.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", tc.name, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), tc.want) {
+			t.Fatalf("%s: expected body to mention %q, got %s", tc.name, tc.want, rec.Body.String())
+		}
+	}
+}
+
+func TestSimulateRangeNearMaxInt(t *testing.T) {
+	req := simulateRequest{Range: &quantityRange{From: math.MaxInt - 1, To: math.MaxInt}}
+	quantities, err := req.quantities()
+	if err != nil {
+		t.Fatalf("quantities returned error: %v", err)
+	}
+	if len(quantities) != 2 || quantities[0] != math.MaxInt-1 || quantities[1] != math.MaxInt {
+		t.Fatalf("expected the two largest quantities, got %v", quantities)
+	}
+}
+
+func TestSimulateRejectsQuantitiesAboveTableLimit(t *testing.T) {
+	opts := []calculator.Option{calculator.WithMaxTableBytes(10_000)}
+	handler := NewHandler(calculator.New(opts...), storage.NewMemoryStorage(), WithCalculatorOptions(opts...))
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false))
+
+	for _, body := range []string{
+		`{"candidates": [{"packSizes": [23, 31, 53]}], "quantities": [500, 500000]}`,
+		`{"candidates": [{"packSizes": [23, 31, 53]}], "range": {"from": 9223372036854775806, "to": 9223372036854775807}}`,
+	} {
+		rec := postSimulate(t, router, body)
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "Order too large") {
+			t.Fatalf("expected 422 Order too large, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSimulateUsesCalculatorOptions(t *testing.T) {
+	opts := []calculator.Option{calculator.WithDefaultTieBreak(calculator.TieBreakLargerPacks)}
+	store := storage.NewMemoryStorage()
+	if err := store.SetPackSizes([]int{1, 2, 3}); err != nil {
+		t.Fatalf("SetPackSizes returned error: %v", err)
+	}
+	handler := NewHandler(calculator.New(opts...), store, WithCalculatorOptions(opts...))
+	router := NewRouter(handler, zaptest.NewLogger(t), WithLogging(false))
+
+	rec := postSimulate(t, router, `{"candidates": [{"packSizes": [1, 2]}], "quantities": [4]}`)
+	var body simulateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	diff := body.Candidates[0].Diff
+	if len(diff) != 1 || diff[0].Current.Packs["1"] != 1 || diff[0].Current.Packs["3"] != 1 {
+		t.Fatalf("expected the current sizes to follow the configured tie-break, got %+v", diff)
+	}
+}
@@ -1,17 +1,30 @@
 package application
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"net"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/eugenenazirov/re-partners/internal/api"
 	"github.com/eugenenazirov/re-partners/internal/calculator"
 	"github.com/eugenenazirov/re-partners/internal/config"
+	"github.com/eugenenazirov/re-partners/internal/cors"
+	"github.com/eugenenazirov/re-partners/internal/history"
+	"github.com/eugenenazirov/re-partners/internal/httpcompress"
+	"github.com/eugenenazirov/re-partners/internal/jobs"
+	"github.com/eugenenazirov/re-partners/internal/listener"
+	"github.com/eugenenazirov/re-partners/internal/logging"
 	"github.com/eugenenazirov/re-partners/internal/storage"
+	"github.com/eugenenazirov/re-partners/internal/tlsconfig"
+	"github.com/eugenenazirov/re-partners/internal/webui"
+	"github.com/eugenenazirov/re-partners/web"
 	"go.uber.org/zap"
 )
 
@@ -20,77 +33,370 @@ type App struct {
 	storage    storage.Storage
 	calculator calculator.Calculator
 	handler    *api.Handler
+	jobs       *jobs.Manager
 	router     http.Handler
 	logger     *zap.Logger
 	server     *http.Server
+	runtime    *api.Runtime
+	// adminServer serves the admin endpoints on their own listeners; nil
+	// when they share the API listeners or are disabled.
+	adminServer *http.Server
+	// listen and adminListen are the addresses Start listens on.
+	listen      []listener.Address
+	adminListen []listener.Address
+	socketMode  fs.FileMode
+	// tls serves the certificates when HTTPS is enabled; nil otherwise.
+	tls *tlsconfig.Reloader
+
+	// logLevel is the level of logger, when it can be changed.
+	logLevel *zap.AtomicLevel
+
+	// requests is the base context of every request; cancelling it stops
+	// in-flight calculations once the shutdown grace period expires.
+	requests       context.Context
+	cancelRequests context.CancelFunc
+	drainDelay     time.Duration
+	gracePeriod    time.Duration
+	// serveErrs receives the errors of listeners that stop unexpectedly.
+	serveErrs chan error
+
+	// reloadMu serialises Reload; cfg is the configuration in effect.
+	reloadMu sync.Mutex
+	cfg      config.Config
+}
+
+// reloadableSettings are the settings Reload applies to a running server.
+// Changes to any other setting are logged and take effect after a restart.
+var reloadableSettings = map[string]bool{
+	"pack_sizes":             true,
+	"write_timeout":          true,
+	"enable_request_logging": true,
+	"rate_limit.rps":         true,
+	"rate_limit.burst":       true,
+	"log.level":              true,
+}
+
+// Option configures New.
+type Option func(*App)
+
+// WithLogLevel passes the level controlling the logger given to New, so
+// that the admin endpoints and Reload can change it.
+func WithLogLevel(level zap.AtomicLevel) Option {
+	return func(a *App) {
+		a.logLevel = &level
+	}
 }
 
 // New initializes the application with all dependencies from the provided configuration.
-func New(cfg config.Config, logger *zap.Logger) (*App, error) {
+func New(cfg config.Config, logger *zap.Logger, opts ...Option) (*App, error) {
+	tlsReloader, err := newTLSReloader(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificates: %w", err)
+	}
+	listen, adminListen, socketMode, err := listenAddresses(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+	corsPolicy, err := cors.New(cors.Config{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		ExposedHeaders:   api.ExposedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid CORS policy: %w", err)
+	}
+	if _, set := cfg.Sources["cors.allowed_origins"]; !set && !corsPolicy.Enabled() {
+		// Earlier versions allowed every origin; say so instead of leaving
+		// browser clients on other origins to fail silently.
+		logger.Warn(`cross-origin requests are refused; list the origins in cors.allowed_origins, or "*" for the previous behaviour`)
+	}
+
 	store := storage.NewMemoryStorage()
 	if err := store.SetPackSizes(cfg.InitialPackSizes); err != nil {
 		return nil, fmt.Errorf("failed to apply initial pack sizes: %w", err)
 	}
 
-	calc := calculator.New()
-	handler := api.NewHandler(calc, store)
+	calcOpts := []calculator.Option{
+		calculator.WithMaxTableBytes(cfg.DPTableMaxBytes),
+		calculator.WithDefaultTieBreak(calculator.TieBreakPolicy(cfg.TieBreak)),
+	}
+	calc := calculator.New(calcOpts...)
+	if warmer, ok := calc.(calculator.Warmer); ok {
+		if err := warmer.Warm(context.Background(), cfg.InitialPackSizes); err != nil {
+			return nil, fmt.Errorf("failed to prepare calculator: %w", err)
+		}
+		store.Subscribe(newTableWarmer(warmer, logger).request)
+	}
+	if cfg.CacheMaxEntries > 0 {
+		cache := calculator.NewCache(calc, calculator.CacheConfig{
+			MaxEntries: cfg.CacheMaxEntries,
+			MaxBytes:   cfg.CacheMaxBytes,
+		})
+		store.Subscribe(func(storage.Snapshot) {
+			cache.Purge()
+		})
+		calc = cache
+	}
+
+	handlerOpts := []api.HandlerOption{api.WithCalculatorOptions(calcOpts...)}
+	var jobManager *jobs.Manager
+	if cfg.JobWorkers > 0 {
+		jobManager = jobs.NewManager(calc, jobs.Config{
+			Workers:   cfg.JobWorkers,
+			QueueSize: cfg.JobQueueSize,
+			Retention: cfg.JobRetention,
+		})
+		handlerOpts = append(handlerOpts, api.WithJobs(jobManager))
+	}
+	if cfg.HistoryMaxEntries > 0 {
+		handlerOpts = append(handlerOpts, api.WithHistory(history.NewMemoryStore(cfg.HistoryMaxEntries)))
+	}
+
+	handler := api.NewHandler(calc, store, handlerOpts...)
+	runtime := api.NewRuntime(runtimeSettings(cfg))
 	apiRouter := api.NewRouter(handler, logger,
-		api.WithLogging(cfg.EnableRequestLogging),
-		api.WithRateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst),
+		api.WithRuntime(runtime),
+		api.WithAccessLogger(logging.Sampled(logger, cfg.LogSampleInitial, cfg.LogSampleThereafter)),
+		api.WithIdempotency(cfg.IdempotencyTTL),
+		api.WithCORS(corsPolicy),
+		api.WithCalculationsToken(cfg.AdminToken),
 	)
 
-	rootHandler, err := BuildRootHandler(apiRouter)
+	rootHandler, err := BuildRootHandler(apiRouter, cfg.WebDir)
 	if err != nil {
+		if jobManager != nil {
+			jobManager.Close()
+		}
 		return nil, fmt.Errorf("failed to build HTTP handler: %w", err)
 	}
 
+	requests, cancelRequests := context.WithCancel(context.Background())
+	app := &App{
+		storage:     store,
+		calculator:  calc,
+		handler:     handler,
+		jobs:        jobManager,
+		router:      apiRouter,
+		logger:      logger,
+		runtime:     runtime,
+		tls:         tlsReloader,
+		cfg:         cfg,
+		listen:      listen,
+		adminListen: adminListen,
+		socketMode:  socketMode,
+
+		requests:       requests,
+		cancelRequests: cancelRequests,
+		drainDelay:     cfg.ShutdownDrainDelay,
+		gracePeriod:    cfg.ShutdownGracePeriod,
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+
+	if cfg.AdminToken != "" {
+		adminOpts := []api.AdminOption{api.WithEffectiveConfig(app.effectiveConfig)}
+		if app.logLevel != nil {
+			adminOpts = append(adminOpts, api.WithLogLevel(*app.logLevel))
+		}
+		if cfg.AdminDiagnostics && len(adminListen) > 0 {
+			adminOpts = append(adminOpts, api.WithDiagnostics(handler, runtime))
+		}
+		adminRouter := api.NewAdminRouter(cfg.AdminToken, logger, adminOpts...)
+		if len(adminListen) > 0 {
+			app.adminServer = &http.Server{
+				Handler:           runtime.WriteDeadline(httpcompress.Handler(adminRouter, cfg.CompressionMinSize)),
+				ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+				IdleTimeout:       cfg.IdleTimeout,
+				BaseContext:       app.baseContext,
+			}
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("/admin/", adminRouter)
+			mux.Handle("/", rootHandler)
+			rootHandler = mux
+		}
+	}
+
 	addr := cfg.Port
 	if !strings.Contains(addr, ":") {
 		addr = ":" + addr
 	}
 
-	server := &http.Server{
+	// The write timeout is applied per request by the runtime so that
+	// Reload can change it.
+	app.server = &http.Server{
 		Addr:              addr,
-		Handler:           rootHandler,
+		Handler:           runtime.WriteDeadline(httpcompress.Handler(rootHandler, cfg.CompressionMinSize)),
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
-		WriteTimeout:      cfg.WriteTimeout,
 		IdleTimeout:       cfg.IdleTimeout,
+		BaseContext:       app.baseContext,
+	}
+	if tlsReloader != nil {
+		app.server.TLSConfig = tlsReloader.TLSConfig()
+		if app.adminServer != nil {
+			app.adminServer.TLSConfig = tlsReloader.TLSConfig()
+		}
 	}
 
-	return &App{
-		storage:    store,
-		calculator: calc,
-		handler:    handler,
-		router:     apiRouter,
-		logger:     logger,
-		server:     server,
-	}, nil
+	return app, nil
 }
 
-// BuildRootHandler constructs the root HTTP handler that serves static files and routes API requests.
-func BuildRootHandler(apiHandler http.Handler) (http.Handler, error) {
-	mux := http.NewServeMux()
+func (a *App) baseContext(net.Listener) context.Context {
+	return a.requests
+}
 
-	staticPath, err := resolveProjectPath(filepath.Join("web", "static"))
+// listenAddresses parses the API and admin listen addresses. The API
+// listens on the port when no addresses are configured.
+func listenAddresses(cfg config.Config) (listen, adminListen []listener.Address, socketMode fs.FileMode, err error) {
+	raw := cfg.Listen
+	if len(raw) == 0 {
+		raw = []string{cfg.Port}
+	}
+	for _, r := range raw {
+		address, err := listener.ParseAddress(r)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		listen = append(listen, address)
+	}
+	for _, r := range cfg.AdminListen {
+		address, err := listener.ParseAddress(r)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		adminListen = append(adminListen, address)
+	}
+
+	socketMode = listener.DefaultSocketMode
+	if cfg.UnixSocketMode != "" {
+		if socketMode, err = listener.ParseSocketMode(cfg.UnixSocketMode); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	return listen, adminListen, socketMode, nil
+}
+
+// newTLSReloader loads the configured certificates, or returns nil when
+// HTTPS is not enabled. Rotated certificates are picked up by later
+// handshakes; a rotation that cannot be loaded is logged and the previous
+// certificates stay in use.
+func newTLSReloader(cfg config.Config, logger *zap.Logger) (*tlsconfig.Reloader, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+	minVersion, err := tlsconfig.ParseVersion(cfg.TLSMinVersion)
 	if err != nil {
 		return nil, err
 	}
-	staticDir := http.Dir(staticPath)
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(staticDir)))
-	mux.Handle("/api/", apiHandler)
-
-	indexPath, err := resolveProjectPath(filepath.Join("web", "templates", "index.html"))
+	clientAuth, err := tlsconfig.ParseClientAuth(cfg.TLSClientAuth)
 	if err != nil {
 		return nil, err
 	}
-	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
+	return tlsconfig.New(tlsconfig.Config{
+		CertFile:     cfg.TLSCertFile,
+		KeyFile:      cfg.TLSKeyFile,
+		ClientCAFile: cfg.TLSClientCAFile,
+		MinVersion:   minVersion,
+		ClientAuth:   clientAuth,
+		OnReloadError: func(err error) {
+			logger.Warn("failed to reload TLS certificates, keeping the previous ones", zap.Error(err))
+		},
+	})
+}
+
+// effectiveConfig reports the configuration in effect, following reloads.
+func (a *App) effectiveConfig() []config.EffectiveSetting {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+	return a.cfg.Effective()
+}
+
+// runtimeSettings extracts the router settings that Reload can change.
+func runtimeSettings(cfg config.Config) api.RuntimeSettings {
+	return api.RuntimeSettings{
+		EnableLogging:  cfg.EnableRequestLogging,
+		RateLimitRPS:   cfg.RateLimitRPS,
+		RateLimitBurst: cfg.RateLimitBurst,
+		WriteTimeout:   cfg.WriteTimeout,
+	}
+}
+
+// Reload applies a new, already validated configuration to the running
+// server and returns what changed. TLS certificates are reloaded from their
+// files even when the configuration is unchanged. Request logging, rate limiting and the
+// write timeout are swapped in one step; pack sizes are replaced only when
+// the configured sizes changed, so sizes set through the API survive reloads
+// that do not touch them. If the new pack sizes are rejected the previous
+// configuration stays in effect.
+func (a *App) Reload(cfg config.Config) ([]config.Change, error) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	if a.tls != nil {
+		if err := a.tls.Reload(); err != nil {
+			a.logger.Warn("failed to reload TLS certificates, keeping the previous ones", zap.Error(err))
+		}
+	}
+
+	changes := config.Diff(a.cfg, cfg)
+	if len(changes) == 0 {
+		a.logger.Info("configuration reloaded, nothing changed")
+		return nil, nil
+	}
+
+	if !slices.Equal(a.cfg.InitialPackSizes, cfg.InitialPackSizes) {
+		if err := a.storage.SetPackSizes(cfg.InitialPackSizes); err != nil {
+			return nil, fmt.Errorf("apply pack sizes: %w", err)
 		}
-		http.ServeFile(w, r, indexPath)
-	}))
+	}
+	a.runtime.Apply(runtimeSettings(cfg))
+	// The level is set only when log.level changed, so a level set through
+	// the admin endpoint survives reloads that do not touch it.
+	if a.logLevel != nil && a.cfg.LogLevel != cfg.LogLevel {
+		if level, err := logging.ParseLevel(cfg.LogLevel); err == nil {
+			a.logLevel.SetLevel(level)
+		}
+	}
+
+	for _, change := range changes {
+		fields := []zap.Field{
+			zap.String("setting", change.Key),
+			zap.Any("old", change.Old),
+			zap.Any("new", change.New),
+		}
+		if reloadableSettings[change.Key] {
+			a.logger.Info("configuration changed", fields...)
+			continue
+		}
+		a.logger.Warn("configuration changed, restart required to apply", fields...)
+	}
+
+	a.cfg = cfg
+	return changes, nil
+}
+
+// BuildRootHandler constructs the root HTTP handler that serves the UI and
+// routes API requests. The UI is embedded in the binary unless webDir names
+// a directory to serve it from.
+func BuildRootHandler(apiHandler http.Handler, webDir string) (http.Handler, error) {
+	var ui http.Handler
+	var err error
+	if webDir != "" {
+		ui, err = webui.NewDir(webDir)
+	} else {
+		ui, err = webui.New(web.Files)
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	mux := http.NewServeMux()
+	mux.Handle("/api/", apiHandler)
+	mux.Handle("/", ui)
 	return mux, nil
 }
 
@@ -110,40 +416,165 @@ func NewServer(cfg config.Config, handler http.Handler) *http.Server {
 	}
 }
 
-// Start starts the HTTP server in a goroutine and logs the listening address.
+// Start opens every listener and serves them in the background. If any
+// listener cannot be opened, the ones already opened are closed and the
+// error is returned. Use Stop to shut the servers down, or Run to do both.
 func (a *App) Start() error {
-	go func() {
-		a.logger.Info("server listening", zap.String("addr", a.server.Addr))
-		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			a.logger.Fatal("server error", zap.Error(err))
+	opener, err := listener.NewOpener(a.socketMode)
+	if err != nil {
+		return err
+	}
+	// Inherited sockets that no address uses are released.
+	defer opener.Close()
+
+	type binding struct {
+		server  *http.Server
+		address listener.Address
+		ln      net.Listener
+	}
+	var bindings []binding
+	open := func(server *http.Server, addresses []listener.Address) error {
+		for _, address := range addresses {
+			ln, err := opener.Open(address)
+			if err != nil {
+				return fmt.Errorf("listen on %s: %w", address, err)
+			}
+			bindings = append(bindings, binding{server: server, address: address, ln: ln})
 		}
-	}()
+		return nil
+	}
+	err = open(a.server, a.listen)
+	if err == nil && a.adminServer != nil {
+		err = open(a.adminServer, a.adminListen)
+	}
+	if err != nil {
+		for _, b := range bindings {
+			b.ln.Close()
+		}
+		return err
+	}
+
+	a.serveErrs = make(chan error, len(bindings))
+	for _, b := range bindings {
+		name := "api"
+		if b.server == a.adminServer {
+			name = "admin"
+		}
+		go func() {
+			a.logger.Info("server listening",
+				zap.String("listener", name),
+				zap.String("addr", b.ln.Addr().String()),
+				zap.String("network", b.address.Network),
+				zap.Bool("tls", a.tls != nil),
+			)
+			var err error
+			if a.tls != nil {
+				err = b.server.ServeTLS(b.ln, "", "")
+			} else {
+				err = b.server.Serve(b.ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.logger.Error("server error", zap.String("listener", name), zap.Error(err))
+				a.serveErrs <- fmt.Errorf("serve %s on %s: %w", name, b.address, err)
+			}
+		}()
+	}
 	return nil
 }
 
-// Server returns the HTTP server instance for shutdown handling.
-func (a *App) Server() *http.Server {
-	return a.server
-}
+// Run starts the application and blocks until ctx is done or a listener
+// fails, then stops it gracefully, allowing the drain delay and the shutdown
+// grace period. It returns the listener's error, if any, joined with the
+// error of Stop.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(); err != nil {
+		_ = a.Close()
+		return err
+	}
 
-// resolveProjectPath locates a file or directory relative to the project root by walking up the directory tree.
-func resolveProjectPath(relative string) (string, error) {
-	dir, err := os.Getwd()
-	if err != nil {
-		return "", err
+	var serveErr error
+	select {
+	case <-ctx.Done():
+		a.logger.Info("shutting down server")
+	case serveErr = <-a.serveErrs:
+		a.logger.Error("shutting down after server error")
 	}
 
-	for {
-		candidate := filepath.Join(dir, relative)
-		if _, err := os.Stat(candidate); err == nil {
-			return candidate, nil
+	stopCtx, cancel := context.WithTimeout(context.Background(), a.drainDelay+a.gracePeriod)
+	defer cancel()
+	return errors.Join(serveErr, a.Stop(stopCtx))
+}
+
+// Stop shuts the application down gracefully. It switches readiness off
+// (GET /api/ready answers 503) and keeps serving for the drain delay, so
+// that load balancers stop routing new requests, and then stops accepting
+// connections and waits for in-flight requests and calculation jobs. If ctx
+// is done first, their contexts are cancelled, which stops running
+// calculations, and the remaining connections are closed; the context's
+// error is returned.
+func (a *App) Stop(ctx context.Context) error {
+	a.handler.Drain()
+	if a.drainDelay > 0 {
+		a.logger.Info("draining before shutdown", zap.Duration("delay", a.drainDelay))
+		timer := time.NewTimer(a.drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
 		}
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
+	}
+
+	var jobsErr error
+	var wg sync.WaitGroup
+	if a.jobs != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobsErr = a.jobs.Shutdown(ctx)
+		}()
+	}
+
+	err := a.server.Shutdown(ctx)
+	if a.adminServer != nil {
+		err = errors.Join(err, a.adminServer.Shutdown(ctx))
+	}
+	if err != nil {
+		a.logger.Warn("shutdown grace period expired, cancelling in-flight requests", zap.Error(err))
+		a.cancelRequests()
+		if closeErr := a.closeServers(); closeErr != nil {
+			a.logger.Error("forced close failed", zap.Error(closeErr))
 		}
-		dir = parent
 	}
+	wg.Wait()
+	a.cancelRequests()
+
+	if err == nil && jobsErr != nil {
+		a.logger.Warn("shutdown grace period expired, cancelled running jobs", zap.Error(jobsErr))
+		err = jobsErr
+	}
+	return err
+}
+
+// Close immediately closes the servers, cancelling in-flight requests and
+// jobs.
+func (a *App) Close() error {
+	a.cancelRequests()
+	err := a.closeServers()
+	if a.jobs != nil {
+		a.jobs.Close()
+	}
+	return err
+}
 
-	return "", fmt.Errorf("unable to locate %s", relative)
+func (a *App) closeServers() error {
+	err := a.server.Close()
+	if a.adminServer != nil {
+		err = errors.Join(err, a.adminServer.Close())
+	}
+	return err
+}
+
+// Server returns the HTTP server serving the API.
+func (a *App) Server() *http.Server {
+	return a.server
 }
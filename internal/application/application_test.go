@@ -1,14 +1,33 @@
 package application
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/eugenenazirov/re-partners/internal/api"
+	"github.com/eugenenazirov/re-partners/internal/calculator"
 	"github.com/eugenenazirov/re-partners/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewInitializesDependencies(t *testing.T) {
@@ -54,13 +73,62 @@ func TestNewServerAppliesConfig(t *testing.T) {
 	}
 }
 
-func TestResolveProjectPathFindsGoMod(t *testing.T) {
-	path, err := resolveProjectPath("go.mod")
+func TestNewServesEmbeddedUIFromAnyDirectory(t *testing.T) {
+	t.Chdir(t.TempDir())
+	app, err := New(baseTestConfig(":0"), zaptest.NewLogger(t))
 	if err != nil {
-		t.Fatalf("resolveProjectPath returned error: %v", err)
+		t.Fatalf("New returned error outside the repository: %v", err)
 	}
-	if _, err := os.Stat(path); err != nil {
-		t.Fatalf("expected go.mod to exist at %s: %v", path, err)
+	rec := httptest.NewRecorder()
+	app.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Order Packs Calculator") {
+		t.Fatalf("expected the embedded index page, got %d", rec.Code)
+	}
+
+	cfg := baseTestConfig(":0")
+	cfg.WebDir = t.TempDir()
+	if _, err := New(cfg, zaptest.NewLogger(t)); err == nil {
+		t.Fatalf("expected a web directory without the UI to be rejected")
+	}
+}
+
+func TestNewWarnsWhenCORSOriginsAreUnset(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	if _, err := New(baseTestConfig(":0"), zap.New(core)); err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if logs.FilterMessageSnippet("cors.allowed_origins").Len() != 1 {
+		t.Fatalf("expected a warning about the CORS origins, got %v", logs.All())
+	}
+
+	cfg := baseTestConfig(":0")
+	cfg.CORSAllowedOrigins = []string{"https://app.example.com"}
+	cfg.CORSAllowedMethods = []string{http.MethodGet}
+	cfg.Sources = map[string]config.Source{"cors.allowed_origins": config.SourceEnv}
+	core, logs = observer.New(zapcore.WarnLevel)
+	if _, err := New(cfg, zap.New(core)); err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("expected no warning with origins configured, got %v", logs.All())
+	}
+}
+
+func TestNewCompressesResponses(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	cfg.CompressionMinSize = 1024
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	for path, want := range map[string]string{"/": "gzip", "/api/health": ""} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		app.server.Handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != want {
+			t.Fatalf("%s: expected Content-Encoding %q, got %q", path, want, got)
+		}
 	}
 }
 
@@ -73,9 +141,389 @@ func TestNewReturnsErrorForInvalidPackSizes(t *testing.T) {
 	}
 }
 
-func TestResolveProjectPathUnknownTarget(t *testing.T) {
-	if _, err := resolveProjectPath("definitely-not-a-real-file"); err == nil {
-		t.Fatalf("expected error for missing resource")
+func TestNewPurgesCacheWhenPackSizesChange(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	cfg.CacheMaxEntries = 10
+
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	cache, ok := app.calculator.(*calculator.Cache)
+	if !ok {
+		t.Fatalf("expected calculator to be wrapped in a cache, got %T", app.calculator)
+	}
+	if _, err := cache.CalculatePacks(500, []int{250, 500}); err != nil {
+		t.Fatalf("CalculatePacks returned error: %v", err)
+	}
+	if err := app.storage.SetPackSizes([]int{100}); err != nil {
+		t.Fatalf("SetPackSizes returned error: %v", err)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("expected cache to be purged after pack sizes change, got %+v", stats)
+	}
+}
+
+func TestNewRebuildsTableWhenPackSizesChange(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	cfg.DPTableMaxBytes = 1 << 20
+
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := app.storage.SetPackSizes([]int{23, 31, 53}); err != nil {
+		t.Fatalf("SetPackSizes returned error: %v", err)
+	}
+
+	stats, ok := app.calculator.(interface{ TableStats() calculator.TableStats })
+	if !ok {
+		t.Fatalf("expected calculator to expose table stats, got %T", app.calculator)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.Equal(stats.TableStats().PackSizes, []int{23, 31, 53}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected table to be rebuilt for new pack sizes, got %v", stats.TableStats().PackSizes)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReloadAppliesRuntimeSettingsAndPackSizes(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	updated := cfg
+	updated.InitialPackSizes = []int{23, 31, 53}
+	updated.EnableRequestLogging = true
+	updated.RateLimitRPS = 5
+	updated.RateLimitBurst = 10
+	updated.WriteTimeout = time.Second
+	updated.IdleTimeout = time.Minute
+
+	changes, err := app.Reload(updated)
+	if err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if len(changes) != 6 {
+		t.Fatalf("expected 6 changes, got %+v", changes)
+	}
+	if sizes, _ := app.storage.GetPackSizes(); !slices.Equal(sizes, []int{23, 31, 53}) {
+		t.Fatalf("expected reloaded pack sizes, got %v", sizes)
+	}
+	want := api.RuntimeSettings{EnableLogging: true, RateLimitRPS: 5, RateLimitBurst: 10, WriteTimeout: time.Second}
+	if got := app.runtime.Settings(); got != want {
+		t.Fatalf("expected runtime settings %+v, got %+v", want, got)
+	}
+}
+
+func TestReloadKeepsPackSizesSetThroughAPI(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := app.storage.SetPackSizes([]int{100}); err != nil {
+		t.Fatalf("SetPackSizes returned error: %v", err)
+	}
+
+	updated := cfg
+	updated.RateLimitRPS = 5
+	if _, err := app.Reload(updated); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if sizes, _ := app.storage.GetPackSizes(); !slices.Equal(sizes, []int{100}) {
+		t.Fatalf("expected pack sizes set through the API to survive, got %v", sizes)
+	}
+}
+
+func TestReloadChangesLogLevelOnlyWhenConfigured(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	cfg.LogLevel = "info"
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	app, err := New(cfg, zaptest.NewLogger(t), WithLogLevel(level))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	// A level set through the admin endpoint survives unrelated reloads.
+	level.SetLevel(zapcore.DebugLevel)
+	updated := cfg
+	updated.RateLimitRPS = 5
+	if _, err := app.Reload(updated); err != nil || level.Level() != zapcore.DebugLevel {
+		t.Fatalf("expected the debug level to survive, got %s (%v)", level.Level(), err)
+	}
+
+	updated.LogLevel = "warn"
+	if _, err := app.Reload(updated); err != nil || level.Level() != zapcore.WarnLevel {
+		t.Fatalf("expected the configured warn level, got %s (%v)", level.Level(), err)
+	}
+}
+
+func TestReloadKeepsConfigurationWhenRejected(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	updated := cfg
+	updated.InitialPackSizes = []int{0}
+	updated.EnableRequestLogging = true
+	if _, err := app.Reload(updated); err == nil {
+		t.Fatalf("expected invalid pack sizes to be rejected")
+	}
+	if app.runtime.Settings().EnableLogging {
+		t.Fatalf("expected the runtime settings to be unchanged")
+	}
+	if sizes, _ := app.storage.GetPackSizes(); !slices.Equal(sizes, []int{250, 500}) {
+		t.Fatalf("expected the previous pack sizes, got %v", sizes)
+	}
+
+	// The rejected reload left nothing half applied, so reloading the
+	// original configuration reports no changes.
+	if changes, err := app.Reload(cfg); err != nil || len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v (%v)", changes, err)
+	}
+}
+
+func TestNewMountsAdminEndpointsWithToken(t *testing.T) {
+	cfg := baseTestConfig(":0")
+	cfg.AdminToken = "s3cret"
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	app.server.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "s3cret") {
+		t.Fatalf("expected a redacted configuration, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	cfg.AdminToken = ""
+	app, err = New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	app.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected admin endpoints to be disabled without a token, got %d", rec.Code)
+	}
+}
+
+func TestNewServesTLSWhenCertificateConfigured(t *testing.T) {
+	dir := t.TempDir()
+	cfg := baseTestConfig(":0")
+	cfg.TLSMinVersion = "1.3"
+	cfg.TLSClientAuth = "require"
+	cfg.TLSCertFile = filepath.Join(dir, "tls.crt")
+	cfg.TLSKeyFile = filepath.Join(dir, "tls.key")
+	if _, err := New(cfg, zaptest.NewLogger(t)); err == nil {
+		t.Fatalf("expected missing certificate files to be rejected")
+	}
+
+	writeSelfSignedCertificate(t, cfg.TLSCertFile, cfg.TLSKeyFile)
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if app.server.TLSConfig == nil || app.server.TLSConfig.MinVersion != tls.VersionTLS13 {
+		t.Fatalf("expected a TLS 1.3 server configuration, got %+v", app.server.TLSConfig)
+	}
+	if _, err := app.Reload(cfg); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+}
+
+func TestStartServesUnixSocketsAndAdminListener(t *testing.T) {
+	dir := t.TempDir()
+	apiSocket, adminSocket := filepath.Join(dir, "api.sock"), filepath.Join(dir, "admin.sock")
+	cfg := baseTestConfig(":0")
+	cfg.Listen = []string{"unix:" + apiSocket, "127.0.0.1:0"}
+	cfg.UnixSocketMode = "0600"
+	cfg.AdminToken = "s3cret"
+	cfg.AdminListen = []string{"unix:" + adminSocket}
+	cfg.AdminDiagnostics = true
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := app.Start(); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = app.Close() })
+
+	if info, err := os.Stat(apiSocket); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the API socket with mode 0600, got %v (%v)", info, err)
+	}
+	get := func(socket, path string) int {
+		t.Helper()
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}
+		req, _ := http.NewRequest(http.MethodGet, "http://unix"+path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s on %s: %v", path, socket, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := get(apiSocket, "/api/health"); code != http.StatusOK {
+		t.Fatalf("expected the API on its socket, got %d", code)
+	}
+	for _, path := range []string{"/admin/config", "/admin/runtime", "/admin/debug/pprof/"} {
+		if code := get(adminSocket, path); code != http.StatusOK {
+			t.Fatalf("expected %s on the admin socket, got %d", path, code)
+		}
+		if code := get(apiSocket, path); code != http.StatusNotFound {
+			t.Fatalf("expected no %s on the API socket, got %d", path, code)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := app.Stop(ctx); err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if _, err := os.Stat(apiSocket); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestStopDrainsThenCancelsInFlightRequests(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	cfg := baseTestConfig(":0")
+	cfg.Listen = []string{"unix:" + socket}
+	cfg.WriteTimeout = time.Second
+	cfg.ShutdownDrainDelay = 100 * time.Millisecond
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(_ http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})
+	mux.Handle("/", app.server.Handler)
+	app.server.Handler = mux
+	if err := app.Start(); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+		DisableKeepAlives: true,
+	}}
+	get := func(path string) int {
+		resp, err := client.Get("http://unix" + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("/api/ready"); code != http.StatusOK {
+		t.Fatalf("expected the server to be ready, got %d", code)
+	}
+	go get("/slow")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- app.Stop(ctx) }()
+
+	// Readiness is switched off while the server still accepts requests.
+	draining := false
+	for deadline := time.Now().Add(cfg.ShutdownDrainDelay); !draining && time.Now().Before(deadline); {
+		draining = get("/api/ready") == http.StatusServiceUnavailable
+	}
+	if !draining {
+		t.Fatalf("expected GET /api/ready to answer 503 during the drain delay")
+	}
+
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the grace period to expire, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Stop did not return")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the in-flight request to be cancelled")
+	}
+}
+
+func TestStartFailsWhenAddressIsTaken(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer taken.Close()
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	cfg := baseTestConfig(":0")
+	cfg.Listen = []string{"unix:" + socket, taken.Addr().String()}
+	app, err := New(cfg, zaptest.NewLogger(t))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := app.Start(); err == nil {
+		t.Fatalf("expected Start to fail on a taken address")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("expected the opened socket to be closed, got %v", err)
+	}
+}
+
+func writeSelfSignedCertificate(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
 	}
 }
 
@@ -0,0 +1,62 @@
+package application
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap"
+)
+
+// tableWarmer rebuilds the calculation table after pack sizes change. The
+// rebuilds run one at a time on a single goroutine and only the newest
+// snapshot is kept while one is running, so a slow rebuild for older sizes
+// can never replace the table for newer ones.
+type tableWarmer struct {
+	warmer calculator.Warmer
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	pending *storage.Snapshot
+	latest  uint64
+	running bool
+}
+
+func newTableWarmer(warmer calculator.Warmer, logger *zap.Logger) *tableWarmer {
+	return &tableWarmer{warmer: warmer, logger: logger}
+}
+
+// request schedules a rebuild for snapshot. Subscribers may be notified out
+// of order, so snapshots older than one already requested are ignored.
+func (t *tableWarmer) request(snapshot storage.Snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if snapshot.Version <= t.latest {
+		return
+	}
+	t.latest = snapshot.Version
+	t.pending = &snapshot
+	if !t.running {
+		t.running = true
+		go t.run()
+	}
+}
+
+func (t *tableWarmer) run() {
+	for {
+		t.mu.Lock()
+		snapshot := t.pending
+		t.pending = nil
+		if snapshot == nil {
+			t.running = false
+			t.mu.Unlock()
+			return
+		}
+		t.mu.Unlock()
+
+		if err := t.warmer.Warm(context.Background(), snapshot.PackSizes); err != nil {
+			t.logger.Warn("failed to rebuild calculation table", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"go.uber.org/zap/zaptest"
+)
+
+type recordingWarmer struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu     sync.Mutex
+	warmed [][]int
+}
+
+func (w *recordingWarmer) Warm(_ context.Context, packSizes []int) error {
+	w.started <- struct{}{}
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warmed = append(w.warmed, packSizes)
+	return nil
+}
+
+func (w *recordingWarmer) calls() [][]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.warmed)
+}
+
+func TestTableWarmerKeepsOnlyNewestSnapshot(t *testing.T) {
+	warmer := &recordingWarmer{started: make(chan struct{}, 4), release: make(chan struct{})}
+	tw := newTableWarmer(warmer, zaptest.NewLogger(t))
+
+	tw.request(storage.Snapshot{Version: 1, PackSizes: []int{1}})
+	<-warmer.started
+	tw.request(storage.Snapshot{Version: 3, PackSizes: []int{3}})
+	tw.request(storage.Snapshot{Version: 2, PackSizes: []int{2}})
+	tw.request(storage.Snapshot{Version: 4, PackSizes: []int{4}})
+	close(warmer.release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(warmer.calls()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	got := warmer.calls()
+	if len(got) != 2 || got[0][0] != 1 || got[1][0] != 4 {
+		t.Fatalf("expected the running and the newest rebuild only, got %v", got)
+	}
+}
@@ -0,0 +1,213 @@
+package calculator
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// cacheEntryOverhead approximates the bookkeeping bytes of a cache entry
+	// (list element, map slot, entry struct).
+	cacheEntryOverhead = 128
+	// cacheResultEntrySize approximates the bytes used by one pack size in a
+	// cached distribution map.
+	cacheResultEntrySize = 48
+)
+
+// CacheConfig bounds the size of a Cache.
+type CacheConfig struct {
+	// MaxEntries limits the number of cached results.
+	MaxEntries int
+	// MaxBytes limits the estimated memory used by cached results. A
+	// non-positive value disables the memory limit.
+	MaxBytes int64
+}
+
+// CacheStats reports cache effectiveness and occupancy.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type cacheEntry struct {
+	key    string
+	result map[int]int
+	err    error
+	size   int64
+}
+
+// Cache is a Calculator decorator that memoises results in an LRU keyed by
+// the normalised pack sizes, item count, and calculation mode.
+type Cache struct {
+	next       Calculator
+	maxEntries int
+	maxBytes   int64
+
+	mu        sync.Mutex
+	lru       *list.List
+	entries   map[string]*list.Element
+	bytes     int64
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// NewCache wraps next with an LRU result cache bounded by cfg.
+func NewCache(next Calculator, cfg CacheConfig) *Cache {
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &Cache{
+		next:       next,
+		maxEntries: maxEntries,
+		maxBytes:   cfg.MaxBytes,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// CalculatePacks returns a cached distribution when available and delegates to
+// the wrapped calculator otherwise.
+func (c *Cache) CalculatePacks(items int, packSizes []int) (map[int]int, error) {
+	result, _, err := c.CalculatePacksCached(context.Background(), items, packSizes)
+	return result, err
+}
+
+// CalculatePacksContext implements ContextCalculator.
+func (c *Cache) CalculatePacksContext(ctx context.Context, items int, packSizes []int) (map[int]int, error) {
+	result, _, err := c.CalculatePacksCached(ctx, items, packSizes)
+	return result, err
+}
+
+// CalculatePacksCached behaves like CalculatePacksContext and additionally
+// reports whether the result was served from the cache.
+func (c *Cache) CalculatePacksCached(ctx context.Context, items int, packSizes []int) (map[int]int, bool, error) {
+	normalized, err := normalizePackSizes(packSizes)
+	if err != nil || items < 0 {
+		result, calcErr := CalculateWithContext(ctx, c.next, items, packSizes)
+		return result, false, calcErr
+	}
+
+	key := cacheKey(normalized, items, ModeFromContext(ctx))
+	if entry, ok := c.get(key); ok {
+		return entry.result, true, entry.err
+	}
+
+	result, err := CalculateWithContext(ctx, c.next, items, normalized)
+	if err == nil || errors.Is(err, ErrCannotFulfill) {
+		c.add(key, result, err)
+	}
+	return result, false, err
+}
+
+// Explain delegates to the wrapped calculator; explanations are never cached.
+func (c *Cache) Explain(ctx context.Context, items int, packSizes []int) (Explanation, error) {
+	return Explain(ctx, c.next, items, packSizes)
+}
+
+// Purge drops every cached result.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Entries:   c.lru.Len(),
+		Bytes:     c.bytes,
+	}
+}
+
+func (c *Cache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return cacheEntry{}, false
+	}
+	c.hits++
+	c.lru.MoveToFront(elem)
+	entry := *elem.Value.(*cacheEntry)
+	entry.result = cloneDistribution(entry.result)
+	return entry, true
+}
+
+func (c *Cache) add(key string, result map[int]int, err error) {
+	entry := &cacheEntry{
+		key:    key,
+		result: cloneDistribution(result),
+		err:    err,
+		size:   int64(cacheEntryOverhead + len(key) + len(result)*cacheResultEntrySize),
+	}
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size
+
+	for c.lru.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		evicted := c.lru.Remove(oldest).(*cacheEntry)
+		delete(c.entries, evicted.key)
+		c.bytes -= evicted.size
+		c.evictions++
+	}
+}
+
+func cacheKey(normalized []int, items int, mode string) string {
+	var b strings.Builder
+	for i, size := range normalized {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(size))
+	}
+	b.WriteByte('|')
+	b.WriteString(strconv.Itoa(items))
+	b.WriteByte('|')
+	b.WriteString(mode)
+	return b.String()
+}
+
+func cloneDistribution(src map[int]int) map[int]int {
+	if src == nil {
+		return nil
+	}
+	out := make(map[int]int, len(src))
+	for size, count := range src {
+		out[size] = count
+	}
+	return out
+}
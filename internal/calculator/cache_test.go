@@ -0,0 +1,161 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type countingCalculator struct {
+	calls int
+	next  Calculator
+}
+
+func (c *countingCalculator) CalculatePacks(items int, packSizes []int) (map[int]int, error) {
+	c.calls++
+	return c.next.CalculatePacks(items, packSizes)
+}
+
+func TestCacheServesRepeatedCalculations(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingCalculator{next: New()}
+	cache := NewCache(inner, CacheConfig{MaxEntries: 10})
+
+	first, hit, err := cache.CalculatePacksCached(context.Background(), 750, []int{1000, 250, 500})
+	if err != nil || hit {
+		t.Fatalf("expected miss without error, got hit=%v err=%v", hit, err)
+	}
+	second, hit, err := cache.CalculatePacksCached(context.Background(), 750, []int{250, 500, 1000, 500})
+	if err != nil || !hit {
+		t.Fatalf("expected hit for equivalent pack sizes, got hit=%v err=%v", hit, err)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected one underlying calculation, got %d", inner.calls)
+	}
+	if !equalDistributions(first, second) {
+		t.Fatalf("expected identical results, got %v and %v", first, second)
+	}
+
+	second[250] = 99
+	third, _, _ := cache.CalculatePacksCached(context.Background(), 750, []int{250, 500, 1000})
+	if third[250] != 1 {
+		t.Fatalf("expected cached result to be isolated from caller mutations, got %v", third)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCacheRemembersCannotFulfill(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingCalculator{next: New()}
+	cache := NewCache(inner, CacheConfig{MaxEntries: 10})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.CalculatePacks(7, []int{3, 5}); !errors.Is(err, ErrCannotFulfill) {
+			t.Fatalf("expected ErrCannotFulfill, got %v", err)
+		}
+	}
+	if inner.calls != 1 {
+		t.Fatalf("expected infeasible result to be cached, got %d calls", inner.calls)
+	}
+}
+
+func TestCacheSeparatesModes(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingCalculator{next: New()}
+	cache := NewCache(inner, CacheConfig{MaxEntries: 10})
+
+	if _, err := cache.CalculatePacksContext(context.Background(), 750, []int{250, 500}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, hit, _ := cache.CalculatePacksCached(WithMode(context.Background(), "other"), 750, []int{250, 500}); hit {
+		t.Fatalf("expected different mode to miss the cache")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(New(), CacheConfig{MaxEntries: 2})
+	sizes := []int{1}
+
+	for _, items := range []int{1, 2, 1, 3} {
+		if _, err := cache.CalculatePacks(items, sizes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, hit, _ := cache.CalculatePacksCached(context.Background(), 1, sizes); !hit {
+		t.Fatalf("expected recently used entry to survive eviction")
+	}
+	if _, hit, _ := cache.CalculatePacksCached(context.Background(), 2, sizes); hit {
+		t.Fatalf("expected least recently used entry to be evicted")
+	}
+	if stats := cache.Stats(); stats.Evictions == 0 {
+		t.Fatalf("expected evictions to be counted, got %+v", stats)
+	}
+}
+
+func TestCacheRespectsMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(New(), CacheConfig{MaxEntries: 100, MaxBytes: 2 * (cacheEntryOverhead + 64)})
+	for items := 1; items <= 10; items++ {
+		if _, err := cache.CalculatePacks(items, []int{1}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Bytes > 2*(cacheEntryOverhead+64) {
+		t.Fatalf("expected cache to stay within memory limit, got %d bytes", stats.Bytes)
+	}
+	if stats.Entries >= 10 {
+		t.Fatalf("expected memory limit to evict entries, got %d entries", stats.Entries)
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(New(), CacheConfig{MaxEntries: 10})
+	if _, err := cache.CalculatePacks(500, []int{250}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cache.Purge()
+
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Fatalf("expected empty cache after purge, got %+v", stats)
+	}
+	if _, hit, _ := cache.CalculatePacksCached(context.Background(), 500, []int{250}); hit {
+		t.Fatalf("expected miss after purge")
+	}
+}
+
+func TestCacheDoesNotStoreInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	cache := NewCache(New(), CacheConfig{MaxEntries: 10})
+	if _, err := cache.CalculatePacks(10, nil); !errors.Is(err, ErrInvalidPackSizes) {
+		t.Fatalf("expected ErrInvalidPackSizes, got %v", err)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("expected invalid input not to be cached, got %+v", stats)
+	}
+}
+
+func BenchmarkCachedCalculatePacksLarge(b *testing.B) {
+	calc := NewCache(New(), CacheConfig{MaxEntries: 10})
+	packSizes := []int{23, 31, 53}
+	for i := 0; i < b.N; i++ {
+		if _, err := calc.CalculatePacks(500_000, packSizes); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
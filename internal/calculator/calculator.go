@@ -1,19 +1,80 @@
 package calculator
 
 import (
+	"context"
+	"slices"
 	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eugenenazirov/re-partners/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	maxPackSizes = 10
+	// checkInterval is the number of DP cells filled between cancellation
+	// checks and progress reports.
+	checkInterval = 1 << 14
+	// defaultMaxTableBytes bounds the memory retained by the reusable DP table.
+	defaultMaxTableBytes = 64 << 20
 )
 
-const maxPackSizes = 10
+// Option configures the calculator returned by New.
+type Option func(*dpCalculator)
+
+// WithMaxTableBytes sets the memory ceiling for the reusable DP table. Orders
+// that would grow the table beyond the ceiling are solved with a temporary
+// table instead. A non-positive value disables table reuse.
+func WithMaxTableBytes(limit int64) Option {
+	return func(c *dpCalculator) {
+		c.maxTableBytes = limit
+	}
+}
+
+// WithStrictTableLimit makes orders whose table would exceed the memory
+// ceiling fail with ErrOrderTooLarge instead of being solved with a temporary
+// table, for callers that must bound the cost of many orders. When table
+// reuse is disabled, the default ceiling applies.
+func WithStrictTableLimit() Option {
+	return func(c *dpCalculator) {
+		c.strictTableLimit = true
+	}
+}
+
+type dpCalculator struct {
+	maxTableBytes    int64
+	strictTableLimit bool
+	tieBreak         TieBreakPolicy
 
-type dpCalculator struct{}
+	// active is the reusable table for the current pack-size set. Readers load
+	// it without locking; growth publishes a new table under mu.
+	active     atomic.Pointer[dpTable]
+	mu         sync.Mutex
+	generation atomic.Uint64
+}
 
-// New creates a Calculator based on dynamic programming.
-func New() Calculator {
-	return &dpCalculator{}
+// New creates a Calculator based on dynamic programming. The calculator keeps
+// a precomputed table for the active pack-size set that grows on demand, so
+// repeated orders up to the current bound are answered without recomputation.
+func New(opts ...Option) Calculator {
+	c := &dpCalculator{maxTableBytes: defaultMaxTableBytes}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *dpCalculator) CalculatePacks(items int, packSizes []int) (map[int]int, error) {
+	return c.CalculatePacksContext(context.Background(), items, packSizes)
+}
+
+// CalculatePacksContext behaves like CalculatePacks but stops early with the
+// context error once ctx is done.
+func (c *dpCalculator) CalculatePacksContext(ctx context.Context, items int, packSizes []int) (map[int]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if items < 0 {
 		return nil, ErrInvalidItems
 	}
@@ -28,40 +89,158 @@ func (c *dpCalculator) CalculatePacks(items int, packSizes []int) (map[int]int,
 		return nil, ErrCannotFulfill
 	}
 
-	dp := make([]int, items+1)
-	choice := make([]int, items+1)
-	inf := items + 1
+	if policy := c.tieBreakFor(ctx); policy != TieBreakAscendingSizeFirst {
+		return c.calculateWithPolicy(ctx, policy, normalized, items)
+	}
+
+	progress := progressFromContext(ctx)
+	table, err := c.tableFor(ctx, normalized, items, progress)
+	if err != nil {
+		return nil, err
+	}
+	progress(1)
+
+	return table.reconstruct(items)
+}
+
+// calculateWithPolicy solves the order with a temporary table that keeps the
+// optimal pack count of every amount, which the policy needs to compare the
+// optimal distributions.
+func (c *dpCalculator) calculateWithPolicy(ctx context.Context, policy TieBreakPolicy, sizes []int, items int) (map[int]int, error) {
+	if err := c.checkFullTable(items); err != nil {
+		return nil, err
+	}
+	dp, _, err := solveFull(ctx, sizes, items)
+	if err != nil {
+		return nil, err
+	}
+	if dp[items] == unreachable {
+		return nil, ErrCannotFulfill
+	}
+	progressFromContext(ctx)(1)
+
+	counts, err := selectDistribution(ctx, policy, sizes, dp, items)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]int, len(sizes))
+	for k, count := range counts {
+		if count > 0 {
+			result[sizes[k]] = count
+		}
+	}
+	return result, nil
+}
 
-	for i := 1; i <= items; i++ {
-		dp[i] = inf
-		choice[i] = -1
+// Warm makes packSizes the active set and precomputes its table in the
+// calling goroutine, up to the bound reached by the previous active table.
+// Only the most recent Warm call installs its table.
+func (c *dpCalculator) Warm(ctx context.Context, packSizes []int) error {
+	normalized, err := normalizePackSizes(packSizes)
+	if err != nil {
+		return err
 	}
+	generation := c.generation.Add(1)
 
-	for _, size := range normalized {
-		for amount := size; amount <= items; amount++ {
-			prev := amount - size
-			if dp[prev]+1 < dp[amount] {
-				dp[amount] = dp[prev] + 1
-				choice[amount] = size
-			}
+	target := 0
+	if active := c.active.Load(); active != nil {
+		if slices.Equal(active.sizes, normalized) {
+			return nil
 		}
+		target = active.bound()
+	}
+	for target > 0 && tableMemoryBytes(normalized, target) > c.maxTableBytes {
+		target /= 2
 	}
 
-	if choice[items] == -1 {
-		return nil, ErrCannotFulfill
+	table, err := newDPTable(normalized).extend(ctx, target, progressFromContext(ctx))
+	if err != nil {
+		return err
 	}
 
-	result := make(map[int]int, len(normalized))
-	for remaining := items; remaining > 0; {
-		size := choice[remaining]
-		if size <= 0 {
-			return nil, ErrCannotFulfill
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation.Load() == generation {
+		c.active.Store(table)
+	}
+	return nil
+}
+
+// TableStats describes the reusable DP table.
+func (c *dpCalculator) TableStats() TableStats {
+	active := c.active.Load()
+	if active == nil {
+		return TableStats{MaxBytes: c.maxTableBytes}
+	}
+	return TableStats{
+		PackSizes: slices.Clone(active.sizes),
+		Bound:     active.bound(),
+		Bytes:     active.memoryBytes(),
+		MaxBytes:  c.maxTableBytes,
+	}
+}
+
+// tableFor returns a table that answers items for the normalised sizes,
+// growing the active table when possible. Tables for other sizes are built
+// without holding the lock, so they never delay calculations that grow the
+// active table or each other.
+func (c *dpCalculator) tableFor(ctx context.Context, sizes []int, items int, progress ProgressFunc) (*dpTable, error) {
+	if active := c.active.Load(); active != nil && active.bound() >= items && slices.Equal(active.sizes, sizes) {
+		return active, nil
+	}
+	if tableMemoryBytes(sizes, items) > c.maxTableBytes {
+		if c.strictTableLimit && tableMemoryBytes(sizes, items) > c.tableLimit() {
+			return nil, ErrOrderTooLarge
 		}
-		result[size]++
-		remaining -= size
+		logging.FromContext(ctx).Debug("order exceeds the dp table memory limit, using a temporary table",
+			zap.Int("items", items),
+			zap.Int64("max_bytes", c.maxTableBytes),
+		)
+		return newDPTable(sizes).extend(ctx, items, progress)
+	}
+	if active := c.active.Load(); active != nil && !slices.Equal(active.sizes, sizes) {
+		return newDPTable(sizes).extend(ctx, items, progress)
 	}
 
-	return result, nil
+	table, ok, err := c.growActive(ctx, sizes, items, progress)
+	if ok || err != nil {
+		return table, err
+	}
+	// The active table was replaced by one for other sizes meanwhile.
+	return newDPTable(sizes).extend(ctx, items, progress)
+}
+
+// growActive returns the active table for sizes, growing it to answer items
+// when needed. It reports false when the active table is for other sizes.
+func (c *dpCalculator) growActive(ctx context.Context, sizes []int, items int, progress ProgressFunc) (*dpTable, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active := c.active.Load()
+	switch {
+	case active == nil:
+		active = newDPTable(sizes)
+	case !slices.Equal(active.sizes, sizes):
+		return nil, false, nil
+	case active.bound() >= items:
+		return active, true, nil
+	}
+
+	target := items
+	if doubled := active.bound() * 2; doubled > target && tableMemoryBytes(sizes, doubled) <= c.maxTableBytes {
+		target = doubled
+	}
+	grown, err := active.extend(ctx, target, progress)
+	if err != nil {
+		return nil, true, err
+	}
+	c.active.Store(grown)
+	logging.FromContext(ctx).Debug("dp table grown",
+		zap.Ints("pack_sizes", sizes),
+		zap.Int("bound", grown.bound()),
+		zap.Int64("bytes", grown.memoryBytes()),
+	)
+	return grown, true, nil
 }
 
 func normalizePackSizes(packSizes []int) ([]int, error) {
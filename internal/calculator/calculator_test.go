@@ -1,6 +1,7 @@
 package calculator
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"slices"
@@ -195,3 +196,37 @@ func BenchmarkCalculatePacksLarge(b *testing.B) {
 		}
 	}
 }
+
+func TestCalculatePacksContext_Canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calc := New().(ContextCalculator)
+	if _, err := calc.CalculatePacksContext(ctx, 500_000, []int{23, 31, 53}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCalculatePacksContext_ReportsProgress(t *testing.T) {
+	t.Parallel()
+
+	var reports []float64
+	ctx := WithProgress(context.Background(), func(fraction float64) {
+		reports = append(reports, fraction)
+	})
+
+	if _, err := CalculateWithContext(ctx, New(), 500_000, []int{23, 31, 53}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("expected intermediate progress reports, got %v", reports)
+	}
+	if !slices.IsSorted(reports) {
+		t.Fatalf("expected progress to be monotonic, got %v", reports)
+	}
+	if last := reports[len(reports)-1]; last != 1 {
+		t.Fatalf("expected final progress 1, got %f", last)
+	}
+}
@@ -0,0 +1,52 @@
+package calculator
+
+import "context"
+
+type (
+	progressContextKey struct{}
+	modeContextKey     struct{}
+)
+
+// ProgressFunc receives the share of a calculation completed so far, in the
+// range [0, 1].
+type ProgressFunc func(fraction float64)
+
+// WithProgress returns a context that makes context-aware calculators report
+// their progress to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, fn)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	if fn, ok := ctx.Value(progressContextKey{}).(ProgressFunc); ok && fn != nil {
+		return fn
+	}
+	return func(float64) {}
+}
+
+// WithMode tags ctx with a calculation mode. Results computed under different
+// modes are kept apart by decorators such as Cache.
+func WithMode(ctx context.Context, mode string) context.Context {
+	return context.WithValue(ctx, modeContextKey{}, mode)
+}
+
+// ModeFromContext returns the calculation mode stored in ctx, or an empty
+// string for the default mode.
+func ModeFromContext(ctx context.Context) string {
+	if mode, ok := ctx.Value(modeContextKey{}).(string); ok {
+		return mode
+	}
+	return ""
+}
+
+// CalculateWithContext runs calc with ctx when it implements ContextCalculator
+// and falls back to CalculatePacks otherwise.
+func CalculateWithContext(ctx context.Context, calc Calculator, items int, packSizes []int) (map[int]int, error) {
+	if cc, ok := calc.(ContextCalculator); ok {
+		return cc.CalculatePacksContext(ctx, items, packSizes)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return calc.CalculatePacks(items, packSizes)
+}
@@ -9,4 +9,11 @@ var (
 	ErrInvalidPackSizes = errors.New("pack sizes must contain between 1 and 10 positive integers")
 	// ErrCannotFulfill is returned when it is impossible to pack the items exactly with the provided sizes.
 	ErrCannotFulfill = errors.New("cannot pack items exactly with the provided pack sizes")
+	// ErrInvalidTieBreak is returned for an unknown tie-break policy.
+	ErrInvalidTieBreak = errors.New("unknown tie-break policy")
+	// ErrOrderTooLarge is returned when an explanation or a tie-break
+	// policy would need a full DP table larger than the memory ceiling.
+	ErrOrderTooLarge = errors.New("order is too large to solve within the dp table memory limit")
+	// ErrExplainUnsupported is returned when the calculator cannot explain its results.
+	ErrExplainUnsupported = errors.New("calculator does not support explanations")
 )
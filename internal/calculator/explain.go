@@ -0,0 +1,302 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxExplainedTieBreaks caps the tie-breaking decisions listed in an
+// Explanation; TieBreakCount always reports the full number.
+const maxExplainedTieBreaks = 50
+
+// Explanation describes how a distribution was derived and why it is optimal.
+type Explanation struct {
+	Items     int         `json:"items"`
+	PackSizes []int       `json:"packSizes"`
+	Packs     map[int]int `json:"packs"`
+	// Objective is the minimised value: the total number of packs.
+	Objective int `json:"objective"`
+	// Path is the reconstruction walk over choice[], compressed into runs of
+	// the same pack size.
+	Path []PathSegment `json:"path"`
+	// TieBreaks lists amounts on the path where another pack size would have
+	// reached the same optimum, and the policy that decided between them.
+	TieBreaks     []TieBreak `json:"tieBreaks"`
+	TieBreakCount int        `json:"tieBreakCount"`
+	TieBreakRule  string     `json:"tieBreakRule"`
+	// LowerBound is ceil(items / largest pack size): no distribution can use
+	// fewer packs.
+	LowerBound int `json:"lowerBound"`
+	// Predecessors certify optimality: removing any single pack from items
+	// leaves an amount that needs at least Objective-1 packs.
+	Predecessors []Predecessor `json:"predecessors"`
+	Proof        string        `json:"proof"`
+}
+
+// PathSegment is a run of identical packs taken during reconstruction.
+type PathSegment struct {
+	Pack  int `json:"pack"`
+	Count int `json:"count"`
+	From  int `json:"from"`
+	To    int `json:"to"`
+}
+
+// TieBreak records a reconstruction step with more than one optimal pack.
+type TieBreak struct {
+	Amount       int   `json:"amount"`
+	Chosen       int   `json:"chosen"`
+	Alternatives []int `json:"alternatives"`
+}
+
+// Predecessor is the optimal pack count of items minus one pack.
+type Predecessor struct {
+	Pack      int  `json:"pack"`
+	Amount    int  `json:"amount"`
+	MinPacks  int  `json:"minPacks"`
+	Reachable bool `json:"reachable"`
+}
+
+// Explainer is implemented by calculators that can justify their results.
+type Explainer interface {
+	Explain(ctx context.Context, items int, packSizes []int) (Explanation, error)
+}
+
+// Explain asks calc for an explanation when it implements Explainer and
+// returns ErrExplainUnsupported otherwise.
+func Explain(ctx context.Context, calc Calculator, items int, packSizes []int) (Explanation, error) {
+	if e, ok := calc.(Explainer); ok {
+		return e.Explain(ctx, items, packSizes)
+	}
+	return Explanation{}, ErrExplainUnsupported
+}
+
+// Explain solves the order with a full DP table and records the decisions
+// that led to the result.
+func (c *dpCalculator) Explain(ctx context.Context, items int, packSizes []int) (Explanation, error) {
+	if err := ctx.Err(); err != nil {
+		return Explanation{}, err
+	}
+	if items < 0 {
+		return Explanation{}, ErrInvalidItems
+	}
+	sizes, err := normalizePackSizes(packSizes)
+	if err != nil {
+		return Explanation{}, err
+	}
+	if err := c.checkFullTable(items); err != nil {
+		return Explanation{}, err
+	}
+
+	dp, choice, err := solveFull(ctx, sizes, items)
+	if err != nil {
+		return Explanation{}, err
+	}
+	if dp[items] == unreachable {
+		return Explanation{}, ErrCannotFulfill
+	}
+
+	policy := c.tieBreakFor(ctx)
+	steps, err := explainedSteps(ctx, policy, sizes, dp, choice, items)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	exp := Explanation{
+		Items:        items,
+		PackSizes:    slices.Clone(sizes),
+		Packs:        map[int]int{},
+		Objective:    dp[items],
+		Path:         []PathSegment{},
+		TieBreaks:    []TieBreak{},
+		TieBreakRule: string(policy),
+		LowerBound:   ceilDiv(items, sizes[len(sizes)-1]),
+		Predecessors: []Predecessor{},
+	}
+
+	remaining := items
+	for _, k := range steps {
+		size := sizes[k]
+		exp.Packs[size]++
+
+		var alternatives []int
+		for _, other := range sizes {
+			if other != size && other <= remaining && dp[remaining-other] != unreachable && dp[remaining-other]+1 == dp[remaining] {
+				alternatives = append(alternatives, other)
+			}
+		}
+		if len(alternatives) > 0 {
+			exp.TieBreakCount++
+			if len(exp.TieBreaks) < maxExplainedTieBreaks {
+				exp.TieBreaks = append(exp.TieBreaks, TieBreak{Amount: remaining, Chosen: size, Alternatives: alternatives})
+			}
+		}
+
+		if n := len(exp.Path); n > 0 && exp.Path[n-1].Pack == size {
+			exp.Path[n-1].Count++
+			exp.Path[n-1].To = remaining - size
+		} else {
+			exp.Path = append(exp.Path, PathSegment{Pack: size, Count: 1, From: remaining, To: remaining - size})
+		}
+		remaining -= size
+	}
+
+	if items > 0 {
+		for _, size := range sizes {
+			if size > items {
+				continue
+			}
+			pred := Predecessor{Pack: size, Amount: items - size, Reachable: dp[items-size] != unreachable}
+			if pred.Reachable {
+				pred.MinPacks = dp[items-size]
+			}
+			exp.Predecessors = append(exp.Predecessors, pred)
+		}
+	}
+
+	if exp.Objective == exp.LowerBound {
+		exp.Proof = fmt.Sprintf("objective %d equals the lower bound ceil(%d / %d)", exp.Objective, items, sizes[len(sizes)-1])
+	} else {
+		exp.Proof = fmt.Sprintf("every amount reachable by removing one pack needs at least %d packs, so %d packs is minimal", exp.Objective-1, exp.Objective)
+	}
+
+	return exp, nil
+}
+
+// explainedSteps lists the size indexes taken from items down to zero. The
+// default policy follows the choice table; other policies take their selected
+// packs largest first.
+func explainedSteps(ctx context.Context, policy TieBreakPolicy, sizes []int, dp []int, choice []uint8, items int) ([]int, error) {
+	steps := make([]int, 0, dp[items])
+	if policy == TieBreakAscendingSizeFirst {
+		for remaining := items; remaining > 0; remaining -= sizes[choice[remaining]] {
+			steps = append(steps, int(choice[remaining]))
+		}
+		return steps, nil
+	}
+
+	counts, err := selectDistribution(ctx, policy, sizes, dp, items)
+	if err != nil {
+		return nil, err
+	}
+	for k := len(sizes) - 1; k >= 0; k-- {
+		for range counts[k] {
+			steps = append(steps, k)
+		}
+	}
+	return steps, nil
+}
+
+// Text renders the explanation for humans.
+func (e Explanation) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Order: %d items\n", e.Items)
+	fmt.Fprintf(&b, "Pack sizes: %s\n", joinInts(e.PackSizes))
+	fmt.Fprintf(&b, "Result: %d packs (%s)\n", e.Objective, formatDistribution(e.Packs, e.PackSizes))
+
+	b.WriteString("\nReconstruction path:\n")
+	for _, seg := range e.Path {
+		fmt.Fprintf(&b, "  %d -> %d: %d x %d\n", seg.From, seg.To, seg.Count, seg.Pack)
+	}
+
+	fmt.Fprintf(&b, "\nTie-breaking (%s): %d decision(s)\n", e.TieBreakRule, e.TieBreakCount)
+	for _, tb := range e.TieBreaks {
+		fmt.Fprintf(&b, "  at %d chose %d over %s\n", tb.Amount, tb.Chosen, joinInts(tb.Alternatives))
+	}
+	if hidden := e.TieBreakCount - len(e.TieBreaks); hidden > 0 {
+		fmt.Fprintf(&b, "  ... %d more\n", hidden)
+	}
+
+	b.WriteString("\nOptimality:\n")
+	fmt.Fprintf(&b, "  lower bound: %d packs\n", e.LowerBound)
+	for _, pred := range e.Predecessors {
+		if pred.Reachable {
+			fmt.Fprintf(&b, "  %d - %d = %d needs %d packs\n", e.Items, pred.Pack, pred.Amount, pred.MinPacks)
+		} else {
+			fmt.Fprintf(&b, "  %d - %d = %d cannot be packed\n", e.Items, pred.Pack, pred.Amount)
+		}
+	}
+	fmt.Fprintf(&b, "  proof: %s\n", e.Proof)
+	return b.String()
+}
+
+// fullTableCellBytes is the memory solveFull uses per amount: the pack
+// count and the choice.
+const fullTableCellBytes = windowCellBytes + 1
+
+// checkFullTable returns ErrOrderTooLarge when the full table for items
+// exceeds the memory ceiling, or the default one when table reuse is
+// disabled.
+func (c *dpCalculator) checkFullTable(items int) error {
+	// Equivalent to (items+1)*fullTableCellBytes > limit without overflowing
+	// for orders near math.MaxInt.
+	if int64(items) >= c.tableLimit()/fullTableCellBytes {
+		return ErrOrderTooLarge
+	}
+	return nil
+}
+
+// tableLimit is the memory ceiling for tables, or the default one when
+// table reuse is disabled.
+func (c *dpCalculator) tableLimit() int64 {
+	if c.maxTableBytes <= 0 {
+		return defaultMaxTableBytes
+	}
+	return c.maxTableBytes
+}
+
+// solveFull runs the DP to items and keeps the optimal pack count of every
+// amount in addition to the choices.
+func solveFull(ctx context.Context, sizes []int, items int) ([]int, []uint8, error) {
+	dp := make([]int, items+1)
+	choice := make([]uint8, items+1)
+	for i := 1; i <= items; i++ {
+		dp[i] = unreachable
+		choice[i] = noChoice
+	}
+	choice[0] = noChoice
+
+	done := 0
+	for k, size := range sizes {
+		for amount := size; amount <= items; amount++ {
+			prev := dp[amount-size]
+			if prev != unreachable && prev+1 < dp[amount] {
+				dp[amount] = prev + 1
+				choice[amount] = uint8(k)
+			}
+			done++
+			if done%checkInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	return dp, choice, nil
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatDistribution(packs map[int]int, sizes []int) string {
+	parts := make([]string, 0, len(packs))
+	for i := len(sizes) - 1; i >= 0; i-- {
+		if count := packs[sizes[i]]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%d x %d", count, sizes[i]))
+		}
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,135 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainMatchesCalculatePacks(t *testing.T) {
+	calc := New()
+	sizes := []int{23, 31, 53}
+
+	for _, items := range []int{0, 23, 263, 1000, 500000} {
+		want, err := calc.CalculatePacks(items, sizes)
+		if err != nil {
+			t.Fatalf("CalculatePacks(%d) returned error: %v", items, err)
+		}
+		exp, err := Explain(context.Background(), calc, items, sizes)
+		if err != nil {
+			t.Fatalf("Explain(%d) returned error: %v", items, err)
+		}
+		if !reflect.DeepEqual(exp.Packs, want) {
+			t.Fatalf("items %d: explanation packs %v, calculator %v", items, exp.Packs, want)
+		}
+
+		total, walked := 0, 0
+		for _, count := range want {
+			total += count
+		}
+		for _, seg := range exp.Path {
+			walked += seg.Count
+			if seg.From-seg.To != seg.Count*seg.Pack {
+				t.Fatalf("items %d: inconsistent path segment %+v", items, seg)
+			}
+		}
+		if exp.Objective != total || walked != total {
+			t.Fatalf("items %d: objective %d, path %d, want %d", items, exp.Objective, walked, total)
+		}
+		if exp.LowerBound > exp.Objective {
+			t.Fatalf("items %d: lower bound %d exceeds objective %d", items, exp.LowerBound, exp.Objective)
+		}
+		for _, pred := range exp.Predecessors {
+			if pred.Reachable && pred.MinPacks < exp.Objective-1 {
+				t.Fatalf("items %d: predecessor %+v beats the objective", items, pred)
+			}
+		}
+	}
+}
+
+func TestExplainRecordsTieBreaks(t *testing.T) {
+	exp, err := New().(Explainer).Explain(context.Background(), 4, []int{3, 1, 2})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+
+	want := []TieBreak{{Amount: 4, Chosen: 2, Alternatives: []int{1, 3}}}
+	if !reflect.DeepEqual(exp.TieBreaks, want) || exp.TieBreakCount != 1 {
+		t.Fatalf("unexpected tie-breaks: %+v (count %d)", exp.TieBreaks, exp.TieBreakCount)
+	}
+	if exp.TieBreakRule != string(TieBreakAscendingSizeFirst) {
+		t.Fatalf("unexpected rule %q", exp.TieBreakRule)
+	}
+	if exp.LowerBound != 2 || exp.Objective != 2 {
+		t.Fatalf("expected objective and lower bound 2, got %d and %d", exp.Objective, exp.LowerBound)
+	}
+	if !strings.Contains(exp.Proof, "lower bound") {
+		t.Fatalf("expected lower-bound proof, got %q", exp.Proof)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	calc := New()
+
+	if _, err := Explain(context.Background(), calc, 263, []int{250, 500}); !errors.Is(err, ErrCannotFulfill) {
+		t.Fatalf("expected ErrCannotFulfill, got %v", err)
+	}
+	if _, err := Explain(context.Background(), calc, -1, []int{250}); !errors.Is(err, ErrInvalidItems) {
+		t.Fatalf("expected ErrInvalidItems, got %v", err)
+	}
+	for _, items := range []int{2_000_000_000, math.MaxInt} {
+		if _, err := Explain(context.Background(), calc, items, []int{250}); !errors.Is(err, ErrOrderTooLarge) {
+			t.Fatalf("expected ErrOrderTooLarge for %d items, got %v", items, err)
+		}
+	}
+	if _, err := Explain(context.Background(), New(WithMaxTableBytes(1024)), 1000, []int{250}); !errors.Is(err, ErrOrderTooLarge) {
+		t.Fatalf("expected the configured limit to apply, got %v", err)
+	}
+	if _, err := Explain(context.Background(), &countingCalculator{next: New()}, 1, []int{1}); !errors.Is(err, ErrExplainUnsupported) {
+		t.Fatalf("expected ErrExplainUnsupported, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Explain(ctx, calc, 1000, []int{250}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestExplainThroughCache(t *testing.T) {
+	cache := NewCache(New(), CacheConfig{MaxEntries: 10})
+	exp, err := Explain(context.Background(), cache, 750, []int{250, 500, 1000})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+	if exp.Packs[250] != 1 || exp.Packs[500] != 1 {
+		t.Fatalf("unexpected packs %v", exp.Packs)
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Fatalf("expected explanations to bypass the cache, got %+v", stats)
+	}
+}
+
+func TestExplanationText(t *testing.T) {
+	exp, err := Explain(context.Background(), New(), 12250, []int{250, 500, 1000, 2000, 5000})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+
+	text := exp.Text()
+	for _, want := range []string{
+		"Order: 12250 items",
+		"Result: 4 packs (2 x 5000, 1 x 2000, 1 x 250)",
+		"Reconstruction path:",
+		"Tie-breaking (ascending-size-first)",
+		"lower bound: 3 packs",
+		"proof:",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected text to contain %q, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"slices"
+)
+
+// TieBreakPolicy decides which distribution is returned when several use the
+// same, minimal number of packs.
+type TieBreakPolicy string
+
+const (
+	// TieBreakAscendingSizeFirst keeps the classic DP behaviour: sizes are
+	// applied in ascending order and a larger size only replaces a choice when
+	// it strictly reduces the pack count. The result uses the smallest possible
+	// largest pack, then the smallest possible next pack, and so on.
+	TieBreakAscendingSizeFirst TieBreakPolicy = "ascending-size-first"
+	// TieBreakLargerPacks uses as many of the largest pack as possible, then as
+	// many of the next largest, and so on.
+	TieBreakLargerPacks TieBreakPolicy = "larger-packs"
+	// TieBreakFewerSizes uses the fewest distinct pack sizes. Equally small
+	// sets prefer larger packs.
+	TieBreakFewerSizes TieBreakPolicy = "fewer-sizes"
+	// TieBreakSmallerOvershoot minimises the items shipped beyond the order.
+	// Orders are packed exactly, so every optimal distribution has zero
+	// overshoot and the remaining ties are broken as TieBreakLargerPacks.
+	TieBreakSmallerOvershoot TieBreakPolicy = "smaller-overshoot"
+	// TieBreakLexicographic compares pack counts ordered by ascending pack
+	// size and returns the smallest vector: the fewest packs of the smallest
+	// size, then of the next size, and so on.
+	TieBreakLexicographic TieBreakPolicy = "lexicographic"
+)
+
+type tieBreakContextKey struct{}
+
+// TieBreakPolicies lists the supported policies, default first.
+func TieBreakPolicies() []TieBreakPolicy {
+	return []TieBreakPolicy{
+		TieBreakAscendingSizeFirst,
+		TieBreakLargerPacks,
+		TieBreakFewerSizes,
+		TieBreakSmallerOvershoot,
+		TieBreakLexicographic,
+	}
+}
+
+// ParseTieBreakPolicy validates a policy name.
+func ParseTieBreakPolicy(name string) (TieBreakPolicy, error) {
+	policy := TieBreakPolicy(name)
+	if slices.Contains(TieBreakPolicies(), policy) {
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidTieBreak, name)
+}
+
+// WithDefaultTieBreak sets the policy used when the context does not select
+// one. The default is TieBreakAscendingSizeFirst.
+func WithDefaultTieBreak(policy TieBreakPolicy) Option {
+	return func(c *dpCalculator) {
+		c.tieBreak = policy
+	}
+}
+
+// WithTieBreak selects the tie-break policy for calculations run with ctx. The
+// policy doubles as the calculation mode, so cached results stay apart.
+func WithTieBreak(ctx context.Context, policy TieBreakPolicy) context.Context {
+	ctx = context.WithValue(ctx, tieBreakContextKey{}, policy)
+	return WithMode(ctx, string(policy))
+}
+
+func (c *dpCalculator) tieBreakFor(ctx context.Context) TieBreakPolicy {
+	if policy, ok := ctx.Value(tieBreakContextKey{}).(TieBreakPolicy); ok && policy != "" {
+		return policy
+	}
+	if c.tieBreak != "" {
+		return c.tieBreak
+	}
+	return TieBreakAscendingSizeFirst
+}
+
+// selectDistribution picks the per-size pack counts that policy prefers among
+// the optimal distributions encoded in dp. It must not be called with
+// TieBreakAscendingSizeFirst, which is answered by the choice table.
+func selectDistribution(ctx context.Context, policy TieBreakPolicy, sizes []int, dp []int, items int) ([]int, error) {
+	paths := newOptimalPaths(ctx, sizes, dp)
+	all := uint16(1)<<len(sizes) - 1
+
+	switch policy {
+	case TieBreakLargerPacks, TieBreakSmallerOvershoot:
+		return paths.largestFirst(items, all)
+	case TieBreakFewerSizes:
+		for _, mask := range subsetsBySize(sizes) {
+			paths.restrict(mask)
+			ok, err := paths.completes(items)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return paths.largestFirst(items, mask)
+			}
+		}
+		return nil, ErrCannotFulfill
+	case TieBreakLexicographic:
+		return paths.fewestSmallFirst(items)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTieBreak, policy)
+	}
+}
+
+const (
+	pathUnknown int8 = iota
+	pathCompletes
+	pathDeadEnd
+)
+
+// optimalPaths walks the DAG of optimal steps: from amount a, taking pack s is
+// optimal when dp[a-s] == dp[a]-1. Every walk from items to zero is an optimal
+// distribution. Walks can be restricted to a subset of the sizes.
+type optimalPaths struct {
+	ctx   context.Context
+	sizes []int
+	dp    []int
+
+	mask    uint16
+	memo    []int8
+	touched []int
+	visits  int
+}
+
+func newOptimalPaths(ctx context.Context, sizes []int, dp []int) *optimalPaths {
+	return &optimalPaths{
+		ctx:   ctx,
+		sizes: sizes,
+		dp:    dp,
+		mask:  uint16(1)<<len(sizes) - 1,
+		memo:  make([]int8, len(dp)),
+	}
+}
+
+// restrict limits later walks to the sizes in mask.
+func (p *optimalPaths) restrict(mask uint16) {
+	for _, amount := range p.touched {
+		p.memo[amount] = pathUnknown
+	}
+	p.touched = p.touched[:0]
+	p.mask = mask
+}
+
+// step reports whether taking size index k from amount is an optimal step.
+func (p *optimalPaths) step(amount, k int) bool {
+	size := p.sizes[k]
+	return size <= amount && p.dp[amount-size] != unreachable && p.dp[amount-size]+1 == p.dp[amount]
+}
+
+// completes reports whether amount can reach zero with optimal steps using
+// only the allowed sizes.
+func (p *optimalPaths) completes(start int) (bool, error) {
+	if start == 0 {
+		return true, nil
+	}
+	if p.dp[start] == unreachable {
+		return false, nil
+	}
+	if p.mask == uint16(1)<<len(p.sizes)-1 {
+		return true, nil
+	}
+
+	stack := []int{start}
+	for len(stack) > 0 {
+		amount := stack[len(stack)-1]
+		if p.memo[amount] != pathUnknown {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		p.visits++
+		if p.visits%checkInterval == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return false, err
+			}
+		}
+
+		state, pending := pathDeadEnd, false
+		for k := range p.sizes {
+			if p.mask&(1<<k) == 0 || !p.step(amount, k) {
+				continue
+			}
+			next := amount - p.sizes[k]
+			if next == 0 || p.memo[next] == pathCompletes {
+				state = pathCompletes
+				break
+			}
+			if p.memo[next] == pathUnknown {
+				stack = append(stack, next)
+				pending = true
+			}
+		}
+		if state == pathCompletes || !pending {
+			p.memo[amount] = state
+			p.touched = append(p.touched, amount)
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return p.memo[start] == pathCompletes, nil
+}
+
+// largestFirst walks from items taking the largest allowed pack that keeps the
+// walk optimal and completable.
+func (p *optimalPaths) largestFirst(items int, mask uint16) ([]int, error) {
+	if p.mask != mask {
+		p.restrict(mask)
+	}
+	counts := make([]int, len(p.sizes))
+	for amount := items; amount > 0; {
+		chosen := -1
+		for k := len(p.sizes) - 1; k >= 0 && chosen < 0; k-- {
+			if mask&(1<<k) == 0 || !p.step(amount, k) {
+				continue
+			}
+			ok, err := p.completes(amount - p.sizes[k])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				chosen = k
+			}
+		}
+		if chosen < 0 {
+			return nil, ErrCannotFulfill
+		}
+		counts[chosen]++
+		amount -= p.sizes[chosen]
+	}
+	return counts, nil
+}
+
+// fewestSmallFirst fixes the count of each size in ascending order, taking the
+// smallest count that still leaves an optimal completion with larger sizes.
+func (p *optimalPaths) fewestSmallFirst(items int) ([]int, error) {
+	counts := make([]int, len(p.sizes))
+	amount := items
+	for k, size := range p.sizes {
+		larger := uint16(1)<<len(p.sizes) - uint16(1)<<(k+1)
+		p.restrict(larger)
+
+		found := false
+		for count := 0; count*size <= amount; count++ {
+			rest := amount - count*size
+			if p.dp[rest] == unreachable || p.dp[rest] != p.dp[amount]-count {
+				break
+			}
+			ok, err := p.completes(rest)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				counts[k] = count
+				amount = rest
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrCannotFulfill
+		}
+	}
+	return counts, nil
+}
+
+// subsetsBySize lists every non-empty subset of sizes as a bit mask, ordered
+// by cardinality and then by preferring larger sizes.
+func subsetsBySize(sizes []int) []uint16 {
+	masks := make([]uint16, 0, 1<<len(sizes)-1)
+	for mask := uint16(1); mask < uint16(1)<<len(sizes); mask++ {
+		masks = append(masks, mask)
+	}
+	slices.SortFunc(masks, func(a, b uint16) int {
+		if n, m := bits.OnesCount16(a), bits.OnesCount16(b); n != m {
+			return n - m
+		}
+		for k := len(sizes) - 1; k >= 0; k-- {
+			inA, inB := a&(1<<k) != 0, b&(1<<k) != 0
+			if inA != inB {
+				if inA {
+					return -1
+				}
+				return 1
+			}
+		}
+		return 0
+	})
+	return masks
+}
@@ -0,0 +1,288 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTieBreakPoliciesLockedOutputs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name  string
+		items int
+		sizes []int
+		want  map[TieBreakPolicy]map[int]int
+	}{
+		{
+			name:  "three small sizes",
+			items: 4,
+			sizes: []int{1, 2, 3},
+			want: map[TieBreakPolicy]map[int]int{
+				TieBreakAscendingSizeFirst: {2: 2},
+				TieBreakLargerPacks:        {1: 1, 3: 1},
+				TieBreakFewerSizes:         {2: 2},
+				TieBreakSmallerOvershoot:   {1: 1, 3: 1},
+				TieBreakLexicographic:      {2: 2},
+			},
+		},
+		{
+			name:  "distinct outcome per policy",
+			items: 24,
+			sizes: []int{2, 3, 4, 7, 9},
+			want: map[TieBreakPolicy]map[int]int{
+				TieBreakAscendingSizeFirst: {3: 1, 7: 3},
+				TieBreakLargerPacks:        {9: 2, 4: 1, 2: 1},
+				TieBreakFewerSizes:         {9: 2, 3: 2},
+				TieBreakSmallerOvershoot:   {9: 2, 4: 1, 2: 1},
+				TieBreakLexicographic:      {9: 1, 7: 1, 4: 2},
+			},
+		},
+		{
+			name:  "edge case unaffected",
+			items: 500000,
+			sizes: []int{23, 31, 53},
+			want: map[TieBreakPolicy]map[int]int{
+				TieBreakAscendingSizeFirst: {23: 2, 31: 7, 53: 9429},
+				TieBreakLargerPacks:        {23: 2, 31: 7, 53: 9429},
+				TieBreakFewerSizes:         {23: 2, 31: 7, 53: 9429},
+				TieBreakSmallerOvershoot:   {23: 2, 31: 7, 53: 9429},
+				TieBreakLexicographic:      {23: 2, 31: 7, 53: 9429},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		for _, policy := range TieBreakPolicies() {
+			got, err := New().(ContextCalculator).CalculatePacksContext(WithTieBreak(context.Background(), policy), tc.items, tc.sizes)
+			if err != nil {
+				t.Fatalf("%s/%s: unexpected error: %v", tc.name, policy, err)
+			}
+			if !reflect.DeepEqual(got, tc.want[policy]) {
+				t.Fatalf("%s/%s: expected %v, got %v", tc.name, policy, tc.want[policy], got)
+			}
+		}
+	}
+}
+
+func TestTieBreakPoliciesMatchBruteForce(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(31))
+	calc := New().(ContextCalculator)
+
+	for iter := 0; iter < 300; iter++ {
+		var sizes []int
+		for len(sizes) == 0 {
+			for size := 1; size <= 12; size++ {
+				if rng.Intn(3) == 0 {
+					sizes = append(sizes, size)
+				}
+			}
+		}
+		items := 1 + rng.Intn(40)
+
+		candidates := optimalDistributions(items, sizes)
+		for _, policy := range TieBreakPolicies() {
+			got, err := calc.CalculatePacksContext(WithTieBreak(context.Background(), policy), items, sizes)
+			if len(candidates) == 0 {
+				if !errors.Is(err, ErrCannotFulfill) {
+					t.Fatalf("%v/%d/%s: expected ErrCannotFulfill, got %v", sizes, items, policy, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%v/%d/%s: unexpected error: %v", sizes, items, policy, err)
+			}
+			want := preferredDistribution(policy, sizes, candidates)
+			if !reflect.DeepEqual(got, toDistribution(sizes, want)) {
+				t.Fatalf("%v/%d/%s: expected %v, got %v", sizes, items, policy, toDistribution(sizes, want), got)
+			}
+		}
+	}
+}
+
+func TestTieBreakPolicyFromCalculatorDefault(t *testing.T) {
+	t.Parallel()
+
+	calc := New(WithDefaultTieBreak(TieBreakLargerPacks))
+	got, err := calc.CalculatePacks(4, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[int]int{1: 1, 3: 1}) {
+		t.Fatalf("expected calculator default to apply, got %v", got)
+	}
+
+	got, err = calc.(ContextCalculator).CalculatePacksContext(WithTieBreak(context.Background(), TieBreakAscendingSizeFirst), 4, []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, map[int]int{2: 2}) {
+		t.Fatalf("expected request policy to override the default, got %v", got)
+	}
+}
+
+func TestTieBreakPolicyRespectsMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	ctx := WithTieBreak(context.Background(), TieBreakFewerSizes)
+	calc := New().(ContextCalculator)
+	if _, err := calc.CalculatePacksContext(ctx, 2_000_000_000, []int{250, 500}); !errors.Is(err, ErrOrderTooLarge) {
+		t.Fatalf("expected ErrOrderTooLarge, got %v", err)
+	}
+	if _, err := New(WithDefaultTieBreak(TieBreakLargerPacks), WithMaxTableBytes(1024)).CalculatePacks(1000, []int{250}); !errors.Is(err, ErrOrderTooLarge) {
+		t.Fatalf("expected the configured limit to apply, got %v", err)
+	}
+	if _, err := New(WithMaxTableBytes(1024)).CalculatePacks(1000, []int{250}); err != nil {
+		t.Fatalf("expected the default policy to use a temporary table, got %v", err)
+	}
+}
+
+func TestParseTieBreakPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range TieBreakPolicies() {
+		got, err := ParseTieBreakPolicy(string(policy))
+		if err != nil || got != policy {
+			t.Fatalf("ParseTieBreakPolicy(%q) = %q, %v", policy, got, err)
+		}
+	}
+	if _, err := ParseTieBreakPolicy("random"); !errors.Is(err, ErrInvalidTieBreak) {
+		t.Fatalf("expected ErrInvalidTieBreak, got %v", err)
+	}
+}
+
+func TestExplainFollowsTieBreakPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := WithTieBreak(context.Background(), TieBreakLargerPacks)
+	exp, err := Explain(ctx, New(), 24, []int{2, 3, 4, 7, 9})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+	if exp.TieBreakRule != string(TieBreakLargerPacks) {
+		t.Fatalf("unexpected rule %q", exp.TieBreakRule)
+	}
+	want := []PathSegment{
+		{Pack: 9, Count: 2, From: 24, To: 6},
+		{Pack: 4, Count: 1, From: 6, To: 2},
+		{Pack: 2, Count: 1, From: 2, To: 0},
+	}
+	if !reflect.DeepEqual(exp.Path, want) {
+		t.Fatalf("expected path %+v, got %+v", want, exp.Path)
+	}
+}
+
+// optimalDistributions enumerates every count vector that packs items exactly
+// with the minimal number of packs.
+func optimalDistributions(items int, sizes []int) [][]int {
+	var all [][]int
+	counts := make([]int, len(sizes))
+	var walk func(k, remaining int)
+	walk = func(k, remaining int) {
+		if k == len(sizes) {
+			if remaining == 0 {
+				all = append(all, slices.Clone(counts))
+			}
+			return
+		}
+		for c := 0; c*sizes[k] <= remaining; c++ {
+			counts[k] = c
+			walk(k+1, remaining-c*sizes[k])
+		}
+		counts[k] = 0
+	}
+	walk(0, items)
+
+	best := -1
+	for _, c := range all {
+		if n := total(c); best < 0 || n < best {
+			best = n
+		}
+	}
+	var optimal [][]int
+	for _, c := range all {
+		if total(c) == best {
+			optimal = append(optimal, c)
+		}
+	}
+	return optimal
+}
+
+// preferredDistribution applies the documented definition of each policy.
+func preferredDistribution(policy TieBreakPolicy, sizes []int, candidates [][]int) []int {
+	fromLargest := func(a, b []int) int {
+		for k := len(a) - 1; k >= 0; k-- {
+			if a[k] != b[k] {
+				return a[k] - b[k]
+			}
+		}
+		return 0
+	}
+	fromSmallest := func(a, b []int) int {
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] - b[k]
+			}
+		}
+		return 0
+	}
+	usedMask := func(c []int) uint16 {
+		var mask uint16
+		for k, n := range c {
+			if n > 0 {
+				mask |= 1 << k
+			}
+		}
+		return mask
+	}
+
+	return slices.MinFunc(candidates, func(a, b []int) int {
+		switch policy {
+		case TieBreakAscendingSizeFirst:
+			return fromLargest(a, b)
+		case TieBreakLargerPacks, TieBreakSmallerOvershoot:
+			return -fromLargest(a, b)
+		case TieBreakFewerSizes:
+			ma, mb := usedMask(a), usedMask(b)
+			if n, m := bits.OnesCount16(ma), bits.OnesCount16(mb); n != m {
+				return n - m
+			}
+			for k := len(sizes) - 1; k >= 0; k-- {
+				if inA, inB := ma&(1<<k) != 0, mb&(1<<k) != 0; inA != inB {
+					if inA {
+						return -1
+					}
+					return 1
+				}
+			}
+			return -fromLargest(a, b)
+		default:
+			return fromSmallest(a, b)
+		}
+	})
+}
+
+func toDistribution(sizes, counts []int) map[int]int {
+	out := map[int]int{}
+	for k, n := range counts {
+		if n > 0 {
+			out[sizes[k]] = n
+		}
+	}
+	return out
+}
+
+func total(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package calculator
+
+import "context"
+
+// UnreachableBound describes which quantities packSizes can pack exactly.
+// Quantities that are not multiples of gcd are never reachable, and every
+// multiple of gcd above bound is. Below bound, Unreachable lists the gaps.
+func UnreachableBound(packSizes []int) (gcd, bound int, err error) {
+	normalized, err := normalizePackSizes(packSizes)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	gcd = normalized[0]
+	for _, size := range normalized[1:] {
+		gcd = greatestCommonDivisor(gcd, size)
+	}
+
+	// Schur's bound on the Frobenius number of the sizes divided by their gcd.
+	smallest, largest := normalized[0]/gcd, normalized[len(normalized)-1]/gcd
+	if smallest == 1 {
+		return gcd, 0, nil
+	}
+	return gcd, ((smallest-1)*(largest-1) - 1) * gcd, nil
+}
+
+// Unreachable returns the quantities in [1, limit] that cannot be packed
+// exactly with packSizes, in ascending order.
+func Unreachable(ctx context.Context, packSizes []int, limit int) ([]int, error) {
+	if limit < 0 {
+		return nil, ErrInvalidItems
+	}
+	normalized, err := normalizePackSizes(packSizes)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make([]bool, limit+1)
+	reachable[0] = true
+	var missing []int
+	for amount := 1; amount <= limit; amount++ {
+		if amount%checkInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for _, size := range normalized {
+			if size > amount {
+				break
+			}
+			if reachable[amount-size] {
+				reachable[amount] = true
+				break
+			}
+		}
+		if !reachable[amount] {
+			missing = append(missing, amount)
+		}
+	}
+	return missing, nil
+}
+
+func greatestCommonDivisor(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
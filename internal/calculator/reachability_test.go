@@ -0,0 +1,64 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestUnreachable(t *testing.T) {
+	missing, err := Unreachable(context.Background(), []int{3, 5}, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []int{1, 2, 4, 7}; !slices.Equal(missing, want) {
+		t.Fatalf("expected %v, got %v", want, missing)
+	}
+
+	if _, err := Unreachable(context.Background(), nil, 10); !errors.Is(err, ErrInvalidPackSizes) {
+		t.Fatalf("expected ErrInvalidPackSizes, got %v", err)
+	}
+	if _, err := Unreachable(context.Background(), []int{3}, -1); !errors.Is(err, ErrInvalidItems) {
+		t.Fatalf("expected ErrInvalidItems, got %v", err)
+	}
+}
+
+func TestUnreachableBound(t *testing.T) {
+	tests := []struct {
+		sizes     []int
+		gcd       int
+		bound     int
+		frobenius int
+	}{
+		{sizes: []int{3, 5}, gcd: 1, bound: 7, frobenius: 7},
+		{sizes: []int{1, 9}, gcd: 1, bound: 0, frobenius: 0},
+		{sizes: []int{250, 500, 1000, 2000, 5000}, gcd: 250, bound: 0, frobenius: 0},
+		{sizes: []int{6, 10, 15}, gcd: 1, bound: 69, frobenius: 29},
+		{sizes: []int{4, 6}, gcd: 2, bound: 2, frobenius: 2},
+	}
+
+	for _, tc := range tests {
+		gcd, bound, err := UnreachableBound(tc.sizes)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.sizes, err)
+		}
+		if gcd != tc.gcd || bound != tc.bound {
+			t.Fatalf("%v: expected gcd %d and bound %d, got %d and %d", tc.sizes, tc.gcd, tc.bound, gcd, bound)
+		}
+
+		missing, err := Unreachable(context.Background(), tc.sizes, bound+10*gcd)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.sizes, err)
+		}
+		largest := 0
+		for _, amount := range missing {
+			if amount%gcd == 0 {
+				largest = amount
+			}
+		}
+		if largest != tc.frobenius {
+			t.Fatalf("%v: expected largest unreachable multiple of %d to be %d, got %d", tc.sizes, gcd, tc.frobenius, largest)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package calculator
+
+import (
+	"context"
+	"math"
+	"slices"
+)
+
+const (
+	// noChoice marks an amount that cannot be packed exactly.
+	noChoice = math.MaxUint8
+	// unreachable is the DP value of an amount that cannot be packed exactly.
+	unreachable = math.MaxInt
+	// windowCellBytes is the size of one DP value kept in a stage window.
+	windowCellBytes = 8
+)
+
+// dpTable is an immutable, growable solution table for one normalised
+// pack-size set.
+//
+// The table reproduces the classic size-by-size DP: sizes are applied in
+// ascending order and an amount only switches to a later size when it strictly
+// reduces the pack count. Because every stage visits amounts in increasing
+// order, the table can be extended to a larger bound by replaying each stage
+// over the new amounts only. To do so each stage keeps a window with its DP
+// values for the last sizes[k] amounts below the bound.
+type dpTable struct {
+	sizes []int
+	// choice[a] is the index into sizes of the last pack used for amount a, or
+	// noChoice when a cannot be packed exactly.
+	choice []uint8
+	// windows[k] holds the stage-k DP values for amounts
+	// (bound-sizes[k], bound], clamped at zero.
+	windows []stageWindow
+}
+
+type stageWindow struct {
+	start  int
+	values []int
+}
+
+func newDPTable(sizes []int) *dpTable {
+	windows := make([]stageWindow, len(sizes))
+	for k := range windows {
+		windows[k] = stageWindow{start: 0, values: []int{0}}
+	}
+	return &dpTable{
+		sizes:   slices.Clone(sizes),
+		choice:  []uint8{noChoice},
+		windows: windows,
+	}
+}
+
+// bound returns the largest amount answered by the table.
+func (t *dpTable) bound() int {
+	return len(t.choice) - 1
+}
+
+// memoryBytes estimates the memory used by the table.
+func (t *dpTable) memoryBytes() int64 {
+	return tableMemoryBytes(t.sizes, t.bound())
+}
+
+// tableMemoryBytes estimates the memory used by a table for sizes up to bound.
+// The estimate saturates at math.MaxInt64, so orders near math.MaxInt never
+// wrap around below a memory ceiling.
+func tableMemoryBytes(sizes []int, bound int) int64 {
+	total := uint64(bound) + 1
+	for _, size := range sizes {
+		cells := min(uint64(size), uint64(bound)+1)
+		if cells > math.MaxInt64/windowCellBytes {
+			return math.MaxInt64
+		}
+		if total += cells * windowCellBytes; total > math.MaxInt64 {
+			return math.MaxInt64
+		}
+	}
+	return int64(total)
+}
+
+// extend returns a new table answering every amount up to newBound. The
+// receiver is left untouched so concurrent readers can keep using it.
+func (t *dpTable) extend(ctx context.Context, newBound int, progress ProgressFunc) (*dpTable, error) {
+	oldBound := t.bound()
+	if newBound <= oldBound {
+		return t, nil
+	}
+
+	added := newBound - oldBound
+	choice := make([]uint8, newBound+1)
+	copy(choice, t.choice)
+	values := make([]int, added)
+	for i := range values {
+		values[i] = unreachable
+		choice[oldBound+1+i] = noChoice
+	}
+
+	totalWork := 0
+	for _, size := range t.sizes {
+		totalWork += max(0, newBound-max(size, oldBound+1)+1)
+	}
+	done := 0
+
+	windows := make([]stageWindow, len(t.sizes))
+	for k, size := range t.sizes {
+		window := t.windows[k]
+		for amount := max(size, oldBound+1); amount <= newBound; amount++ {
+			prev := amount - size
+			var prevValue int
+			if prev <= oldBound {
+				prevValue = window.values[prev-window.start]
+			} else {
+				prevValue = values[prev-oldBound-1]
+			}
+			if prevValue != unreachable && prevValue+1 < values[amount-oldBound-1] {
+				values[amount-oldBound-1] = prevValue + 1
+				choice[amount] = uint8(k)
+			}
+
+			done++
+			if done%checkInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				progress(float64(done) / float64(totalWork))
+			}
+		}
+
+		start := max(0, newBound-size+1)
+		next := stageWindow{start: start, values: make([]int, newBound-start+1)}
+		for amount := start; amount <= newBound; amount++ {
+			if amount <= oldBound {
+				next.values[amount-start] = window.values[amount-window.start]
+			} else {
+				next.values[amount-start] = values[amount-oldBound-1]
+			}
+		}
+		windows[k] = next
+	}
+
+	return &dpTable{
+		sizes:   t.sizes,
+		choice:  choice,
+		windows: windows,
+	}, nil
+}
+
+// reconstruct walks the choice table back from items and counts the packs
+// used. It runs in time proportional to the number of packs in the result.
+func (t *dpTable) reconstruct(items int) (map[int]int, error) {
+	if items > t.bound() {
+		return nil, ErrCannotFulfill
+	}
+	if t.choice[items] == noChoice && items > 0 {
+		return nil, ErrCannotFulfill
+	}
+
+	counts := make([]int, len(t.sizes))
+	for remaining := items; remaining > 0; {
+		k := t.choice[remaining]
+		if k == noChoice {
+			return nil, ErrCannotFulfill
+		}
+		counts[k]++
+		remaining -= t.sizes[k]
+	}
+
+	result := make(map[int]int, len(t.sizes))
+	for k, count := range counts {
+		if count > 0 {
+			result[t.sizes[k]] = count
+		}
+	}
+	return result, nil
+}
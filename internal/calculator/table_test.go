@@ -0,0 +1,208 @@
+package calculator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// referenceChoices is the original one-shot DP used to verify that growing a
+// table incrementally yields exactly the same decisions.
+func referenceChoices(sizes []int, bound int) []int {
+	dp := make([]int, bound+1)
+	choice := make([]int, bound+1)
+	for i := 1; i <= bound; i++ {
+		dp[i] = bound + 1
+		choice[i] = -1
+	}
+	for _, size := range sizes {
+		for amount := size; amount <= bound; amount++ {
+			if dp[amount-size]+1 < dp[amount] {
+				dp[amount] = dp[amount-size] + 1
+				choice[amount] = size
+			}
+		}
+	}
+	return choice
+}
+
+func TestDPTableIncrementalGrowthMatchesReference(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 50; trial++ {
+		sizes, err := normalizePackSizes([]int{1 + rng.Intn(60), 1 + rng.Intn(60), 1 + rng.Intn(200)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		final := 500 + rng.Intn(1500)
+		want := referenceChoices(sizes, final)
+
+		table := newDPTable(sizes)
+		for table.bound() < final {
+			next := min(final, table.bound()+1+rng.Intn(120))
+			table, err = table.extend(context.Background(), next, func(float64) {})
+			if err != nil {
+				t.Fatalf("extend returned error: %v", err)
+			}
+		}
+
+		for amount := 1; amount <= final; amount++ {
+			got := -1
+			if k := table.choice[amount]; k != noChoice {
+				got = sizes[k]
+			}
+			if got != want[amount] {
+				t.Fatalf("sizes %v amount %d: expected choice %d, got %d", sizes, amount, want[amount], got)
+			}
+		}
+	}
+}
+
+func TestDPTableExtendLeavesReceiverUntouched(t *testing.T) {
+	t.Parallel()
+
+	small, err := newDPTable([]int{3, 5}).extend(context.Background(), 10, func(float64) {})
+	if err != nil {
+		t.Fatalf("extend returned error: %v", err)
+	}
+	choices := slices.Clone(small.choice)
+
+	if _, err := small.extend(context.Background(), 100, func(float64) {}); err != nil {
+		t.Fatalf("extend returned error: %v", err)
+	}
+	if small.bound() != 10 || !slices.Equal(small.choice, choices) {
+		t.Fatalf("expected original table to remain unchanged")
+	}
+}
+
+func TestCalculatorGrowsActiveTableOnDemand(t *testing.T) {
+	t.Parallel()
+
+	calc := New().(*dpCalculator)
+	sizes := []int{23, 31, 53}
+
+	if _, err := calc.CalculatePacks(1000, sizes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := calc.TableStats(); stats.Bound != 1000 {
+		t.Fatalf("expected table bound 1000, got %d", stats.Bound)
+	}
+
+	if _, err := calc.CalculatePacks(1500, sizes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := calc.TableStats(); stats.Bound != 2000 {
+		t.Fatalf("expected table to double to 2000, got %d", stats.Bound)
+	}
+
+	if _, err := calc.CalculatePacks(1500, []int{7, 13}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := calc.TableStats(); !slices.Equal(stats.PackSizes, []int{23, 31, 53}) {
+		t.Fatalf("expected other pack sizes not to replace the active table, got %v", stats.PackSizes)
+	}
+}
+
+func TestCalculatorBuildsOtherTablesWithoutTheLock(t *testing.T) {
+	t.Parallel()
+
+	calc := New().(*dpCalculator)
+	if _, err := calc.CalculatePacks(1000, []int{23, 31, 53}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A slow growth of the active table holds the lock.
+	calc.mu.Lock()
+	defer calc.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := calc.CalculatePacks(1500, []int{7, 13})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected a calculation for other sizes not to wait for the lock")
+	}
+}
+
+func TestCalculatorRespectsTableMemoryCeiling(t *testing.T) {
+	t.Parallel()
+
+	calc := New(WithMaxTableBytes(10_000)).(*dpCalculator)
+	got, err := calc.CalculatePacks(500_000, []int{23, 31, 53})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[53] != 9429 {
+		t.Fatalf("unexpected result: %v", got)
+	}
+	if stats := calc.TableStats(); stats.Bytes > 10_000 {
+		t.Fatalf("expected retained table within ceiling, got %d bytes", stats.Bytes)
+	}
+}
+
+func TestCalculatorStrictTableLimit(t *testing.T) {
+	t.Parallel()
+
+	calc := New(WithMaxTableBytes(10_000), WithStrictTableLimit())
+	if _, err := calc.CalculatePacks(500, []int{23, 31, 53}); err != nil {
+		t.Fatalf("unexpected error within the ceiling: %v", err)
+	}
+	for _, items := range []int{500_000, math.MaxInt} {
+		if _, err := calc.CalculatePacks(items, []int{23, 31, 53}); !errors.Is(err, ErrOrderTooLarge) {
+			t.Fatalf("expected ErrOrderTooLarge for %d items, got %v", items, err)
+		}
+	}
+}
+
+func TestCalculatorWarmReplacesActiveSet(t *testing.T) {
+	t.Parallel()
+
+	calc := New().(*dpCalculator)
+	if _, err := calc.CalculatePacks(5000, []int{250, 500}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := calc.Warm(context.Background(), []int{53, 31, 23}); err != nil {
+		t.Fatalf("Warm returned error: %v", err)
+	}
+	stats := calc.TableStats()
+	if !slices.Equal(stats.PackSizes, []int{23, 31, 53}) {
+		t.Fatalf("expected warmed pack sizes to become active, got %v", stats.PackSizes)
+	}
+	if stats.Bound != 5000 {
+		t.Fatalf("expected warmed table to reach previous bound 5000, got %d", stats.Bound)
+	}
+
+	if err := calc.Warm(context.Background(), nil); !errors.Is(err, ErrInvalidPackSizes) {
+		t.Fatalf("expected ErrInvalidPackSizes, got %v", err)
+	}
+}
+
+func TestCalculatorWarmHonoursCancellation(t *testing.T) {
+	t.Parallel()
+
+	calc := New().(*dpCalculator)
+	if _, err := calc.CalculatePacks(500_000, []int{250, 500}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := calc.Warm(ctx, []int{23, 31, 53}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if stats := calc.TableStats(); !slices.Equal(stats.PackSizes, []int{250, 500}) {
+		t.Fatalf("expected cancelled warm to keep the previous table, got %v", stats.PackSizes)
+	}
+}
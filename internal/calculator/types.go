@@ -1,5 +1,7 @@
 package calculator
 
+import "context"
+
 // PackResult represents a summary of the packing calculation.
 // TotalPacks and TotalItems are derived values that callers can use when they
 // need aggregated information in addition to the raw distribution.
@@ -13,3 +15,24 @@ type PackResult struct {
 type Calculator interface {
 	CalculatePacks(items int, packSizes []int) (map[int]int, error)
 }
+
+// ContextCalculator is implemented by calculators that honour context
+// cancellation and report progress registered through WithProgress.
+type ContextCalculator interface {
+	Calculator
+	CalculatePacksContext(ctx context.Context, items int, packSizes []int) (map[int]int, error)
+}
+
+// Warmer is implemented by calculators that can precompute state for a
+// pack-size set before requests arrive.
+type Warmer interface {
+	Warm(ctx context.Context, packSizes []int) error
+}
+
+// TableStats describes the reusable DP table kept by the calculator.
+type TableStats struct {
+	PackSizes []int `json:"packSizes"`
+	Bound     int   `json:"bound"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"maxBytes"`
+}
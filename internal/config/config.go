@@ -1,13 +1,20 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/cors"
+	"github.com/eugenenazirov/re-partners/internal/httpcompress"
+	"github.com/eugenenazirov/re-partners/internal/logging"
 	"github.com/eugenenazirov/re-partners/internal/storage"
+	"github.com/eugenenazirov/re-partners/internal/tlsconfig"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,20 +22,83 @@ const (
 	defaultPort           = "8080"
 	defaultRateLimitRPS   = 25.0
 	defaultRateLimitBurst = 50
+	defaultIdempotencyTTL = 24 * time.Hour
+	defaultJobWorkers     = 2
+	defaultJobQueueSize   = 100
+	defaultJobRetention   = time.Hour
+	defaultCacheEntries   = 10_000
+	defaultCacheBytes     = 64 << 20
+	defaultDPTableBytes   = 64 << 20
+	defaultHistoryEntries = 10_000
+	defaultCORSMaxAge     = 24 * time.Hour
 )
 
 // Config aggregates runtime configuration resolved from multiple sources.
-// Precedence: CLI flags > YAML config > Environment variables > Defaults
+// Precedence: CLI flags > Environment variables > YAML config > Defaults
 type Config struct {
 	Port                 string        `yaml:"port"`
 	InitialPackSizes     []int         `yaml:"pack_sizes"`
 	ShutdownGracePeriod  time.Duration `yaml:"shutdown_grace_period"`
+	ShutdownDrainDelay   time.Duration `yaml:"shutdown_drain_delay"`
 	ReadHeaderTimeout    time.Duration `yaml:"read_header_timeout"`
 	WriteTimeout         time.Duration `yaml:"write_timeout"`
 	IdleTimeout          time.Duration `yaml:"idle_timeout"`
 	EnableRequestLogging bool          `yaml:"enable_request_logging"`
 	RateLimitRPS         float64       `yaml:"-"`
 	RateLimitBurst       int           `yaml:"-"`
+	IdempotencyTTL       time.Duration `yaml:"idempotency_ttl"`
+	JobWorkers           int           `yaml:"-"`
+	JobQueueSize         int           `yaml:"-"`
+	JobRetention         time.Duration `yaml:"-"`
+	CacheMaxEntries      int           `yaml:"-"`
+	CacheMaxBytes        int64         `yaml:"-"`
+	DPTableMaxBytes      int64         `yaml:"dp_table_max_bytes"`
+	TieBreak             string        `yaml:"tie_break"`
+	HistoryMaxEntries    int           `yaml:"-"`
+	CompressionMinSize   int           `yaml:"-"`
+	// Listen lists the addresses serving the API: TCP addresses,
+	// "unix:/path" sockets created with UnixSocketMode, and "systemd" or
+	// "systemd:NAME" sockets passed by socket activation. Port is used when
+	// it is empty.
+	Listen         []string `yaml:"-"`
+	UnixSocketMode string   `yaml:"-"`
+	// Log configures the logger. LogSampleInitial and LogSampleThereafter
+	// sample the access logs; both zero logs every request.
+	LogLevel            string   `yaml:"-"`
+	LogFormat           string   `yaml:"-"`
+	LogOutput           []string `yaml:"-"`
+	LogSampleInitial    int      `yaml:"-"`
+	LogSampleThereafter int      `yaml:"-"`
+	// WebDir serves the UI from a directory instead of the files embedded
+	// in the binary, for UI development.
+	WebDir string `yaml:"web_dir"`
+	// TLS serves HTTPS when TLSCertFile and TLSKeyFile are set;
+	// TLSClientCAFile additionally enables mutual TLS.
+	TLSCertFile     string `yaml:"-"`
+	TLSKeyFile      string `yaml:"-"`
+	TLSMinVersion   string `yaml:"-"`
+	TLSClientCAFile string `yaml:"-"`
+	TLSClientAuth   string `yaml:"-"`
+	// AdminToken enables the admin endpoints for requests presenting it as
+	// a bearer token. It is a secret and is redacted when shown.
+	AdminToken string `yaml:"-"`
+	// AdminListen serves the admin endpoints on their own listeners instead
+	// of alongside the API.
+	AdminListen []string `yaml:"-"`
+	// AdminDiagnostics adds profiling and runtime diagnostics to the admin
+	// endpoints. It requires AdminListen so they are never public.
+	AdminDiagnostics bool `yaml:"-"`
+	// CORS allows cross-origin browser requests from CORSAllowedOrigins:
+	// exact origins, "scheme://*.domain" wildcard subdomains or "*". None
+	// are allowed by default.
+	CORSAllowedOrigins   []string      `yaml:"-"`
+	CORSAllowedMethods   []string      `yaml:"-"`
+	CORSAllowedHeaders   []string      `yaml:"-"`
+	CORSAllowCredentials bool          `yaml:"-"`
+	CORSMaxAge           time.Duration `yaml:"-"`
+	// Sources records where each setting came from, keyed by its YAML key
+	// (e.g. "rate_limit.rps"). Settings missing from it have their default.
+	Sources map[string]Source `yaml:"-"`
 }
 
 // yamlConfig represents the YAML configuration file structure.
@@ -36,17 +106,94 @@ type yamlConfig struct {
 	Port                 string        `yaml:"port"`
 	PackSizes            []int         `yaml:"pack_sizes"`
 	ShutdownGracePeriod  string        `yaml:"shutdown_grace_period"`
+	ShutdownDrainDelay   string        `yaml:"shutdown_drain_delay"`
 	ReadHeaderTimeout    string        `yaml:"read_header_timeout"`
 	WriteTimeout         string        `yaml:"write_timeout"`
 	IdleTimeout          string        `yaml:"idle_timeout"`
-	EnableRequestLogging bool          `yaml:"enable_request_logging"`
+	EnableRequestLogging *bool         `yaml:"enable_request_logging"`
 	RateLimit            yamlRateLimit `yaml:"rate_limit"`
+	IdempotencyTTL       string        `yaml:"idempotency_ttl"`
+	Jobs                 yamlJobs      `yaml:"jobs"`
+	Cache                yamlCache     `yaml:"cache"`
+	DPTableMaxBytes      *int64        `yaml:"dp_table_max_bytes"`
+	TieBreak             string        `yaml:"tie_break"`
+	History              yamlHistory   `yaml:"history"`
+	Compression          yamlCompress  `yaml:"compression"`
+	Listen               []string      `yaml:"listen"`
+	UnixSocketMode       string        `yaml:"unix_socket_mode"`
+	WebDir               string        `yaml:"web_dir"`
+	Log                  yamlLog       `yaml:"log"`
+	TLS                  yamlTLS       `yaml:"tls"`
+	Admin                yamlAdmin     `yaml:"admin"`
+	CORS                 yamlCORS      `yaml:"cors"`
 }
 
 // yamlRateLimit represents the rate limit section in YAML.
 type yamlRateLimit struct {
-	RPS   float64 `yaml:"rps"`
-	Burst int     `yaml:"burst"`
+	RPS   *float64 `yaml:"rps"`
+	Burst *int     `yaml:"burst"`
+}
+
+// yamlJobs represents the asynchronous jobs section in YAML.
+type yamlJobs struct {
+	Workers   *int   `yaml:"workers"`
+	QueueSize *int   `yaml:"queue_size"`
+	Retention string `yaml:"retention"`
+}
+
+// yamlCache represents the result cache section in YAML.
+type yamlCache struct {
+	MaxEntries *int   `yaml:"max_entries"`
+	MaxBytes   *int64 `yaml:"max_bytes"`
+}
+
+// yamlHistory represents the calculation history section in YAML.
+type yamlHistory struct {
+	MaxEntries *int `yaml:"max_entries"`
+}
+
+// yamlCompress represents the response compression section in YAML.
+type yamlCompress struct {
+	MinSize *int `yaml:"min_size"`
+}
+
+// yamlLog represents the logging section in YAML.
+type yamlLog struct {
+	Level    string          `yaml:"level"`
+	Format   string          `yaml:"format"`
+	Output   []string        `yaml:"output"`
+	Sampling yamlLogSampling `yaml:"sampling"`
+}
+
+// yamlLogSampling represents the access log sampling section in YAML.
+type yamlLogSampling struct {
+	Initial    *int `yaml:"initial"`
+	Thereafter *int `yaml:"thereafter"`
+}
+
+// yamlTLS represents the TLS section in YAML.
+type yamlTLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	MinVersion   string `yaml:"min_version"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+}
+
+// yamlAdmin represents the admin endpoints section in YAML.
+type yamlAdmin struct {
+	Token       string   `yaml:"token"`
+	Listen      []string `yaml:"listen"`
+	Diagnostics *bool    `yaml:"diagnostics"`
+}
+
+// yamlCORS represents the CORS section in YAML.
+type yamlCORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	AllowCredentials *bool    `yaml:"allow_credentials"`
+	MaxAge           string   `yaml:"max_age"`
 }
 
 // CLIOverrides holds command-line flag overrides.
@@ -56,38 +203,88 @@ type CLIOverrides struct {
 	PackSizesStr   *string
 	RateLimitRPS   *float64
 	RateLimitBurst *int
+	Listen         []string
+	LogLevel       *string
+	LogFormat      *string
+	LogOutput      []string
+	// LogSampleInitial and LogSampleThereafter are ignored when negative.
+	LogSampleInitial    *int
+	LogSampleThereafter *int
+	// Strict overrides the CONFIG_STRICT environment variable. Strict
+	// loading, the default, fails with a *ValidationError listing every
+	// unparsable or invalid value and unknown YAML key; lenient loading
+	// ignores unparsable values and reports only the first invalid one.
+	Strict *bool
 }
 
 // Load extracts configuration from multiple sources with precedence:
-// CLI flags > YAML config > Environment variables > Defaults
+// CLI flags > Environment variables > YAML config > Defaults
 func Load(overrides *CLIOverrides) (Config, error) {
+	cfg, res, err := resolve(overrides)
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg.Sources = res.sources
+
+	if !strictMode(overrides) {
+		// Validate final configuration
+		if err := validateConfig(cfg); err != nil {
+			return Config{}, err
+		}
+		return cfg, nil
+	}
+
+	for _, c := range checkConfig(cfg) {
+		res.reject(res.source(c.key), c.key, "%s", c.message)
+	}
+	if len(res.problems) > 0 {
+		return Config{}, &ValidationError{Problems: res.problems}
+	}
+	return cfg, nil
+}
+
+// resolve merges the sources without validating the result. Problems with
+// individual values are recorded in the returned resolution; in lenient
+// mode an invalid --pack-sizes flag is returned as an error instead.
+func resolve(overrides *CLIOverrides) (Config, *resolution, error) {
 	cfg := defaultConfig()
+	res := newResolution()
+	strict := strictMode(overrides)
 
 	// Load from YAML file if specified
 	if overrides != nil && overrides.ConfigFile != "" {
-		yamlCfg, err := loadFromFile(overrides.ConfigFile)
+		yamlCfg, problems, err := loadFromFile(overrides.ConfigFile, strict)
 		if err != nil {
-			return Config{}, fmt.Errorf("load YAML config: %w", err)
+			return Config{}, nil, fmt.Errorf("load YAML config: %w", err)
 		}
-		applyYAMLConfig(&cfg, yamlCfg)
+		res.problems = append(res.problems, problems...)
+		applyYAMLConfig(&cfg, yamlCfg, res)
 	}
 
 	// Apply environment variables (override YAML)
-	applyEnvConfig(&cfg)
+	applyEnvConfig(&cfg, res)
 
 	// Apply CLI overrides (highest precedence)
 	if overrides != nil {
-		if err := applyCLIOverrides(&cfg, overrides); err != nil {
-			return Config{}, err
+		if err := applyCLIOverrides(&cfg, overrides, res); err != nil && !strict {
+			return Config{}, nil, err
 		}
 	}
 
-	// Validate final configuration
-	if err := validateConfig(cfg); err != nil {
-		return Config{}, err
-	}
+	return cfg, res, nil
+}
 
-	return cfg, nil
+// strictMode reports whether overrides or CONFIG_STRICT ask for strict
+// loading. It defaults to strict; an unparsable CONFIG_STRICT keeps it.
+func strictMode(overrides *CLIOverrides) bool {
+	if overrides != nil && overrides.Strict != nil {
+		return *overrides.Strict
+	}
+	if strict, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("CONFIG_STRICT"))); err == nil {
+		return strict
+	}
+	return true
 }
 
 // defaultConfig returns a Config with default values.
@@ -102,137 +299,468 @@ func defaultConfig() Config {
 		EnableRequestLogging: true,
 		RateLimitRPS:         defaultRateLimitRPS,
 		RateLimitBurst:       defaultRateLimitBurst,
+		IdempotencyTTL:       defaultIdempotencyTTL,
+		JobWorkers:           defaultJobWorkers,
+		JobQueueSize:         defaultJobQueueSize,
+		JobRetention:         defaultJobRetention,
+		CacheMaxEntries:      defaultCacheEntries,
+		CacheMaxBytes:        defaultCacheBytes,
+		DPTableMaxBytes:      defaultDPTableBytes,
+		TieBreak:             string(calculator.TieBreakAscendingSizeFirst),
+		HistoryMaxEntries:    defaultHistoryEntries,
+		CompressionMinSize:   httpcompress.DefaultMinSize,
+		UnixSocketMode:       "0660",
+		LogLevel:             "info",
+		LogFormat:            logging.FormatJSON,
+		LogOutput:            []string{"stderr"},
+		TLSMinVersion:        "1.2",
+		TLSClientAuth:        tlsconfig.ClientAuthRequire,
+		CORSAllowedMethods:   slices.Clone(cors.DefaultMethods),
+		CORSAllowedHeaders:   slices.Clone(cors.DefaultHeaders),
+		CORSMaxAge:           defaultCORSMaxAge,
 	}
 }
 
-// loadFromFile loads configuration from a YAML file.
-func loadFromFile(path string) (*yamlConfig, error) {
+// loadFromFile loads configuration from a YAML file. In strict mode, values
+// of the wrong type and unknown keys are returned as problems; otherwise a
+// type mismatch fails the load and unknown keys are ignored.
+func loadFromFile(path string, strict bool) (*yamlConfig, []Problem, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read file: %w", err)
+		return nil, nil, fmt.Errorf("read file: %w", err)
 	}
 
 	var yamlCfg yamlConfig
+	var problems []Problem
 	if err := yaml.Unmarshal(data, &yamlCfg); err != nil {
-		return nil, fmt.Errorf("parse YAML: %w", err)
+		var typeErr *yaml.TypeError
+		if !strict || !errors.As(err, &typeErr) {
+			return nil, nil, fmt.Errorf("parse YAML: %w", err)
+		}
+		for _, message := range typeErr.Errors {
+			problems = append(problems, Problem{Source: SourceYAML, Message: message})
+		}
 	}
 
-	return &yamlCfg, nil
+	if strict {
+		unknown, err := unknownYAMLKeys(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse YAML: %w", err)
+		}
+		problems = append(problems, unknown...)
+	}
+
+	return &yamlCfg, problems, nil
 }
 
-// applyYAMLConfig applies YAML configuration to the Config struct.
-func applyYAMLConfig(cfg *Config, yamlCfg *yamlConfig) {
+// applyYAMLConfig applies YAML configuration to the Config struct, recording
+// the values it sets and the durations it cannot parse in res.
+func applyYAMLConfig(cfg *Config, yamlCfg *yamlConfig, res *resolution) {
 	if yamlCfg.Port != "" {
 		cfg.Port = yamlCfg.Port
+		res.set(SourceYAML, "port")
 	}
 
 	if len(yamlCfg.PackSizes) > 0 {
 		cfg.InitialPackSizes = yamlCfg.PackSizes
+		res.set(SourceYAML, "pack_sizes")
 	}
 
-	if yamlCfg.ShutdownGracePeriod != "" {
-		if d, err := time.ParseDuration(yamlCfg.ShutdownGracePeriod); err == nil {
-			cfg.ShutdownGracePeriod = d
-		}
+	res.yamlDuration("shutdown_grace_period", yamlCfg.ShutdownGracePeriod, &cfg.ShutdownGracePeriod)
+	res.yamlDuration("shutdown_drain_delay", yamlCfg.ShutdownDrainDelay, &cfg.ShutdownDrainDelay)
+	res.yamlDuration("read_header_timeout", yamlCfg.ReadHeaderTimeout, &cfg.ReadHeaderTimeout)
+	res.yamlDuration("write_timeout", yamlCfg.WriteTimeout, &cfg.WriteTimeout)
+	res.yamlDuration("idle_timeout", yamlCfg.IdleTimeout, &cfg.IdleTimeout)
+
+	if yamlCfg.EnableRequestLogging != nil {
+		cfg.EnableRequestLogging = *yamlCfg.EnableRequestLogging
+		res.set(SourceYAML, "enable_request_logging")
 	}
 
-	if yamlCfg.ReadHeaderTimeout != "" {
-		if d, err := time.ParseDuration(yamlCfg.ReadHeaderTimeout); err == nil {
-			cfg.ReadHeaderTimeout = d
+	if rps := yamlCfg.RateLimit.RPS; rps != nil {
+		if *rps >= 0 {
+			cfg.RateLimitRPS = *rps
+			res.set(SourceYAML, "rate_limit.rps")
+		} else {
+			res.reject(SourceYAML, "rate_limit.rps", "must be >= 0, got %v", *rps)
 		}
 	}
 
-	if yamlCfg.WriteTimeout != "" {
-		if d, err := time.ParseDuration(yamlCfg.WriteTimeout); err == nil {
-			cfg.WriteTimeout = d
+	if burst := yamlCfg.RateLimit.Burst; burst != nil {
+		if *burst >= 0 {
+			cfg.RateLimitBurst = *burst
+			res.set(SourceYAML, "rate_limit.burst")
+		} else {
+			res.reject(SourceYAML, "rate_limit.burst", "must be >= 0, got %d", *burst)
 		}
 	}
 
-	if yamlCfg.IdleTimeout != "" {
-		if d, err := time.ParseDuration(yamlCfg.IdleTimeout); err == nil {
-			cfg.IdleTimeout = d
+	res.yamlDuration("idempotency_ttl", yamlCfg.IdempotencyTTL, &cfg.IdempotencyTTL)
+
+	if yamlCfg.Jobs.Workers != nil {
+		cfg.JobWorkers = *yamlCfg.Jobs.Workers
+		res.set(SourceYAML, "jobs.workers")
+	}
+
+	if yamlCfg.Jobs.QueueSize != nil {
+		cfg.JobQueueSize = *yamlCfg.Jobs.QueueSize
+		res.set(SourceYAML, "jobs.queue_size")
+	}
+
+	res.yamlDuration("jobs.retention", yamlCfg.Jobs.Retention, &cfg.JobRetention)
+
+	if yamlCfg.Cache.MaxEntries != nil {
+		cfg.CacheMaxEntries = *yamlCfg.Cache.MaxEntries
+		res.set(SourceYAML, "cache.max_entries")
+	}
+
+	if yamlCfg.Cache.MaxBytes != nil {
+		cfg.CacheMaxBytes = *yamlCfg.Cache.MaxBytes
+		res.set(SourceYAML, "cache.max_bytes")
+	}
+
+	if yamlCfg.DPTableMaxBytes != nil {
+		cfg.DPTableMaxBytes = *yamlCfg.DPTableMaxBytes
+		res.set(SourceYAML, "dp_table_max_bytes")
+	}
+
+	if yamlCfg.TieBreak != "" {
+		cfg.TieBreak = yamlCfg.TieBreak
+		res.set(SourceYAML, "tie_break")
+	}
+
+	if yamlCfg.History.MaxEntries != nil {
+		cfg.HistoryMaxEntries = *yamlCfg.History.MaxEntries
+		res.set(SourceYAML, "history.max_entries")
+	}
+
+	if yamlCfg.Compression.MinSize != nil {
+		cfg.CompressionMinSize = *yamlCfg.Compression.MinSize
+		res.set(SourceYAML, "compression.min_size")
+	}
+
+	if len(yamlCfg.Listen) > 0 {
+		cfg.Listen = yamlCfg.Listen
+		res.set(SourceYAML, "listen")
+	}
+
+	if yamlCfg.UnixSocketMode != "" {
+		cfg.UnixSocketMode = yamlCfg.UnixSocketMode
+		res.set(SourceYAML, "unix_socket_mode")
+	}
+
+	if yamlCfg.WebDir != "" {
+		cfg.WebDir = yamlCfg.WebDir
+		res.set(SourceYAML, "web_dir")
+	}
+
+	if yamlCfg.Log.Level != "" {
+		cfg.LogLevel = yamlCfg.Log.Level
+		res.set(SourceYAML, "log.level")
+	}
+
+	if yamlCfg.Log.Format != "" {
+		cfg.LogFormat = yamlCfg.Log.Format
+		res.set(SourceYAML, "log.format")
+	}
+
+	if len(yamlCfg.Log.Output) > 0 {
+		cfg.LogOutput = yamlCfg.Log.Output
+		res.set(SourceYAML, "log.output")
+	}
+
+	if yamlCfg.Log.Sampling.Initial != nil {
+		cfg.LogSampleInitial = *yamlCfg.Log.Sampling.Initial
+		res.set(SourceYAML, "log.sampling.initial")
+	}
+
+	if yamlCfg.Log.Sampling.Thereafter != nil {
+		cfg.LogSampleThereafter = *yamlCfg.Log.Sampling.Thereafter
+		res.set(SourceYAML, "log.sampling.thereafter")
+	}
+
+	for _, value := range []struct {
+		key string
+		raw string
+		dst *string
+	}{
+		{"tls.cert_file", yamlCfg.TLS.CertFile, &cfg.TLSCertFile},
+		{"tls.key_file", yamlCfg.TLS.KeyFile, &cfg.TLSKeyFile},
+		{"tls.min_version", yamlCfg.TLS.MinVersion, &cfg.TLSMinVersion},
+		{"tls.client_ca_file", yamlCfg.TLS.ClientCAFile, &cfg.TLSClientCAFile},
+		{"tls.client_auth", yamlCfg.TLS.ClientAuth, &cfg.TLSClientAuth},
+	} {
+		if value.raw != "" {
+			*value.dst = value.raw
+			res.set(SourceYAML, value.key)
 		}
 	}
 
-	cfg.EnableRequestLogging = yamlCfg.EnableRequestLogging
+	if yamlCfg.Admin.Token != "" {
+		cfg.AdminToken = yamlCfg.Admin.Token
+		res.set(SourceYAML, "admin.token")
+	}
+
+	if len(yamlCfg.Admin.Listen) > 0 {
+		cfg.AdminListen = yamlCfg.Admin.Listen
+		res.set(SourceYAML, "admin.listen")
+	}
+
+	if yamlCfg.Admin.Diagnostics != nil {
+		cfg.AdminDiagnostics = *yamlCfg.Admin.Diagnostics
+		res.set(SourceYAML, "admin.diagnostics")
+	}
 
-	if yamlCfg.RateLimit.RPS >= 0 {
-		cfg.RateLimitRPS = yamlCfg.RateLimit.RPS
+	for _, list := range []struct {
+		key    string
+		values []string
+		dst    *[]string
+	}{
+		{"cors.allowed_origins", yamlCfg.CORS.AllowedOrigins, &cfg.CORSAllowedOrigins},
+		{"cors.allowed_methods", yamlCfg.CORS.AllowedMethods, &cfg.CORSAllowedMethods},
+		{"cors.allowed_headers", yamlCfg.CORS.AllowedHeaders, &cfg.CORSAllowedHeaders},
+	} {
+		if len(list.values) > 0 {
+			*list.dst = list.values
+			res.set(SourceYAML, list.key)
+		}
 	}
 
-	if yamlCfg.RateLimit.Burst >= 0 {
-		cfg.RateLimitBurst = yamlCfg.RateLimit.Burst
+	if yamlCfg.CORS.AllowCredentials != nil {
+		cfg.CORSAllowCredentials = *yamlCfg.CORS.AllowCredentials
+		res.set(SourceYAML, "cors.allow_credentials")
 	}
+
+	res.yamlDuration("cors.max_age", yamlCfg.CORS.MaxAge, &cfg.CORSMaxAge)
 }
 
-// applyEnvConfig applies environment variable configuration.
-func applyEnvConfig(cfg *Config) {
-	if port := strings.TrimSpace(os.Getenv("PORT")); port != "" {
+// applyEnvConfig applies environment variable configuration, recording the
+// values it sets and the ones it rejects in res.
+func applyEnvConfig(cfg *Config, res *resolution) {
+	lookup := func(name string) string {
+		return strings.TrimSpace(os.Getenv(name))
+	}
+	atoi := strconv.Atoi
+	parseInt64 := func(raw string) (int64, error) {
+		return strconv.ParseInt(raw, 10, 64)
+	}
+	parseFloat := func(raw string) (float64, error) {
+		return strconv.ParseFloat(raw, 64)
+	}
+
+	if port := lookup("PORT"); port != "" {
 		cfg.Port = port
+		res.set(SourceEnv, "port")
 	}
 
-	if rawSizes := strings.TrimSpace(os.Getenv("PACK_SIZES")); rawSizes != "" {
-		sizes, err := parsePackSizes(rawSizes)
-		if err == nil && len(sizes) > 0 {
+	if rawSizes := lookup("PACK_SIZES"); rawSizes != "" {
+		sizes, err := ParsePackSizes(rawSizes)
+		if err != nil {
+			res.reject(SourceEnv, "pack_sizes", "%v", err)
+		} else {
 			cfg.InitialPackSizes = sizes
+			res.set(SourceEnv, "pack_sizes")
+		}
+	}
+
+	envValue(res, "rate_limit.rps", lookup, parseFloat, "number", &cfg.RateLimitRPS)
+	envValue(res, "rate_limit.burst", lookup, atoi, "integer", &cfg.RateLimitBurst)
+	envValue(res, "idempotency_ttl", lookup, time.ParseDuration, "duration", &cfg.IdempotencyTTL)
+	envValue(res, "jobs.workers", lookup, atoi, "integer", &cfg.JobWorkers)
+	envValue(res, "jobs.queue_size", lookup, atoi, "integer", &cfg.JobQueueSize)
+	envValue(res, "jobs.retention", lookup, time.ParseDuration, "duration", &cfg.JobRetention)
+	envValue(res, "cache.max_entries", lookup, atoi, "integer", &cfg.CacheMaxEntries)
+	envValue(res, "cache.max_bytes", lookup, parseInt64, "integer", &cfg.CacheMaxBytes)
+	envValue(res, "dp_table_max_bytes", lookup, parseInt64, "integer", &cfg.DPTableMaxBytes)
+
+	if tieBreak := lookup("TIE_BREAK"); tieBreak != "" {
+		cfg.TieBreak = tieBreak
+		res.set(SourceEnv, "tie_break")
+	}
+
+	envValue(res, "history.max_entries", lookup, atoi, "integer", &cfg.HistoryMaxEntries)
+	envValue(res, "compression.min_size", lookup, atoi, "integer", &cfg.CompressionMinSize)
+
+	if listen := lookup("LISTEN"); listen != "" {
+		cfg.Listen = splitList(listen)
+		res.set(SourceEnv, "listen")
+	}
+
+	if mode := lookup("UNIX_SOCKET_MODE"); mode != "" {
+		cfg.UnixSocketMode = mode
+		res.set(SourceEnv, "unix_socket_mode")
+	}
+
+	if webDir := lookup("WEB_DIR"); webDir != "" {
+		cfg.WebDir = webDir
+		res.set(SourceEnv, "web_dir")
+	}
+
+	if level := lookup("LOG_LEVEL"); level != "" {
+		cfg.LogLevel = level
+		res.set(SourceEnv, "log.level")
+	}
+
+	if format := lookup("LOG_FORMAT"); format != "" {
+		cfg.LogFormat = format
+		res.set(SourceEnv, "log.format")
+	}
+
+	if output := lookup("LOG_OUTPUT"); output != "" {
+		cfg.LogOutput = splitList(output)
+		res.set(SourceEnv, "log.output")
+	}
+
+	envValue(res, "log.sampling.initial", lookup, atoi, "integer", &cfg.LogSampleInitial)
+	envValue(res, "log.sampling.thereafter", lookup, atoi, "integer", &cfg.LogSampleThereafter)
+
+	for _, value := range []struct {
+		key string
+		dst *string
+	}{
+		{"tls.cert_file", &cfg.TLSCertFile},
+		{"tls.key_file", &cfg.TLSKeyFile},
+		{"tls.min_version", &cfg.TLSMinVersion},
+		{"tls.client_ca_file", &cfg.TLSClientCAFile},
+		{"tls.client_auth", &cfg.TLSClientAuth},
+	} {
+		if raw := lookup(settingFor(value.key).env); raw != "" {
+			*value.dst = raw
+			res.set(SourceEnv, value.key)
 		}
 	}
 
-	if rps := strings.TrimSpace(os.Getenv("RATE_LIMIT_RPS")); rps != "" {
-		if value, err := strconv.ParseFloat(rps, 64); err == nil && value >= 0 {
-			cfg.RateLimitRPS = value
+	if token := lookup("ADMIN_TOKEN"); token != "" {
+		cfg.AdminToken = token
+		res.set(SourceEnv, "admin.token")
+	}
+
+	if listen := lookup("ADMIN_LISTEN"); listen != "" {
+		cfg.AdminListen = splitList(listen)
+		res.set(SourceEnv, "admin.listen")
+	}
+
+	if raw := lookup("ADMIN_DIAGNOSTICS"); raw != "" {
+		if enabled, err := strconv.ParseBool(raw); err != nil {
+			res.reject(SourceEnv, "admin.diagnostics", "invalid boolean %q", raw)
+		} else {
+			cfg.AdminDiagnostics = enabled
+			res.set(SourceEnv, "admin.diagnostics")
 		}
 	}
 
-	if burst := strings.TrimSpace(os.Getenv("RATE_LIMIT_BURST")); burst != "" {
-		if value, err := strconv.Atoi(burst); err == nil && value >= 0 {
-			cfg.RateLimitBurst = value
+	for _, list := range []struct {
+		key string
+		dst *[]string
+	}{
+		{"cors.allowed_origins", &cfg.CORSAllowedOrigins},
+		{"cors.allowed_methods", &cfg.CORSAllowedMethods},
+		{"cors.allowed_headers", &cfg.CORSAllowedHeaders},
+	} {
+		if raw := lookup(settingFor(list.key).env); raw != "" {
+			*list.dst = splitList(raw)
+			res.set(SourceEnv, list.key)
 		}
 	}
+
+	if raw := lookup("CORS_ALLOW_CREDENTIALS"); raw != "" {
+		if allowed, err := strconv.ParseBool(raw); err != nil {
+			res.reject(SourceEnv, "cors.allow_credentials", "invalid boolean %q", raw)
+		} else {
+			cfg.CORSAllowCredentials = allowed
+			res.set(SourceEnv, "cors.allow_credentials")
+		}
+	}
+
+	envValue(res, "cors.max_age", lookup, time.ParseDuration, "duration", &cfg.CORSMaxAge)
 }
 
-// applyCLIOverrides applies command-line flag overrides.
-func applyCLIOverrides(cfg *Config, overrides *CLIOverrides) error {
+// applyCLIOverrides applies command-line flag overrides. An invalid
+// --pack-sizes value is recorded in res and returned.
+func applyCLIOverrides(cfg *Config, overrides *CLIOverrides, res *resolution) error {
 	if overrides.Port != nil && *overrides.Port != "" {
 		cfg.Port = *overrides.Port
+		res.set(SourceFlag, "port")
 	}
 
 	if overrides.PackSizesStr != nil && *overrides.PackSizesStr != "" {
-		sizes, err := parsePackSizes(*overrides.PackSizesStr)
+		sizes, err := ParsePackSizes(*overrides.PackSizesStr)
 		if err != nil {
+			res.reject(SourceFlag, "pack_sizes", "%v", err)
 			return fmt.Errorf("parse pack sizes: %w", err)
 		}
 		cfg.InitialPackSizes = sizes
+		res.set(SourceFlag, "pack_sizes")
 	}
 
 	if overrides.RateLimitRPS != nil && *overrides.RateLimitRPS >= 0 {
 		cfg.RateLimitRPS = *overrides.RateLimitRPS
+		res.set(SourceFlag, "rate_limit.rps")
 	}
 
 	if overrides.RateLimitBurst != nil && *overrides.RateLimitBurst >= 0 {
 		cfg.RateLimitBurst = *overrides.RateLimitBurst
+		res.set(SourceFlag, "rate_limit.burst")
+	}
+
+	if len(overrides.Listen) > 0 {
+		cfg.Listen = overrides.Listen
+		res.set(SourceFlag, "listen")
+	}
+
+	if overrides.LogLevel != nil && *overrides.LogLevel != "" {
+		cfg.LogLevel = *overrides.LogLevel
+		res.set(SourceFlag, "log.level")
+	}
+
+	if overrides.LogFormat != nil && *overrides.LogFormat != "" {
+		cfg.LogFormat = *overrides.LogFormat
+		res.set(SourceFlag, "log.format")
+	}
+
+	if len(overrides.LogOutput) > 0 {
+		cfg.LogOutput = overrides.LogOutput
+		res.set(SourceFlag, "log.output")
+	}
+
+	if overrides.LogSampleInitial != nil && *overrides.LogSampleInitial >= 0 {
+		cfg.LogSampleInitial = *overrides.LogSampleInitial
+		res.set(SourceFlag, "log.sampling.initial")
+	}
+
+	if overrides.LogSampleThereafter != nil && *overrides.LogSampleThereafter >= 0 {
+		cfg.LogSampleThereafter = *overrides.LogSampleThereafter
+		res.set(SourceFlag, "log.sampling.thereafter")
 	}
 
 	return nil
 }
 
-// validateConfig validates the final configuration.
+// validateConfig validates the final configuration, returning the first
+// failed rule named by its environment variable where it has one.
 func validateConfig(cfg Config) error {
-	if cfg.RateLimitRPS < 0 {
-		return fmt.Errorf("RATE_LIMIT_RPS must be >= 0")
-	}
-	if cfg.RateLimitBurst < 0 {
-		return fmt.Errorf("RATE_LIMIT_BURST must be >= 0")
+	checks := checkConfig(cfg)
+	if len(checks) == 0 {
+		return nil
 	}
-	if len(cfg.InitialPackSizes) == 0 {
-		return fmt.Errorf("pack sizes cannot be empty")
+	return fmt.Errorf("%s: %s", settingFor(checks[0].key).name(SourceEnv), checks[0].message)
+}
+
+// splitList splits a comma-separated environment value, dropping empty
+// entries.
+func splitList(raw string) []string {
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
 	}
-	return nil
+	return values
 }
 
-// parsePackSizes parses a comma-separated string of pack sizes into a slice of integers.
+// ParsePackSizes parses a comma-separated string of pack sizes into a slice of integers.
 // It validates that all values are positive integers.
-func parsePackSizes(raw string) ([]int, error) {
+func ParsePackSizes(raw string) ([]int, error) {
 	parts := strings.Split(raw, ",")
 	sizes := make([]int, 0, len(parts))
 	for _, part := range parts {
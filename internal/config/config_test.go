@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -56,6 +57,7 @@ pack_sizes:
   - 200
   - 300
 shutdown_grace_period: "20s"
+shutdown_drain_delay: "5s"
 rate_limit:
   rps: 50.0
   burst: 100
@@ -82,6 +84,9 @@ rate_limit:
 	if cfg.ShutdownGracePeriod != 20*time.Second {
 		t.Fatalf("expected shutdown grace period 20s, got %s", cfg.ShutdownGracePeriod)
 	}
+	if cfg.ShutdownDrainDelay != 5*time.Second {
+		t.Fatalf("expected shutdown drain delay 5s, got %s", cfg.ShutdownDrainDelay)
+	}
 	if cfg.RateLimitRPS != 50.0 {
 		t.Fatalf("expected rate limit RPS 50.0, got %f", cfg.RateLimitRPS)
 	}
@@ -90,6 +95,28 @@ rate_limit:
 	}
 }
 
+func TestLoadYAMLKeepsDefaultsForAbsentKeys(t *testing.T) {
+	for _, key := range []string{"PORT", "ENABLE_REQUEST_LOGGING", "RATE_LIMIT_RPS", "RATE_LIMIT_BURST"} {
+		t.Setenv(key, "")
+	}
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: writeYAML(t, "port: \"9090\"\n")})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.EnableRequestLogging || cfg.RateLimitRPS != defaultRateLimitRPS || cfg.RateLimitBurst != defaultRateLimitBurst {
+		t.Fatalf("expected defaults, got logging=%t rps=%v burst=%d", cfg.EnableRequestLogging, cfg.RateLimitRPS, cfg.RateLimitBurst)
+	}
+	for _, key := range []string{"enable_request_logging", "rate_limit.rps", "rate_limit.burst"} {
+		if source, ok := cfg.Sources[key]; ok && source != SourceDefault {
+			t.Fatalf("expected %s to keep its default source, got %s", key, source)
+		}
+	}
+	if cfg.Sources["port"] != SourceYAML {
+		t.Fatalf("expected port from YAML, got %s", cfg.Sources["port"])
+	}
+}
+
 func TestLoadPrecedence_CLIOverridesYAML(t *testing.T) {
 	t.Setenv("PORT", "")
 	t.Setenv("PACK_SIZES", "")
@@ -207,7 +234,7 @@ func TestLoadNonExistentYAML(t *testing.T) {
 
 func TestParsePackSizes(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
-		got, err := parsePackSizes("1,2,3")
+		got, err := ParsePackSizes("1,2,3")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -217,10 +244,10 @@ func TestParsePackSizes(t *testing.T) {
 	})
 
 	t.Run("invalid", func(t *testing.T) {
-		if _, err := parsePackSizes(" , "); err == nil {
+		if _, err := ParsePackSizes(" , "); err == nil {
 			t.Fatalf("expected error for empty string")
 		}
-		if _, err := parsePackSizes("1,a"); err == nil {
+		if _, err := ParsePackSizes("1,a"); err == nil {
 			t.Fatalf("expected error for invalid integer")
 		}
 	})
@@ -233,7 +260,7 @@ func TestApplyEnvConfigOverridesRateLimits(t *testing.T) {
 	t.Setenv("RATE_LIMIT_RPS", "123.5")
 	t.Setenv("RATE_LIMIT_BURST", "42")
 
-	applyEnvConfig(&cfg)
+	applyEnvConfig(&cfg, nil)
 
 	if cfg.RateLimitRPS != 123.5 {
 		t.Fatalf("expected RPS env override, got %f", cfg.RateLimitRPS)
@@ -262,3 +289,233 @@ func TestValidateConfigRejectsInvalidValues(t *testing.T) {
 		t.Fatalf("expected error for empty pack sizes")
 	}
 }
+
+func TestLoadIdempotencyTTL(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("IDEMPOTENCY_TTL", "")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.IdempotencyTTL != defaultIdempotencyTTL {
+		t.Fatalf("expected default idempotency TTL %s, got %s", defaultIdempotencyTTL, cfg.IdempotencyTTL)
+	}
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("idempotency_ttl: \"30m\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.IdempotencyTTL != 30*time.Minute {
+		t.Fatalf("expected idempotency TTL from YAML 30m, got %s", cfg.IdempotencyTTL)
+	}
+
+	t.Setenv("IDEMPOTENCY_TTL", "0s")
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.IdempotencyTTL != 0 {
+		t.Fatalf("expected env to disable idempotency, got %s", cfg.IdempotencyTTL)
+	}
+}
+
+func TestLoadJobsConfig(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("JOB_WORKERS", "")
+	t.Setenv("JOB_QUEUE_SIZE", "")
+	t.Setenv("JOB_RETENTION", "")
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	yamlContent := `jobs:
+  workers: 4
+  queue_size: 10
+  retention: "2h"
+`
+	if err := os.WriteFile(yamlFile, []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.JobWorkers != 4 || cfg.JobQueueSize != 10 || cfg.JobRetention != 2*time.Hour {
+		t.Fatalf("unexpected jobs config: workers=%d queue=%d retention=%s", cfg.JobWorkers, cfg.JobQueueSize, cfg.JobRetention)
+	}
+
+	t.Setenv("JOB_WORKERS", "0")
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.JobWorkers != 0 {
+		t.Fatalf("expected env to disable job workers, got %d", cfg.JobWorkers)
+	}
+
+	t.Setenv("JOB_RETENTION", "0s")
+	if _, err := Load(&CLIOverrides{ConfigFile: yamlFile}); err == nil || !strings.Contains(err.Error(), "JOB_RETENTION: must be > 0") {
+		t.Fatalf("expected a zero retention to be rejected, got %v", err)
+	}
+}
+
+func TestLoadCacheConfig(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("CACHE_MAX_ENTRIES", "")
+	t.Setenv("CACHE_MAX_BYTES", "")
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	yamlContent := `cache:
+  max_entries: 500
+  max_bytes: 1048576
+`
+	if err := os.WriteFile(yamlFile, []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CacheMaxEntries != 500 || cfg.CacheMaxBytes != 1<<20 {
+		t.Fatalf("unexpected cache config: entries=%d bytes=%d", cfg.CacheMaxEntries, cfg.CacheMaxBytes)
+	}
+
+	t.Setenv("CACHE_MAX_ENTRIES", "0")
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CacheMaxEntries != 0 {
+		t.Fatalf("expected env to disable the cache, got %d entries", cfg.CacheMaxEntries)
+	}
+}
+
+func TestLoadHistoryConfig(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("HISTORY_MAX_ENTRIES", "")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.HistoryMaxEntries != defaultHistoryEntries {
+		t.Fatalf("expected default history size %d, got %d", defaultHistoryEntries, cfg.HistoryMaxEntries)
+	}
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("history:\n  max_entries: 250\n"), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.HistoryMaxEntries != 250 {
+		t.Fatalf("expected YAML history size 250, got %d", cfg.HistoryMaxEntries)
+	}
+
+	t.Setenv("HISTORY_MAX_ENTRIES", "0")
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.HistoryMaxEntries != 0 {
+		t.Fatalf("expected env to disable history, got %d entries", cfg.HistoryMaxEntries)
+	}
+}
+
+func TestLoadDPTableMaxBytes(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("DP_TABLE_MAX_BYTES", "")
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("dp_table_max_bytes: 1048576\n"), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DPTableMaxBytes != 1<<20 {
+		t.Fatalf("expected table ceiling from YAML, got %d", cfg.DPTableMaxBytes)
+	}
+
+	t.Setenv("DP_TABLE_MAX_BYTES", "0")
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DPTableMaxBytes != 0 {
+		t.Fatalf("expected env to disable table reuse, got %d", cfg.DPTableMaxBytes)
+	}
+}
+
+func TestLoadTieBreak(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("TIE_BREAK", "")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.TieBreak != "ascending-size-first" {
+		t.Fatalf("expected default tie-break, got %q", cfg.TieBreak)
+	}
+
+	tmpDir := t.TempDir()
+	yamlFile := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("tie_break: larger-packs\n"), 0644); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+
+	cfg, err = Load(&CLIOverrides{ConfigFile: yamlFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.TieBreak != "larger-packs" {
+		t.Fatalf("expected tie-break from YAML, got %q", cfg.TieBreak)
+	}
+
+	t.Setenv("TIE_BREAK", "cheapest")
+	if _, err := Load(nil); err == nil || !strings.Contains(err.Error(), "TIE_BREAK") {
+		t.Fatalf("expected unknown tie-break to be rejected, got %v", err)
+	}
+}
+
+func TestLoadCompressionMinSize(t *testing.T) {
+	cfg, err := Load(&CLIOverrides{ConfigFile: writeYAML(t, "compression:\n  min_size: 512\n")})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CompressionMinSize != 512 || cfg.Sources["compression.min_size"] != SourceYAML {
+		t.Fatalf("expected the YAML minimum size, got %d from %s", cfg.CompressionMinSize, cfg.Sources["compression.min_size"])
+	}
+
+	t.Setenv("COMPRESSION_MIN_SIZE", "0")
+	if cfg, err = Load(&CLIOverrides{}); err != nil || cfg.CompressionMinSize != 0 {
+		t.Fatalf("expected compression to be disabled from the environment, got %d (%v)", cfg.CompressionMinSize, err)
+	}
+
+	t.Setenv("COMPRESSION_MIN_SIZE", "-1")
+	if _, err := Load(&CLIOverrides{}); err == nil || !strings.Contains(err.Error(), "COMPRESSION_MIN_SIZE") {
+		t.Fatalf("expected a negative size to be rejected, got %v", err)
+	}
+}
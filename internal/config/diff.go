@@ -0,0 +1,29 @@
+package config
+
+import "reflect"
+
+// Change describes one setting that differs between two configurations.
+type Change struct {
+	// Key is the dotted YAML key, e.g. "rate_limit.rps".
+	Key string
+	// Old and New are Redacted for secrets.
+	Old any
+	New any
+}
+
+// Diff lists the settings that differ between old and updated, in the order
+// of the configuration file. Where the values came from is not compared.
+func Diff(old, updated Config) []Change {
+	var changes []Change
+	for _, s := range settings {
+		before, after := s.value(old), s.value(updated)
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		if s.secret {
+			before, after = Redacted, Redacted
+		}
+		changes = append(changes, Change{Key: s.key, Old: before, New: after})
+	}
+	return changes
+}
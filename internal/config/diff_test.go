@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffListsChangedFields(t *testing.T) {
+	old := defaultConfig()
+	updated := old
+	updated.InitialPackSizes = []int{23, 31, 53}
+	updated.RateLimitRPS = 5
+	updated.WriteTimeout = 30 * time.Second
+
+	changes := Diff(old, updated)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if changes[0].Key != "pack_sizes" || changes[1].Key != "write_timeout" || changes[2].Key != "rate_limit.rps" {
+		t.Fatalf("expected changes in field order, got %+v", changes)
+	}
+	if changes[1].Old != old.WriteTimeout || changes[1].New != 30*time.Second {
+		t.Fatalf("unexpected write timeout change %+v", changes[1])
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	old := defaultConfig()
+	updated := old
+	updated.AdminToken = "s3cret"
+
+	changes := Diff(old, updated)
+	if len(changes) != 1 || changes[0].Key != "admin.token" || changes[0].New != Redacted {
+		t.Fatalf("expected a redacted token change, got %+v", changes)
+	}
+}
+
+func TestDiffOfEqualConfigsIsEmpty(t *testing.T) {
+	cfg := defaultConfig()
+	copied := cfg
+	copied.InitialPackSizes = append([]int(nil), cfg.InitialPackSizes...)
+	copied.Sources = map[string]Source{"port": SourceEnv}
+
+	if changes := Diff(cfg, copied); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
@@ -1,5 +1,6 @@
 // Package config loads runtime configuration from multiple sources (YAML files,
-// environment variables, CLI flags) with precedence: CLI flags > YAML config >
-// Environment variables > Defaults. It exposes strongly typed settings to the
-// rest of the application.
+// environment variables, CLI flags) with precedence: CLI flags > Environment
+// variables > YAML config > Defaults. Loading is strict by default and reports
+// every invalid value with its source. It exposes strongly typed settings to
+// the rest of the application.
 package config
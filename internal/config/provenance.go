@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the value of a secret setting that is set.
+const Redacted = "[redacted]"
+
+// EffectiveSetting is one resolved setting and where its value came from.
+type EffectiveSetting struct {
+	// Key is the dotted YAML key, e.g. "rate_limit.rps".
+	Key string
+	// Env and Flag name the environment variable and serve flag that can
+	// also set the value; they are empty when there is none.
+	Env  string
+	Flag string
+	// Value is formatted the way it is written in YAML. Secrets are
+	// Redacted.
+	Value  string
+	Source Source
+}
+
+// Effective lists every setting of c with its value and source, in the
+// order of the configuration file. Secret values are redacted.
+func (c Config) Effective() []EffectiveSetting {
+	out := make([]EffectiveSetting, len(settings))
+	for i, s := range settings {
+		value := formatValue(s.value(c))
+		if s.secret && value != "" {
+			value = Redacted
+		}
+		source, ok := c.Sources[s.key]
+		if !ok {
+			source = SourceDefault
+		}
+		out[i] = EffectiveSetting{Key: s.key, Env: s.env, Flag: s.flag, Value: value, Source: source}
+	}
+	return out
+}
+
+// value returns the Config field holding the setting.
+func (s setting) value(cfg Config) any {
+	return reflect.ValueOf(cfg).FieldByName(s.field).Interface()
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case []int:
+		parts := make([]string, len(v))
+		for i, size := range v {
+			parts[i] = strconv.Itoa(size)
+		}
+		return strings.Join(parts, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEffectiveReportsSources(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "")
+	t.Setenv("RATE_LIMIT_RPS", "")
+	t.Setenv("RATE_LIMIT_BURST", "7")
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	path := writeYAML(t, "write_timeout: 20s\npack_sizes: [23, 31]\nrate_limit:\n  rps: 3\n  burst: 4\n")
+	port := "9090"
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: path, Port: &port})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	got := make(map[string]EffectiveSetting)
+	for _, s := range cfg.Effective() {
+		got[s.Key] = s
+	}
+	want := map[string]EffectiveSetting{
+		"port":             {Key: "port", Env: "PORT", Flag: "--port", Value: "9090", Source: SourceFlag},
+		"pack_sizes":       {Key: "pack_sizes", Env: "PACK_SIZES", Flag: "--pack-sizes", Value: "23,31", Source: SourceYAML},
+		"write_timeout":    {Key: "write_timeout", Value: "20s", Source: SourceYAML},
+		"idle_timeout":     {Key: "idle_timeout", Value: "1m0s", Source: SourceDefault},
+		"rate_limit.burst": {Key: "rate_limit.burst", Env: "RATE_LIMIT_BURST", Flag: "--rate-limit-burst", Value: "7", Source: SourceEnv},
+		"admin.token":      {Key: "admin.token", Env: "ADMIN_TOKEN", Value: Redacted, Source: SourceEnv},
+	}
+	for key, expected := range want {
+		if got[key] != expected {
+			t.Fatalf("%s: expected %+v, got %+v", key, expected, got[key])
+		}
+	}
+}
+
+func TestEffectiveLeavesUnsetSecretsEmpty(t *testing.T) {
+	for _, s := range defaultConfig().Effective() {
+		if s.Key == "admin.token" && s.Value != "" {
+			t.Fatalf("expected an unset token to be empty, got %q", s.Value)
+		}
+	}
+}
+
+// Every Config field must be listed in settings so that it is reported,
+// diffed and redacted.
+func TestSettingsCoverConfig(t *testing.T) {
+	listed := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		listed[s.field] = true
+	}
+	fields := reflect.TypeOf(Config{})
+	for i := range fields.NumField() {
+		name := fields.Field(i).Name
+		if name != "Sources" && !listed[name] {
+			t.Fatalf("Config.%s is missing from settings", name)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+	"github.com/eugenenazirov/re-partners/internal/cors"
+	"github.com/eugenenazirov/re-partners/internal/listener"
+	"github.com/eugenenazirov/re-partners/internal/logging"
+	"github.com/eugenenazirov/re-partners/internal/storage"
+	"github.com/eugenenazirov/re-partners/internal/tlsconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Source identifies where a configuration value came from.
+type Source string
+
+// Configuration sources, from lowest to highest precedence.
+const (
+	SourceDefault Source = "default"
+	SourceYAML    Source = "yaml"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Problem is one invalid configuration value.
+type Problem struct {
+	Source Source
+	// Key names the value the way its source does: a dotted YAML key, an
+	// environment variable, or a flag.
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("%s: %s", p.Source, p.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", p.Source, p.Key, p.Message)
+}
+
+// ValidationError reports every problem found while loading a configuration
+// in strict mode.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid configuration: " + e.Problems[0].String()
+	}
+	parts := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		parts[i] = p.String()
+	}
+	return fmt.Sprintf("invalid configuration (%d problems): %s", len(e.Problems), strings.Join(parts, "; "))
+}
+
+// setting names one configuration value in each source and the Config
+// field holding it. Settings without an environment variable or flag leave
+// those names empty; secret settings are redacted whenever they are shown.
+type setting struct {
+	key    string
+	field  string
+	env    string
+	flag   string
+	secret bool
+}
+
+var settings = []setting{
+	{key: "port", field: "Port", env: "PORT", flag: "--port"},
+	{key: "pack_sizes", field: "InitialPackSizes", env: "PACK_SIZES", flag: "--pack-sizes"},
+	{key: "shutdown_grace_period", field: "ShutdownGracePeriod"},
+	{key: "shutdown_drain_delay", field: "ShutdownDrainDelay"},
+	{key: "read_header_timeout", field: "ReadHeaderTimeout"},
+	{key: "write_timeout", field: "WriteTimeout"},
+	{key: "idle_timeout", field: "IdleTimeout"},
+	{key: "enable_request_logging", field: "EnableRequestLogging"},
+	{key: "rate_limit.rps", field: "RateLimitRPS", env: "RATE_LIMIT_RPS", flag: "--rate-limit-rps"},
+	{key: "rate_limit.burst", field: "RateLimitBurst", env: "RATE_LIMIT_BURST", flag: "--rate-limit-burst"},
+	{key: "idempotency_ttl", field: "IdempotencyTTL", env: "IDEMPOTENCY_TTL"},
+	{key: "jobs.workers", field: "JobWorkers", env: "JOB_WORKERS"},
+	{key: "jobs.queue_size", field: "JobQueueSize", env: "JOB_QUEUE_SIZE"},
+	{key: "jobs.retention", field: "JobRetention", env: "JOB_RETENTION"},
+	{key: "cache.max_entries", field: "CacheMaxEntries", env: "CACHE_MAX_ENTRIES"},
+	{key: "cache.max_bytes", field: "CacheMaxBytes", env: "CACHE_MAX_BYTES"},
+	{key: "dp_table_max_bytes", field: "DPTableMaxBytes", env: "DP_TABLE_MAX_BYTES"},
+	{key: "tie_break", field: "TieBreak", env: "TIE_BREAK"},
+	{key: "history.max_entries", field: "HistoryMaxEntries", env: "HISTORY_MAX_ENTRIES"},
+	{key: "compression.min_size", field: "CompressionMinSize", env: "COMPRESSION_MIN_SIZE"},
+	{key: "listen", field: "Listen", env: "LISTEN", flag: "--listen"},
+	{key: "unix_socket_mode", field: "UnixSocketMode", env: "UNIX_SOCKET_MODE"},
+	{key: "web_dir", field: "WebDir", env: "WEB_DIR"},
+	{key: "log.level", field: "LogLevel", env: "LOG_LEVEL", flag: "--log-level"},
+	{key: "log.format", field: "LogFormat", env: "LOG_FORMAT", flag: "--log-format"},
+	{key: "log.output", field: "LogOutput", env: "LOG_OUTPUT", flag: "--log-output"},
+	{key: "log.sampling.initial", field: "LogSampleInitial", env: "LOG_SAMPLING_INITIAL", flag: "--log-sampling-initial"},
+	{key: "log.sampling.thereafter", field: "LogSampleThereafter", env: "LOG_SAMPLING_THEREAFTER", flag: "--log-sampling-thereafter"},
+	{key: "tls.cert_file", field: "TLSCertFile", env: "TLS_CERT_FILE"},
+	{key: "tls.key_file", field: "TLSKeyFile", env: "TLS_KEY_FILE"},
+	{key: "tls.min_version", field: "TLSMinVersion", env: "TLS_MIN_VERSION"},
+	{key: "tls.client_ca_file", field: "TLSClientCAFile", env: "TLS_CLIENT_CA_FILE"},
+	{key: "tls.client_auth", field: "TLSClientAuth", env: "TLS_CLIENT_AUTH"},
+	{key: "admin.token", field: "AdminToken", env: "ADMIN_TOKEN", secret: true},
+	{key: "admin.listen", field: "AdminListen", env: "ADMIN_LISTEN"},
+	{key: "admin.diagnostics", field: "AdminDiagnostics", env: "ADMIN_DIAGNOSTICS"},
+	{key: "cors.allowed_origins", field: "CORSAllowedOrigins", env: "CORS_ALLOWED_ORIGINS"},
+	{key: "cors.allowed_methods", field: "CORSAllowedMethods", env: "CORS_ALLOWED_METHODS"},
+	{key: "cors.allowed_headers", field: "CORSAllowedHeaders", env: "CORS_ALLOWED_HEADERS"},
+	{key: "cors.allow_credentials", field: "CORSAllowCredentials", env: "CORS_ALLOW_CREDENTIALS"},
+	{key: "cors.max_age", field: "CORSMaxAge", env: "CORS_MAX_AGE"},
+}
+
+func settingFor(key string) setting {
+	for _, s := range settings {
+		if s.key == key {
+			return s
+		}
+	}
+	return setting{key: key}
+}
+
+// name returns how source refers to the setting.
+func (s setting) name(source Source) string {
+	switch {
+	case source == SourceEnv && s.env != "":
+		return s.env
+	case source == SourceFlag && s.flag != "":
+		return s.flag
+	default:
+		return s.key
+	}
+}
+
+// resolution records which source set each setting and the problems found
+// while resolving them. A nil resolution records nothing.
+type resolution struct {
+	sources  map[string]Source
+	problems []Problem
+}
+
+func newResolution() *resolution {
+	return &resolution{sources: make(map[string]Source)}
+}
+
+func (r *resolution) set(source Source, key string) {
+	if r != nil {
+		r.sources[key] = source
+	}
+}
+
+func (r *resolution) reject(source Source, key, format string, args ...any) {
+	if r == nil {
+		return
+	}
+	r.problems = append(r.problems, Problem{
+		Source:  source,
+		Key:     settingFor(key).name(source),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (r *resolution) source(key string) Source {
+	if source, ok := r.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// check is a failed rule on a resolved setting.
+type check struct {
+	key     string
+	message string
+}
+
+// checkConfig applies the validation rules to a resolved configuration.
+func checkConfig(cfg Config) []check {
+	var checks []check
+	fail := func(key, message string) {
+		checks = append(checks, check{key: key, message: message})
+	}
+	nonNegative := func(key string, negative bool) {
+		if negative {
+			fail(key, "must be >= 0")
+		}
+	}
+
+	if err := validatePort(cfg.Port); err != nil {
+		fail("port", err.Error())
+	}
+	if _, err := storage.NormalizePackSizes(cfg.InitialPackSizes); err != nil {
+		fail("pack_sizes", err.Error())
+	}
+	nonNegative("shutdown_grace_period", cfg.ShutdownGracePeriod < 0)
+	nonNegative("shutdown_drain_delay", cfg.ShutdownDrainDelay < 0)
+	nonNegative("read_header_timeout", cfg.ReadHeaderTimeout < 0)
+	nonNegative("write_timeout", cfg.WriteTimeout < 0)
+	nonNegative("idle_timeout", cfg.IdleTimeout < 0)
+	nonNegative("rate_limit.rps", cfg.RateLimitRPS < 0)
+	nonNegative("rate_limit.burst", cfg.RateLimitBurst < 0)
+	nonNegative("idempotency_ttl", cfg.IdempotencyTTL < 0)
+	nonNegative("jobs.workers", cfg.JobWorkers < 0)
+	nonNegative("jobs.queue_size", cfg.JobQueueSize < 0)
+	if cfg.JobRetention <= 0 {
+		fail("jobs.retention", "must be > 0")
+	}
+	nonNegative("cache.max_entries", cfg.CacheMaxEntries < 0)
+	nonNegative("cache.max_bytes", cfg.CacheMaxBytes < 0)
+	nonNegative("dp_table_max_bytes", cfg.DPTableMaxBytes < 0)
+	nonNegative("history.max_entries", cfg.HistoryMaxEntries < 0)
+	nonNegative("compression.min_size", cfg.CompressionMinSize < 0)
+	if _, err := calculator.ParseTieBreakPolicy(cfg.TieBreak); err != nil {
+		fail("tie_break", err.Error())
+	}
+	for _, address := range cfg.Listen {
+		if _, err := listener.ParseAddress(address); err != nil {
+			fail("listen", err.Error())
+		}
+	}
+	if _, err := listener.ParseSocketMode(cfg.UnixSocketMode); err != nil {
+		fail("unix_socket_mode", err.Error())
+	}
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		fail("log.level", err.Error())
+	}
+	if _, err := logging.ParseFormat(cfg.LogFormat); err != nil {
+		fail("log.format", err.Error())
+	}
+	if len(cfg.LogOutput) == 0 {
+		fail("log.output", "must name at least one output")
+	}
+	nonNegative("log.sampling.initial", cfg.LogSampleInitial < 0)
+	nonNegative("log.sampling.thereafter", cfg.LogSampleThereafter < 0)
+	switch {
+	case cfg.TLSCertFile != "" && cfg.TLSKeyFile == "":
+		fail("tls.cert_file", "requires tls.key_file")
+	case cfg.TLSKeyFile != "" && cfg.TLSCertFile == "":
+		fail("tls.key_file", "requires tls.cert_file")
+	case cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "":
+		fail("tls.client_ca_file", "requires tls.cert_file and tls.key_file")
+	}
+	if _, err := tlsconfig.ParseVersion(cfg.TLSMinVersion); err != nil {
+		fail("tls.min_version", err.Error())
+	}
+	if _, err := tlsconfig.ParseClientAuth(cfg.TLSClientAuth); err != nil {
+		fail("tls.client_auth", err.Error())
+	}
+	for _, address := range cfg.AdminListen {
+		if _, err := listener.ParseAddress(address); err != nil {
+			fail("admin.listen", err.Error())
+		}
+	}
+	if len(cfg.AdminListen) > 0 && cfg.AdminToken == "" {
+		fail("admin.listen", "requires admin.token")
+	}
+	if cfg.AdminDiagnostics && len(cfg.AdminListen) == 0 {
+		fail("admin.diagnostics", "requires admin.listen, so that profiles are not served on the public listeners")
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if err := cors.ParseOrigin(origin); err != nil {
+			fail("cors.allowed_origins", err.Error())
+		}
+	}
+	if cfg.CORSAllowCredentials && slices.Contains(cfg.CORSAllowedOrigins, cors.AnyOrigin) {
+		fail("cors.allow_credentials", `cannot be combined with allowed origin "*"; list the origins instead`)
+	}
+	if len(cfg.CORSAllowedOrigins) > 0 && len(cfg.CORSAllowedMethods) == 0 {
+		fail("cors.allowed_methods", "must not be empty")
+	}
+	nonNegative("cors.max_age", cfg.CORSMaxAge < 0)
+	return checks
+}
+
+// validatePort accepts a port number or a host:port address.
+func validatePort(port string) error {
+	if strings.Contains(port, ":") {
+		_, p, err := net.SplitHostPort(port)
+		if err != nil {
+			return fmt.Errorf("invalid address %q", port)
+		}
+		port = p
+	}
+	value, err := strconv.Atoi(port)
+	if err != nil || value < 0 || value > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// yamlDuration parses a YAML duration into dst, recording raw as a problem
+// when it is not a valid duration.
+func (r *resolution) yamlDuration(key, raw string, dst *time.Duration) {
+	if raw == "" {
+		return
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		r.reject(SourceYAML, key, "invalid duration %q", raw)
+		return
+	}
+	*dst = value
+	r.set(SourceYAML, key)
+}
+
+// envValue parses the environment variable of key into dst. Unparsable and
+// negative values are recorded as problems and leave dst unchanged.
+func envValue[T int | int64 | float64 | time.Duration](r *resolution, key string, lookup func(string) string, parse func(string) (T, error), kind string, dst *T) {
+	raw := lookup(settingFor(key).env)
+	if raw == "" {
+		return
+	}
+	value, err := parse(raw)
+	if err != nil {
+		r.reject(SourceEnv, key, "invalid %s %q", kind, raw)
+		return
+	}
+	if value < 0 {
+		r.reject(SourceEnv, key, "must be >= 0, got %s", raw)
+		return
+	}
+	*dst = value
+	r.set(SourceEnv, key)
+}
+
+// unknownYAMLKeys reports mapping keys in data that do not correspond to a
+// field of yamlConfig, using dotted paths for nested sections.
+func unknownYAMLKeys(data []byte) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	root := &doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	return unknownKeys(root, reflect.TypeOf(yamlConfig{}), ""), nil
+}
+
+func unknownKeys(node *yaml.Node, t reflect.Type, prefix string) []Problem {
+	if node.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		fields[name] = fieldType
+	}
+
+	var problems []Problem
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		key := keyNode.Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fieldType, ok := fields[keyNode.Value]
+		if !ok {
+			problems = append(problems, Problem{
+				Source:  SourceYAML,
+				Key:     key,
+				Message: fmt.Sprintf("unknown key (line %d)", keyNode.Line),
+			})
+			continue
+		}
+		problems = append(problems, unknownKeys(node.Content[i+1], fieldType, key)...)
+	}
+	return problems
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeYAML(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write YAML file: %v", err)
+	}
+	return path
+}
+
+func problemStrings(t *testing.T, err error) []string {
+	t.Helper()
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	out := make([]string, len(invalid.Problems))
+	for i, p := range invalid.Problems {
+		out[i] = p.String()
+	}
+	return out
+}
+
+func TestLoadStrictCollectsEveryProblem(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "1,a")
+	t.Setenv("JOB_RETENTION", "forever")
+	t.Setenv("CACHE_MAX_ENTRIES", "-3")
+
+	path := writeYAML(t, `port: "70000"
+write_timeout: "15 seconds"
+tie_break: sideways
+rate_limit:
+  rps: -1
+jobs:
+  workers: -2
+  wrkers: 4
+unknown: true
+`)
+	rps := 5.0
+	sizes := "100,0"
+	_, err := Load(&CLIOverrides{ConfigFile: path, RateLimitRPS: &rps, PackSizesStr: &sizes})
+
+	want := []string{
+		"yaml jobs.wrkers: unknown key (line 8)",
+		"yaml unknown: unknown key (line 9)",
+		`yaml write_timeout: invalid duration "15 seconds"`,
+		"yaml rate_limit.rps: must be >= 0, got -1",
+		`env PACK_SIZES: invalid integer "a"`,
+		`env JOB_RETENTION: invalid duration "forever"`,
+		"env CACHE_MAX_ENTRIES: must be >= 0, got -3",
+		"flag --pack-sizes: pack size must be positive, got 0",
+		`yaml port: invalid port "70000"`,
+		"yaml jobs.workers: must be >= 0",
+		"yaml tie_break: ",
+	}
+	got := problemStrings(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("problem %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLoadStrictReportsYAMLTypeErrors(t *testing.T) {
+	path := writeYAML(t, "rate_limit:\n  rps: fast\n")
+	_, err := Load(&CLIOverrides{ConfigFile: path})
+	got := problemStrings(t, err)
+	if len(got) != 1 || !strings.Contains(got[0], "cannot unmarshal") {
+		t.Fatalf("expected a type error, got %v", got)
+	}
+}
+
+func TestLoadStrictValidatesPackSizesWithStorageRules(t *testing.T) {
+	t.Setenv("PACK_SIZES", "1,2,3,4,5,6,7,8,9,10,11")
+	_, err := Load(nil)
+	got := problemStrings(t, err)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "env PACK_SIZES: pack sizes must contain between 1 and 10") {
+		t.Fatalf("expected the storage limit to apply, got %v", got)
+	}
+}
+
+func TestLoadLenientIgnoresUnparsableValues(t *testing.T) {
+	t.Setenv("PORT", "")
+	t.Setenv("PACK_SIZES", "1,a")
+	t.Setenv("CONFIG_STRICT", "false")
+	path := writeYAML(t, "write_timeout: \"15 seconds\"\nunknown: true\n")
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if want := defaultConfig(); cfg.WriteTimeout != want.WriteTimeout || len(cfg.InitialPackSizes) != len(want.InitialPackSizes) {
+		t.Fatalf("expected unparsable values to be ignored, got %+v", cfg)
+	}
+
+	strict := true
+	if _, err := Load(&CLIOverrides{ConfigFile: path, Strict: &strict}); err == nil {
+		t.Fatalf("expected Strict to override CONFIG_STRICT")
+	}
+}
+
+func TestLoadStrictValidatesTLS(t *testing.T) {
+	t.Setenv("TLS_CLIENT_AUTH", "sometimes")
+	path := writeYAML(t, "tls:\n  key_file: /etc/tls/tls.key\n  min_version: \"1.1\"\n")
+
+	_, err := Load(&CLIOverrides{ConfigFile: path})
+
+	want := []string{
+		"yaml tls.key_file: requires tls.cert_file",
+		`yaml tls.min_version: unsupported TLS version "1.1"`,
+		`env TLS_CLIENT_AUTH: unknown client auth policy "sometimes"`,
+	}
+	got := problemStrings(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("problem %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLoadListeners(t *testing.T) {
+	t.Setenv("LISTEN", "unix:/run/pack-calculator/api.sock, systemd:api")
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	path := writeYAML(t, "listen: [\":8080\"]\nunix_socket_mode: \"0600\"\nadmin:\n  listen: [\"unix:/run/pack-calculator/admin.sock\"]\n")
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !slices.Equal(cfg.Listen, []string{"unix:/run/pack-calculator/api.sock", "systemd:api"}) || cfg.Sources["listen"] != SourceEnv {
+		t.Fatalf("expected the environment listeners, got %v from %s", cfg.Listen, cfg.Sources["listen"])
+	}
+	if cfg.UnixSocketMode != "0600" || len(cfg.AdminListen) != 1 {
+		t.Fatalf("unexpected listener configuration %+v", cfg)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "")
+	path = writeYAML(t, "listen: [\"unix:\"]\nunix_socket_mode: rw\nadmin:\n  listen: [\"127.0.0.1:9091\"]\n")
+	_, err = Load(&CLIOverrides{ConfigFile: path, Listen: []string{"unix:"}})
+	want := []string{
+		`flag --listen: address "unix:" has no socket path`,
+		`yaml unix_socket_mode: invalid socket mode "rw"`,
+		"yaml admin.listen: requires admin.token",
+	}
+	got := problemStrings(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("problem %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLoadRequiresPrivateListenerForDiagnostics(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	t.Setenv("ADMIN_LISTEN", "")
+	t.Setenv("ADMIN_DIAGNOSTICS", "true")
+
+	_, err := Load(&CLIOverrides{})
+	got := problemStrings(t, err)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "env ADMIN_DIAGNOSTICS: requires admin.listen") {
+		t.Fatalf("expected diagnostics to require admin.listen, got %v", got)
+	}
+
+	t.Setenv("ADMIN_LISTEN", "unix:/run/pack-calculator/admin.sock")
+	cfg, err := Load(&CLIOverrides{})
+	if err != nil || !cfg.AdminDiagnostics {
+		t.Fatalf("expected diagnostics to be enabled, got %v (%v)", cfg.AdminDiagnostics, err)
+	}
+
+	t.Setenv("ADMIN_DIAGNOSTICS", "sure")
+	_, err = Load(&CLIOverrides{})
+	got = problemStrings(t, err)
+	if len(got) != 1 || got[0] != `env ADMIN_DIAGNOSTICS: invalid boolean "sure"` {
+		t.Fatalf("expected an invalid boolean, got %v", got)
+	}
+}
+
+func TestLoadLogging(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "console")
+	t.Setenv("LOG_SAMPLING_INITIAL", "")
+	path := writeYAML(t, "log:\n  level: warn\n  format: json\n  output: [stdout, /var/log/pack-calculator.log]\n  sampling:\n    initial: 100\n    thereafter: 10\n")
+	level := "debug"
+
+	cfg, err := Load(&CLIOverrides{ConfigFile: path, LogLevel: &level})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.LogFormat != "console" || len(cfg.LogOutput) != 2 || cfg.LogSampleInitial != 100 || cfg.LogSampleThereafter != 10 {
+		t.Fatalf("unexpected logging configuration %+v", cfg)
+	}
+	if cfg.Sources["log.level"] != SourceFlag || cfg.Sources["log.format"] != SourceEnv || cfg.Sources["log.output"] != SourceYAML {
+		t.Fatalf("unexpected sources %v", cfg.Sources)
+	}
+
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_SAMPLING_INITIAL", "-1")
+	_, err = Load(&CLIOverrides{ConfigFile: writeYAML(t, "log:\n  level: loud\n")})
+	want := []string{
+		"env LOG_SAMPLING_INITIAL: must be >= 0, got -1",
+		`yaml log.level: unknown log level "loud"`,
+		`env LOG_FORMAT: unknown log format "xml"`,
+	}
+	got := problemStrings(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("problem %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLoadCORS(t *testing.T) {
+	cfg, err := Load(&CLIOverrides{})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(cfg.CORSAllowedOrigins) != 0 || len(cfg.CORSAllowedMethods) == 0 || cfg.CORSMaxAge != defaultCORSMaxAge {
+		t.Fatalf("expected cross-origin requests to be disabled by default, got %+v", cfg)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example.com, https://*.example.org")
+	path := writeYAML(t, "cors:\n  allowed_origins: [https://ignored.example.com]\n  allowed_methods: [GET]\n  allow_credentials: true\n  max_age: 10m\n")
+	cfg, err = Load(&CLIOverrides{ConfigFile: path})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(cfg.CORSAllowedOrigins) != 2 || cfg.CORSAllowedOrigins[1] != "https://*.example.org" || len(cfg.CORSAllowedMethods) != 1 || !cfg.CORSAllowCredentials || cfg.CORSMaxAge != 10*time.Minute {
+		t.Fatalf("unexpected CORS configuration %+v", cfg)
+	}
+	if cfg.Sources["cors.allowed_origins"] != SourceEnv || cfg.Sources["cors.max_age"] != SourceYAML {
+		t.Fatalf("unexpected sources %v", cfg.Sources)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*,app.example.com")
+	_, err = Load(&CLIOverrides{ConfigFile: path})
+	want := []string{
+		`env CORS_ALLOWED_ORIGINS: invalid origin "app.example.com"`,
+		`yaml cors.allow_credentials: cannot be combined with allowed origin "*"`,
+	}
+	got := problemStrings(t, err)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Fatalf("problem %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AnyOrigin allows requests from every origin. It cannot be combined with
+// credentials.
+const AnyOrigin = "*"
+
+// Default methods and request headers allowed for cross-origin requests.
+var (
+	DefaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	DefaultHeaders = []string{"Content-Type", "Authorization", "X-Requested-With", "Idempotency-Key", "X-Actor"}
+)
+
+// Config describes a CORS policy.
+type Config struct {
+	// AllowedOrigins lists origins such as "https://app.example.com",
+	// wildcard subdomains such as "https://*.example.com", or AnyOrigin.
+	// Cross-origin requests are not allowed when it is empty.
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are the request headers browsers may send.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and client certificates.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// origin is an allowed origin; host starting with "*." matches any
+// subdomain.
+type origin struct {
+	scheme string
+	host   string
+}
+
+func (o origin) matches(scheme, host string) bool {
+	if o.scheme != scheme {
+		return false
+	}
+	if suffix, ok := strings.CutPrefix(o.host, "*"); ok {
+		return len(host) > len(suffix) && strings.HasSuffix(host, suffix)
+	}
+	return o.host == host
+}
+
+// ParseOrigin checks an allowed origin: AnyOrigin, scheme://host[:port], or
+// scheme://*.domain[:port].
+func ParseOrigin(raw string) error {
+	if raw == AnyOrigin {
+		return nil
+	}
+	_, err := parseOrigin(raw)
+	return err
+}
+
+func parseOrigin(raw string) (origin, error) {
+	scheme, host, ok := strings.Cut(strings.ToLower(strings.TrimSpace(raw)), "://")
+	if !ok || scheme == "" || host == "" || strings.ContainsAny(host, "/?#@") {
+		return origin{}, fmt.Errorf("invalid origin %q (use scheme://host[:port] or scheme://*.domain)", raw)
+	}
+	name := strings.TrimPrefix(host, "*.")
+	if strings.Contains(name, "*") {
+		return origin{}, fmt.Errorf("invalid origin %q: only a leading *. wildcard is supported", raw)
+	}
+	if _, err := url.Parse(scheme + "://" + name); err != nil {
+		return origin{}, fmt.Errorf("invalid origin %q: %w", raw, err)
+	}
+	return origin{scheme: scheme, host: host}, nil
+}
+
+// Policy answers CORS checks for a validated Config.
+type Policy struct {
+	anyOrigin   bool
+	origins     []origin
+	methods     []string
+	headers     map[string]bool
+	credentials bool
+	maxAge      time.Duration
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+}
+
+// New validates cfg and returns its policy.
+func New(cfg Config) (*Policy, error) {
+	p := &Policy{
+		headers:     make(map[string]bool, len(cfg.AllowedHeaders)),
+		credentials: cfg.AllowCredentials,
+		maxAge:      cfg.MaxAge,
+	}
+	for _, raw := range cfg.AllowedOrigins {
+		if raw == AnyOrigin {
+			p.anyOrigin = true
+			continue
+		}
+		o, err := parseOrigin(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.origins = append(p.origins, o)
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New(`credentials cannot be allowed for origin "*"`)
+	}
+	for _, method := range cfg.AllowedMethods {
+		p.methods = append(p.methods, strings.ToUpper(strings.TrimSpace(method)))
+	}
+	for _, header := range cfg.AllowedHeaders {
+		p.headers[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+	p.allowMethods = strings.Join(p.methods, ",")
+	p.allowHeaders = strings.Join(cfg.AllowedHeaders, ",")
+	p.exposeHeaders = strings.Join(cfg.ExposedHeaders, ",")
+	return p, nil
+}
+
+// Enabled reports whether any cross-origin request is allowed.
+func (p *Policy) Enabled() bool {
+	return p.anyOrigin || len(p.origins) > 0
+}
+
+// AllowOrigin reports whether requests from requestOrigin are allowed and
+// returns the value of Access-Control-Allow-Origin for them.
+func (p *Policy) AllowOrigin(requestOrigin string) (string, bool) {
+	if p.anyOrigin {
+		return AnyOrigin, true
+	}
+	u, err := url.Parse(requestOrigin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	for _, o := range p.origins {
+		if o.matches(scheme, host) {
+			return requestOrigin, true
+		}
+	}
+	return "", false
+}
+
+// AllowsMethod reports whether cross-origin requests may use method.
+func (p *Policy) AllowsMethod(method string) bool {
+	return slices.Contains(p.methods, method)
+}
+
+// AllowsHeaders reports whether every header in requested, a
+// comma-separated Access-Control-Request-Headers value, is allowed.
+func (p *Policy) AllowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetPreflightHeaders sets the headers of a successful preflight response,
+// except Access-Control-Allow-Origin.
+func (p *Policy) SetPreflightHeaders(h http.Header) {
+	h.Set("Access-Control-Allow-Methods", p.allowMethods)
+	if p.allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", p.allowHeaders)
+	}
+	if p.maxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.maxAge/time.Second)))
+	}
+	p.setCredentials(h)
+}
+
+// SetResponseHeaders sets the headers of an allowed cross-origin response,
+// except Access-Control-Allow-Origin.
+func (p *Policy) SetResponseHeaders(h http.Header) {
+	if p.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+	}
+	p.setCredentials(h)
+}
+
+func (p *Policy) setCredentials(h http.Header) {
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
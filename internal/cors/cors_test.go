@@ -0,0 +1,87 @@
+package cors
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAllowOrigin(t *testing.T) {
+	p, err := New(Config{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org", "http://localhost:3000"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	cases := map[string]bool{
+		"https://app.example.com":     true,
+		"https://APP.example.com":     true,
+		"http://app.example.com":      false,
+		"https://app.example.com:444": false,
+		"https://a.example.org":       true,
+		"https://a.b.example.org":     true,
+		"https://example.org":         false,
+		"https://evilexample.org":     false,
+		"http://localhost:3000":       true,
+		"http://localhost:3001":       false,
+		"null":                        false,
+	}
+	for origin, want := range cases {
+		if allowOrigin, got := p.AllowOrigin(origin); got != want || (got && allowOrigin != origin) {
+			t.Fatalf("AllowOrigin(%q): expected %v, got %v (%q)", origin, want, got, allowOrigin)
+		}
+	}
+
+	wildcard, err := New(Config{AllowedOrigins: []string{AnyOrigin}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if allowOrigin, ok := wildcard.AllowOrigin("https://anything.example"); !ok || allowOrigin != "*" {
+		t.Fatalf("expected any origin to be allowed with *, got %q", allowOrigin)
+	}
+	if p, _ := New(Config{}); p.Enabled() {
+		t.Fatalf("expected a policy without origins to be disabled")
+	}
+}
+
+func TestNewRejectsInvalidPolicies(t *testing.T) {
+	for _, cfg := range []Config{
+		{AllowedOrigins: []string{"app.example.com"}},
+		{AllowedOrigins: []string{"https://app.*.example.com"}},
+		{AllowedOrigins: []string{"https://app.example.com/path"}},
+		{AllowedOrigins: []string{AnyOrigin}, AllowCredentials: true},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestPolicyHeaders(t *testing.T) {
+	p, err := New(Config{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"get", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "Idempotency-Key"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if !p.AllowsMethod(http.MethodGet) || p.AllowsMethod(http.MethodDelete) {
+		t.Fatalf("unexpected method checks")
+	}
+	if !p.AllowsHeaders("content-type,idempotency-key") || !p.AllowsHeaders("") || p.AllowsHeaders("Content-Type, X-Other") {
+		t.Fatalf("unexpected header checks")
+	}
+
+	h := http.Header{}
+	p.SetPreflightHeaders(h)
+	if h.Get("Access-Control-Allow-Methods") != "GET,PUT" || h.Get("Access-Control-Max-Age") != "600" || h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("unexpected preflight headers %v", h)
+	}
+	h = http.Header{}
+	p.SetResponseHeaders(h)
+	if h.Get("Access-Control-Expose-Headers") != "X-Request-ID" || h.Get("Access-Control-Allow-Methods") != "" {
+		t.Fatalf("unexpected response headers %v", h)
+	}
+}
@@ -0,0 +1,5 @@
+// Package cors describes which cross-origin requests browsers may make to
+// the API: the allowed origins, matched exactly or by wildcard subdomain,
+// methods, request headers, credentials and how long preflight results may
+// be cached.
+package cors
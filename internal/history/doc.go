@@ -0,0 +1,3 @@
+// Package history records the calculations served by the API in a
+// size-bounded store so they can be audited and listed later.
+package history
@@ -0,0 +1,126 @@
+package history
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultLimit is the page size used when a query does not set one.
+	DefaultLimit = 50
+	// MaxLimit bounds the page size of a single query.
+	MaxLimit = 1000
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by the store.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Outcome classifies a recorded calculation.
+type Outcome string
+
+// Calculation outcomes.
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeError   Outcome = "error"
+)
+
+// Record is one audited calculation.
+type Record struct {
+	// ID is assigned by the store and increases with every record.
+	ID              uint64
+	RequestID       string
+	Actor           string
+	Items           int
+	PackSizes       []int
+	PackSizeVersion uint64
+	Packs           map[int]int
+	TotalPacks      int
+	Outcome         Outcome
+	// Status is the HTTP status returned to the caller.
+	Status int
+	// Error and Details describe the failure for error outcomes.
+	Error     string
+	Details   string
+	Duration  time.Duration
+	Timestamp time.Time
+}
+
+// Filter selects records. Zero fields do not filter.
+type Filter struct {
+	// From and To bound the timestamp; From is inclusive, To exclusive.
+	From     time.Time
+	To       time.Time
+	Outcome  Outcome
+	MinItems int
+	MaxItems int
+}
+
+// Matches reports whether r satisfies the filter.
+func (f Filter) Matches(r Record) bool {
+	switch {
+	case !f.From.IsZero() && r.Timestamp.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.Timestamp.Before(f.To):
+		return false
+	case f.Outcome != "" && r.Outcome != f.Outcome:
+		return false
+	case f.MinItems > 0 && r.Items < f.MinItems:
+		return false
+	case f.MaxItems > 0 && r.Items > f.MaxItems:
+		return false
+	}
+	return true
+}
+
+// Query is a filtered, paginated listing request. Records are listed newest
+// first, and Cursor continues after the last record of a previous page.
+type Query struct {
+	Filter
+	Cursor string
+	Limit  int
+}
+
+// Page is one page of records.
+type Page struct {
+	Records []Record
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// Store persists calculation records. Implementations must be safe for
+// concurrent use and bound the memory or space they use.
+type Store interface {
+	// Append records r, assigning its ID.
+	Append(r Record) error
+	// List returns the records matching q.
+	List(q Query) (Page, error)
+}
+
+// encodeCursor and decodeCursor keep cursors opaque to clients.
+func encodeCursor(id uint64) string {
+	return strconv.FormatUint(id, 36)
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(cursor, 36, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// normalizeLimit applies the default and maximum page sizes.
+func normalizeLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultLimit
+	case limit > MaxLimit:
+		return MaxLimit
+	default:
+		return limit
+	}
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// MemoryStore keeps the most recent records in a fixed-size ring buffer.
+// Older records are overwritten once the store is full.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []Record
+	next    int
+	lastID  uint64
+}
+
+// NewMemoryStore creates a store that retains up to capacity records. A
+// capacity below one is treated as one.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{records: make([]Record, 0, max(capacity, 1))}
+}
+
+// Append stores a copy of r, evicting the oldest record when full.
+func (s *MemoryStore) Append(r Record) error {
+	r.PackSizes = slices.Clone(r.PackSizes)
+	r.Packs = maps.Clone(r.Packs)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	r.ID = s.lastID
+	if len(s.records) < cap(s.records) {
+		s.records = append(s.records, r)
+		return nil
+	}
+	s.records[s.next] = r
+	s.next = (s.next + 1) % len(s.records)
+	return nil
+}
+
+// List returns matching records, newest first.
+func (s *MemoryStore) List(q Query) (Page, error) {
+	before, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	limit := normalizeLimit(q.Limit)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var page Page
+	for i := range len(s.records) {
+		// Walk backwards from the most recently written slot.
+		r := s.records[(s.newest()-i+len(s.records))%len(s.records)]
+		if before != 0 && r.ID >= before {
+			continue
+		}
+		if !q.Filter.Matches(r) {
+			continue
+		}
+		if len(page.Records) == limit {
+			page.NextCursor = encodeCursor(page.Records[limit-1].ID)
+			break
+		}
+		r.PackSizes = slices.Clone(r.PackSizes)
+		r.Packs = maps.Clone(r.Packs)
+		page.Records = append(page.Records, r)
+	}
+	return page, nil
+}
+
+// Len returns the number of retained records.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// newest returns the index of the most recent record. Callers hold mu.
+func (s *MemoryStore) newest() int {
+	if len(s.records) < cap(s.records) {
+		return len(s.records) - 1
+	}
+	return (s.next - 1 + len(s.records)) % len(s.records)
+}
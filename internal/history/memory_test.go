@@ -0,0 +1,134 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+
+func fill(t *testing.T, store Store, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		outcome := OutcomeSuccess
+		if i%3 == 0 {
+			outcome = OutcomeError
+		}
+		err := store.Append(Record{
+			Items:     i * 100,
+			Outcome:   outcome,
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+}
+
+func ids(page Page) []uint64 {
+	out := make([]uint64, len(page.Records))
+	for i, r := range page.Records {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestMemoryStoreListsNewestFirstWithPagination(t *testing.T) {
+	store := NewMemoryStore(100)
+	fill(t, store, 7)
+
+	page, err := store.List(Query{Limit: 3})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if got := ids(page); len(got) != 3 || got[0] != 7 || got[2] != 5 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %v (cursor %q)", got, page.NextCursor)
+	}
+
+	page, err = store.List(Query{Limit: 3, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if got := ids(page); len(got) != 3 || got[0] != 4 || got[2] != 2 {
+		t.Fatalf("unexpected second page %v", got)
+	}
+
+	page, err = store.List(Query{Limit: 3, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if got := ids(page); len(got) != 1 || got[0] != 1 || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %v (cursor %q)", got, page.NextCursor)
+	}
+}
+
+func TestMemoryStoreFilters(t *testing.T) {
+	store := NewMemoryStore(100)
+	fill(t, store, 9)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []uint64
+	}{
+		{"outcome", Filter{Outcome: OutcomeError}, []uint64{9, 6, 3}},
+		{"items", Filter{MinItems: 300, MaxItems: 500}, []uint64{5, 4, 3}},
+		{"time", Filter{From: base.Add(2 * time.Minute), To: base.Add(4 * time.Minute)}, []uint64{3, 2}},
+		{"combined", Filter{Outcome: OutcomeSuccess, MinItems: 700}, []uint64{8, 7}},
+	}
+	for _, tc := range tests {
+		page, err := store.List(Query{Filter: tc.filter})
+		if err != nil {
+			t.Fatalf("%s: list failed: %v", tc.name, err)
+		}
+		got := ids(page)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestMemoryStoreEvictsOldest(t *testing.T) {
+	store := NewMemoryStore(4)
+	fill(t, store, 10)
+
+	if store.Len() != 4 {
+		t.Fatalf("expected 4 retained records, got %d", store.Len())
+	}
+	page, err := store.List(Query{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if got := ids(page); len(got) != 4 || got[0] != 10 || got[3] != 7 {
+		t.Fatalf("expected records 10..7, got %v", got)
+	}
+}
+
+func TestMemoryStoreCopiesRecords(t *testing.T) {
+	store := NewMemoryStore(2)
+	sizes := []int{250, 500}
+	packs := map[int]int{250: 1}
+	if err := store.Append(Record{PackSizes: sizes, Packs: packs}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	sizes[0] = 1
+	packs[250] = 9
+
+	page, _ := store.List(Query{})
+	if page.Records[0].PackSizes[0] != 250 || page.Records[0].Packs[250] != 1 {
+		t.Fatalf("expected the store to keep its own copy, got %+v", page.Records[0])
+	}
+}
+
+func TestMemoryStoreRejectsInvalidCursor(t *testing.T) {
+	store := NewMemoryStore(2)
+	if _, err := store.List(Query{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
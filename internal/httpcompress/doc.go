@@ -0,0 +1,4 @@
+// Package httpcompress compresses HTTP responses with a content coding the
+// client accepts. Small responses, responses that are not text, and
+// partial or empty responses are sent as they are.
+package httpcompress
@@ -0,0 +1,272 @@
+package httpcompress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMinSize is the smallest response body, in bytes, worth compressing.
+const DefaultMinSize = 1024
+
+// encoding is a supported content coding.
+type encoding struct {
+	name      string
+	newWriter func(w io.Writer) compressor
+}
+
+// compressor is implemented by *gzip.Writer and *zstd.Encoder.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// pooledGzipWriter returns its gzip.Writer to gzipWriters when closed.
+type pooledGzipWriter struct {
+	*gzip.Writer
+}
+
+func newGzipWriter(w io.Writer) compressor {
+	zw := gzipWriters.Get().(*gzip.Writer)
+	zw.Reset(w)
+	return pooledGzipWriter{zw}
+}
+
+func (p pooledGzipWriter) Close() error {
+	err := p.Writer.Close()
+	gzipWriters.Put(p.Writer)
+	return err
+}
+
+// zstdWindowSize is the largest window browsers decode in the zstd content
+// coding (RFC 9659).
+const zstdWindowSize = 8 << 20
+
+var zstdWriters = sync.Pool{
+	New: func() any {
+		// The options are valid, so NewWriter cannot fail.
+		zw, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(zstdWindowSize))
+		return zw
+	},
+}
+
+// pooledZstdWriter returns its zstd.Encoder to zstdWriters when closed.
+type pooledZstdWriter struct {
+	*zstd.Encoder
+}
+
+func newZstdWriter(w io.Writer) compressor {
+	zw := zstdWriters.Get().(*zstd.Encoder)
+	zw.Reset(w)
+	return pooledZstdWriter{zw}
+}
+
+func (p pooledZstdWriter) Close() error {
+	err := p.Encoder.Close()
+	zstdWriters.Put(p.Encoder)
+	return err
+}
+
+// encodings lists the supported content codings in order of preference:
+// zstd compresses better and faster, so it wins when both are accepted
+// equally.
+var encodings = []*encoding{
+	{name: "zstd", newWriter: newZstdWriter},
+	{name: "gzip", newWriter: newGzipWriter},
+}
+
+// Handler compresses the responses of next when the client accepts a
+// supported coding and the body reaches minSize bytes. Compressed responses
+// have their ETag made weak, since the compressed bytes are not those the
+// validator was computed for. A non-positive minSize disables compression.
+func Handler(next http.Handler, minSize int) http.Handler {
+	if minSize <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := negotiate(r.Header.Get("Accept-Encoding"))
+		if enc == nil || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &responseWriter{ResponseWriter: w, encoding: enc, minSize: minSize}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the supported coding with the highest quality in
+// header, or nil if the client accepts none of them.
+func negotiate(header string) *encoding {
+	if header == "" {
+		return nil
+	}
+	quality := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+
+	var best *encoding
+	bestQ := 0.0
+	for _, enc := range encodings {
+		q, ok := quality[enc.name]
+		if !ok {
+			q = quality["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether responses of contentType benefit from
+// compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+type writerState int
+
+const (
+	// buffering holds the body until it reaches minSize.
+	buffering writerState = iota
+	identity
+	compressing
+)
+
+// responseWriter buffers the start of the body to decide whether to
+// compress it.
+type responseWriter struct {
+	http.ResponseWriter
+	encoding *encoding
+	minSize  int
+
+	state      writerState
+	status     int
+	buf        []byte
+	compressor compressor
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status != 0 || w.state != buffering {
+		return
+	}
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	h := w.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent ||
+		h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || !compressible(h.Get("Content-Type")) {
+		w.state = identity
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.state {
+	case identity:
+		return w.ResponseWriter.Write(p)
+	case compressing:
+		return w.compressor.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startCompressing(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what has been written so far, compressed if the response is
+// eligible, so that streamed responses are not held back.
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.state == buffering {
+		_ = w.startCompressing()
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) startCompressing() error {
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding.name)
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.state = compressing
+	w.compressor = w.encoding.newWriter(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.compressor.Write(buf)
+	return err
+}
+
+// close sends a body that stayed below minSize as it is, or finishes the
+// compressed stream.
+func (w *responseWriter) close() {
+	switch w.state {
+	case buffering:
+		if w.status == 0 {
+			// Nothing was written; let net/http send its default response.
+			return
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.buf)
+	case compressing:
+		_ = w.compressor.Close()
+	}
+}
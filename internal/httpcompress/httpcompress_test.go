@@ -0,0 +1,135 @@
+package httpcompress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func serve(t *testing.T, h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+func TestHandlerCompressesLargeResponses(t *testing.T) {
+	body := strings.Repeat(`{"packSizes":[250,500,1000]}`, 100)
+	rec := serve(t, Handler(jsonHandler(body), 1024), "br;q=1.0, gzip;q=0.8")
+
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzip response, got %v", rec.Header())
+	}
+	if etag := rec.Header().Get("ETag"); etag != `W/"abc"` {
+		t.Fatalf("expected a weak ETag, got %q", etag)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("invalid gzip stream: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil || string(decoded) != body {
+		t.Fatalf("unexpected decompressed body (%v)", err)
+	}
+}
+
+func TestHandlerLeavesResponsesUncompressed(t *testing.T) {
+	large := strings.Repeat("x", 2048)
+	cases := map[string]struct {
+		handler        http.Handler
+		acceptEncoding string
+	}{
+		"below threshold":  {jsonHandler(`{"status":"ok"}`), "gzip"},
+		"not accepted":     {jsonHandler(large), "gzip;q=0, br"},
+		"no accept header": {jsonHandler(large), ""},
+		"binary content": {http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(w, large)
+		}), "gzip"},
+		"not modified": {http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotModified)
+		}), "*"},
+	}
+	for name, tc := range cases {
+		rec := serve(t, Handler(tc.handler, 1024), tc.acceptEncoding)
+		if rec.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%s: expected no compression, got %v", name, rec.Header())
+		}
+		if rec.Header().Get("ETag") == `W/"abc"` {
+			t.Fatalf("%s: expected the ETag to stay strong", name)
+		}
+	}
+
+	rec := serve(t, Handler(jsonHandler(`{"status":"ok"}`), 1024), "gzip")
+	if rec.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("expected the small body as written, got %q", rec.Body.String())
+	}
+	if h := jsonHandler(large); Handler(h, 0) == nil || serve(t, Handler(h, 0), "gzip").Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected a zero minimum size to disable compression")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"gzip":                        "gzip",
+		"deflate, gzip;q=0.5":         "gzip",
+		"zstd":                        "zstd",
+		"gzip, zstd":                  "zstd",
+		"gzip;q=1.0, zstd;q=0.5":      "gzip",
+		"br, zstd;q=0.8, gzip;q=0.9":  "gzip",
+		"*":                           "zstd",
+		"*;q=0.1, zstd;q=0":           "gzip",
+		"*;q=0.1, gzip;q=0, zstd;q=0": "",
+		"identity":                    "",
+		"gzip;q=invalid":              "",
+	}
+	for header, want := range cases {
+		got := ""
+		if enc := negotiate(header); enc != nil {
+			got = enc.name
+		}
+		if got != want {
+			t.Fatalf("negotiate(%q): expected %q, got %q", header, want, got)
+		}
+	}
+}
+
+func TestHandlerCompressesWithZstd(t *testing.T) {
+	body := strings.Repeat(`{"packSizes":[250,500,1000]}`, 100)
+	h := Handler(jsonHandler(body), 1024)
+
+	// Pooled encoders must produce independent streams.
+	for range 2 {
+		rec := serve(t, h, "gzip, zstd")
+		if rec.Header().Get("Content-Encoding") != "zstd" || rec.Header().Get("ETag") != `W/"abc"` {
+			t.Fatalf("expected a zstd response with a weak ETag, got %v", rec.Header())
+		}
+		zr, err := zstd.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("invalid zstd stream: %v", err)
+		}
+		decoded, err := io.ReadAll(zr)
+		zr.Close()
+		if err != nil || string(decoded) != body {
+			t.Fatalf("unexpected decompressed body (%v)", err)
+		}
+	}
+}
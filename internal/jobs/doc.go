@@ -0,0 +1,4 @@
+// Package jobs runs pack calculations asynchronously on a bounded worker pool
+// so that very large orders do not have to complete within a single HTTP
+// request.
+package jobs
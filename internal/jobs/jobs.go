@@ -0,0 +1,374 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+var (
+	// ErrQueueFull is returned when the job queue has no room for another job.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrNotFound is returned when a job does not exist or has expired.
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that has already completed.
+	ErrFinished = errors.New("job has already finished")
+	// ErrClosed is returned when submitting to a manager that has been closed.
+	ErrClosed = errors.New("job manager is closed")
+)
+
+// Status describes the lifecycle stage of a job.
+type Status string
+
+// Job statuses.
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCanceled  Status = "canceled"
+)
+
+// Finished reports whether the status is terminal.
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed || s == StatusCanceled
+}
+
+// Job is a point-in-time snapshot of an asynchronous calculation.
+type Job struct {
+	ID        string
+	Status    Status
+	Progress  float64
+	Items     int
+	PackSizes []int
+	// TieBreak is the policy the job was submitted with; empty means the
+	// calculator's default.
+	TieBreak   calculator.TieBreakPolicy
+	Result     map[int]int
+	Err        error
+	Duration   time.Duration
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Config controls the worker pool.
+type Config struct {
+	// Workers is the number of calculations that run concurrently.
+	Workers int
+	// QueueSize is the number of jobs that may wait for a free worker.
+	QueueSize int
+	// Retention is how long finished jobs remain available. A non-positive
+	// value uses DefaultRetention.
+	Retention time.Duration
+}
+
+// DefaultRetention is how long finished jobs remain available when
+// Config.Retention is not set.
+const DefaultRetention = time.Hour
+
+// Option configures Manager behaviour.
+type Option func(*Manager)
+
+// WithClock overrides the time source, primarily for tests.
+func WithClock(clock func() time.Time) Option {
+	return func(m *Manager) {
+		m.clock = clock
+	}
+}
+
+type job struct {
+	snapshot Job
+	cancel   context.CancelFunc
+}
+
+// Manager accepts calculation jobs and executes them on a fixed number of
+// workers.
+type Manager struct {
+	calculator calculator.Calculator
+	retention  time.Duration
+	clock      func() time.Time
+
+	queue chan *job
+	wg    sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	jobs        map[string]*job
+	closed      bool
+	subscribers []func(Job)
+}
+
+// NewManager creates a Manager and starts its workers.
+func NewManager(calc calculator.Calculator, cfg Config, opts ...Option) *Manager {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	queueSize := cfg.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		calculator: calc,
+		retention:  retention,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+		queue:  make(chan *job, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+	return m
+}
+
+// Submit queues a calculation and returns its initial snapshot. An empty
+// policy uses the calculator's default tie-break.
+func (m *Manager) Submit(items int, packSizes []int, policy calculator.TieBreakPolicy) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return Job{}, ErrClosed
+	}
+	m.expireLocked()
+
+	j := &job{snapshot: Job{
+		ID:        newJobID(),
+		Status:    StatusQueued,
+		Items:     items,
+		PackSizes: slices.Clone(packSizes),
+		TieBreak:  policy,
+		CreatedAt: m.clock(),
+	}}
+
+	select {
+	case m.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[j.snapshot.ID] = j
+	return cloneJob(j.snapshot), nil
+}
+
+// Get returns the current snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireLocked()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return cloneJob(j.snapshot), nil
+}
+
+// Cancel stops a queued or running job.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	m.expireLocked()
+	j, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return Job{}, ErrNotFound
+	}
+	if j.snapshot.Status.Finished() {
+		m.mu.Unlock()
+		return cloneJob(j.snapshot), ErrFinished
+	}
+	if j.cancel != nil {
+		j.cancel()
+	}
+	m.finishLocked(j, StatusCanceled, nil, context.Canceled)
+	m.mu.Unlock()
+
+	return m.publish(j), nil
+}
+
+// Subscribe registers fn to be called with the final snapshot of every job
+// once it has finished. Callbacks run on the goroutine that finished the
+// job, so they should return quickly.
+func (m *Manager) Subscribe(fn func(Job)) {
+	m.mu.Lock()
+	m.subscribers = append(m.subscribers, fn)
+	m.mu.Unlock()
+}
+
+// publish passes the snapshot of the finished job j to the subscribers
+// and returns it.
+func (m *Manager) publish(j *job) Job {
+	m.mu.Lock()
+	snapshot := cloneJob(j.snapshot)
+	subscribers := slices.Clone(m.subscribers)
+	m.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(cloneJob(snapshot))
+	}
+	return snapshot
+}
+
+// Close cancels outstanding jobs and waits for the workers to exit.
+func (m *Manager) Close() {
+	m.stopAccepting()
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones to
+// finish. If ctx is done first, the remaining jobs are cancelled and
+// Shutdown returns the context's error once the workers have exited.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stopAccepting()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	defer m.cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		m.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) stopAccepting() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for j := range m.queue {
+		m.run(j)
+	}
+}
+
+func (m *Manager) run(j *job) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+
+	m.mu.Lock()
+	if j.snapshot.Status != StatusQueued {
+		m.mu.Unlock()
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		m.finishLocked(j, StatusCanceled, nil, err)
+		m.mu.Unlock()
+		m.publish(j)
+		return
+	}
+	j.cancel = cancel
+	j.snapshot.Status = StatusRunning
+	j.snapshot.StartedAt = m.clock()
+	items, packSizes := j.snapshot.Items, j.snapshot.PackSizes
+	if j.snapshot.TieBreak != "" {
+		ctx = calculator.WithTieBreak(ctx, j.snapshot.TieBreak)
+	}
+	m.mu.Unlock()
+
+	ctx = calculator.WithProgress(ctx, func(fraction float64) {
+		m.mu.Lock()
+		if j.snapshot.Status == StatusRunning {
+			j.snapshot.Progress = fraction * 100
+		}
+		m.mu.Unlock()
+	})
+
+	start := time.Now()
+	result, err := calculator.CalculateWithContext(ctx, m.calculator, items, packSizes)
+	elapsed := time.Since(start)
+
+	m.mu.Lock()
+	if j.snapshot.Status != StatusRunning {
+		m.mu.Unlock()
+		return
+	}
+	j.snapshot.Duration = elapsed
+	switch {
+	case err == nil:
+		m.finishLocked(j, StatusSucceeded, result, nil)
+	case errors.Is(err, context.Canceled):
+		m.finishLocked(j, StatusCanceled, nil, err)
+	default:
+		m.finishLocked(j, StatusFailed, nil, err)
+	}
+	m.mu.Unlock()
+	m.publish(j)
+}
+
+func (m *Manager) finishLocked(j *job, status Status, result map[int]int, err error) {
+	j.snapshot.Status = status
+	j.snapshot.Result = result
+	j.snapshot.Err = err
+	j.snapshot.FinishedAt = m.clock()
+	if status == StatusSucceeded {
+		j.snapshot.Progress = 100
+	}
+	j.cancel = nil
+}
+
+func (m *Manager) expireLocked() {
+	cutoff := m.clock().Add(-m.retention)
+	for id, j := range m.jobs {
+		if j.snapshot.Status.Finished() && j.snapshot.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func cloneJob(src Job) Job {
+	out := src
+	out.PackSizes = slices.Clone(src.PackSizes)
+	if src.Result != nil {
+		out.Result = make(map[int]int, len(src.Result))
+		for size, count := range src.Result {
+			out.Result[size] = count
+		}
+	}
+	return out
+}
+
+func newJobID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
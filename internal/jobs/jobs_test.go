@@ -0,0 +1,328 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eugenenazirov/re-partners/internal/calculator"
+)
+
+// blockingCalculator waits until it is released or its context is cancelled.
+type blockingCalculator struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingCalculator() *blockingCalculator {
+	return &blockingCalculator{
+		started: make(chan struct{}, 16),
+		release: make(chan struct{}),
+	}
+}
+
+func (b *blockingCalculator) CalculatePacks(items int, packSizes []int) (map[int]int, error) {
+	return b.CalculatePacksContext(context.Background(), items, packSizes)
+}
+
+func (b *blockingCalculator) CalculatePacksContext(ctx context.Context, items int, _ []int) (map[int]int, error) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+		return map[int]int{items: 1}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *blockingCalculator) Release() {
+	b.once.Do(func() { close(b.release) })
+}
+
+func waitForStatus(t *testing.T, m *Manager, id string, want Status) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		if job.Status == want {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not reach status %s", id, want)
+	return Job{}
+}
+
+func TestManagerRunsJobToCompletion(t *testing.T) {
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(750, []int{250, 500, 1000}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	if job.ID == "" || job.Status != StatusQueued {
+		t.Fatalf("unexpected initial snapshot: %+v", job)
+	}
+
+	done := waitForStatus(t, m, job.ID, StatusSucceeded)
+	if done.Progress != 100 {
+		t.Fatalf("expected progress 100, got %f", done.Progress)
+	}
+	if done.Result[250] != 1 || done.Result[500] != 1 {
+		t.Fatalf("unexpected result: %v", done.Result)
+	}
+	if done.StartedAt.IsZero() || done.FinishedAt.IsZero() {
+		t.Fatalf("expected start and finish timestamps, got %+v", done)
+	}
+}
+
+func TestManagerRecordsCalculationFailure(t *testing.T) {
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(263, []int{250, 500}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	failed := waitForStatus(t, m, job.ID, StatusFailed)
+	if !errors.Is(failed.Err, calculator.ErrCannotFulfill) {
+		t.Fatalf("expected ErrCannotFulfill, got %v", failed.Err)
+	}
+}
+
+func TestManagerRejectsWhenQueueFull(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(func() {
+		calc.Release()
+		m.Close()
+	})
+
+	if _, err := m.Submit(1, []int{1}, ""); err != nil {
+		t.Fatalf("first Submit returned error: %v", err)
+	}
+	<-calc.started
+	if _, err := m.Submit(2, []int{1}, ""); err != nil {
+		t.Fatalf("second Submit returned error: %v", err)
+	}
+	if _, err := m.Submit(3, []int{1}, ""); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestManagerCancelsRunningJob(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(10, []int{1}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	<-calc.started
+
+	canceled, err := m.Cancel(job.ID)
+	if err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	if canceled.Status != StatusCanceled {
+		t.Fatalf("expected canceled status, got %s", canceled.Status)
+	}
+
+	if _, err := m.Cancel(job.ID); !errors.Is(err, ErrFinished) {
+		t.Fatalf("expected ErrFinished when cancelling twice, got %v", err)
+	}
+}
+
+func TestManagerCancelsQueuedJob(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(func() {
+		calc.Release()
+		m.Close()
+	})
+
+	if _, err := m.Submit(1, []int{1}, ""); err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	<-calc.started
+	queued, err := m.Submit(2, []int{1}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+
+	if _, err := m.Cancel(queued.ID); err != nil {
+		t.Fatalf("Cancel returned error: %v", err)
+	}
+	calc.Release()
+
+	job := waitForStatus(t, m, queued.ID, StatusCanceled)
+	if !job.StartedAt.IsZero() {
+		t.Fatalf("expected cancelled queued job never to start")
+	}
+}
+
+func TestManagerExpiresFinishedJobs(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1, Retention: time.Minute}, WithClock(clock))
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(500, []int{250}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	waitForStatus(t, m, job.ID, StatusSucceeded)
+
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+
+	if _, err := m.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected expired job to be gone, got %v", err)
+	}
+}
+
+func TestManagerCloseCancelsOutstandingJobs(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+
+	job, err := m.Submit(1, []int{1}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	<-calc.started
+
+	m.Close()
+
+	got, err := m.Get(job.ID)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if got.Status != StatusCanceled {
+		t.Fatalf("expected job to be cancelled on close, got %s", got.Status)
+	}
+	if _, err := m.Submit(1, []int{1}, ""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestManagerShutdownWaitsForJobs(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+
+	running, _ := m.Submit(1, []int{1}, "")
+	<-calc.started
+	queued, err := m.Submit(2, []int{1}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	calc.Release()
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		if job, _ := m.Get(id); job.Status != StatusSucceeded {
+			t.Fatalf("expected job %s to finish before shutdown returned, got %s", id, job.Status)
+		}
+	}
+	if _, err := m.Submit(1, []int{1}, ""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed after Shutdown, got %v", err)
+	}
+}
+
+func TestManagerShutdownCancelsJobsWhenContextEnds(t *testing.T) {
+	calc := newBlockingCalculator()
+	m := NewManager(calc, Config{Workers: 1, QueueSize: 1})
+
+	job, _ := m.Submit(1, []int{1}, "")
+	<-calc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be reported, got %v", err)
+	}
+	if got, _ := m.Get(job.ID); got.Status != StatusCanceled {
+		t.Fatalf("expected the running job to be cancelled, got %s", got.Status)
+	}
+}
+
+func TestManagerAppliesTieBreak(t *testing.T) {
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(4, []int{1, 2, 3}, calculator.TieBreakLargerPacks)
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	done := waitForStatus(t, m, job.ID, StatusSucceeded)
+	if done.TieBreak != calculator.TieBreakLargerPacks || done.Result[1] != 1 || done.Result[3] != 1 {
+		t.Fatalf("expected the larger-packs distribution, got %+v", done)
+	}
+}
+
+func TestManagerExpiresJobsByDefault(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2024, 11, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1}, WithClock(clock))
+	t.Cleanup(m.Close)
+
+	job, err := m.Submit(500, []int{250}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	waitForStatus(t, m, job.ID, StatusSucceeded)
+
+	mu.Lock()
+	now = now.Add(DefaultRetention + time.Minute)
+	mu.Unlock()
+
+	if _, err := m.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected finished jobs to expire after DefaultRetention, got %v", err)
+	}
+}
+
+func TestManagerPublishesFinishedJobs(t *testing.T) {
+	m := NewManager(calculator.New(), Config{Workers: 1, QueueSize: 1})
+	t.Cleanup(m.Close)
+	finished := make(chan Job, 2)
+	m.Subscribe(func(job Job) {
+		finished <- job
+	})
+
+	job, err := m.Submit(750, []int{250, 500}, "")
+	if err != nil {
+		t.Fatalf("Submit returned error: %v", err)
+	}
+	select {
+	case done := <-finished:
+		if done.ID != job.ID || done.Status != StatusSucceeded || done.Result[250] != 1 {
+			t.Fatalf("unexpected published job %+v", done)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the finished job to be published")
+	}
+}
@@ -0,0 +1,4 @@
+// Package listener opens the server's listeners: TCP addresses, Unix
+// sockets with configurable permissions, and sockets inherited through
+// systemd socket activation.
+package listener
@@ -0,0 +1,173 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Networks an Address can refer to.
+const (
+	NetworkTCP     = "tcp"
+	NetworkUnix    = "unix"
+	NetworkSystemd = "systemd"
+)
+
+const (
+	unixPrefix    = "unix:"
+	systemdPrefix = "systemd"
+
+	// DefaultSocketMode is the permission given to Unix sockets: the owner
+	// and its group, typically the local proxy, may connect.
+	DefaultSocketMode fs.FileMode = 0o660
+
+	// firstInheritedFD is the first descriptor passed by systemd.
+	firstInheritedFD = 3
+)
+
+// Address is a parsed listen address.
+type Address struct {
+	Network string
+	// Path is the TCP host:port, the Unix socket path, or the systemd
+	// socket name (empty for the next unnamed inherited socket).
+	Path string
+}
+
+func (a Address) String() string {
+	switch a.Network {
+	case NetworkUnix:
+		return unixPrefix + a.Path
+	case NetworkSystemd:
+		if a.Path == "" {
+			return systemdPrefix
+		}
+		return systemdPrefix + ":" + a.Path
+	default:
+		return a.Path
+	}
+}
+
+// ParseAddress parses a listen address:
+//
+//   - "8080", ":8080" or "127.0.0.1:8080" listen on TCP;
+//   - "unix:/run/app.sock" listens on a Unix socket;
+//   - "systemd" uses the next socket passed by systemd socket activation,
+//     and "systemd:NAME" the one named NAME in FileDescriptorName=.
+func ParseAddress(raw string) (Address, error) {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return Address{}, errors.New("address must not be empty")
+	case strings.HasPrefix(raw, unixPrefix):
+		path := strings.TrimPrefix(raw, unixPrefix)
+		if path == "" {
+			return Address{}, fmt.Errorf("address %q has no socket path", raw)
+		}
+		return Address{Network: NetworkUnix, Path: path}, nil
+	case raw == systemdPrefix:
+		return Address{Network: NetworkSystemd}, nil
+	case strings.HasPrefix(raw, systemdPrefix+":"):
+		name := strings.TrimPrefix(raw, systemdPrefix+":")
+		if name == "" {
+			return Address{}, fmt.Errorf("address %q has no socket name", raw)
+		}
+		return Address{Network: NetworkSystemd, Path: name}, nil
+	}
+
+	if !strings.Contains(raw, ":") {
+		raw = ":" + raw
+	}
+	_, port, err := net.SplitHostPort(raw)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %q: %w", raw, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return Address{}, fmt.Errorf("invalid port in address %q", raw)
+	}
+	return Address{Network: NetworkTCP, Path: raw}, nil
+}
+
+// ParseSocketMode parses an octal permission such as "0660".
+func ParseSocketMode(raw string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(raw), 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q (use octal permissions such as 0660)", raw)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// inherited is a socket passed by systemd.
+type inherited struct {
+	name string
+	file *os.File
+	used bool
+}
+
+// Opener opens listeners for addresses. It takes ownership of the sockets
+// passed by systemd socket activation; each can be opened once.
+type Opener struct {
+	socketMode fs.FileMode
+	inherited  []*inherited
+}
+
+// NewOpener returns an Opener creating Unix sockets with socketMode. It
+// takes the sockets passed through LISTEN_FDS when LISTEN_PID names this
+// process, and clears those variables so child processes do not inherit
+// them.
+func NewOpener(socketMode fs.FileMode) (*Opener, error) {
+	files, err := inheritedFiles(os.Getenv, os.Getpid(), firstInheritedFD)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(name)
+	}
+	return &Opener{socketMode: socketMode, inherited: files}, nil
+}
+
+// Open starts listening on address.
+func (o *Opener) Open(address Address) (net.Listener, error) {
+	switch address.Network {
+	case NetworkUnix:
+		return o.openUnix(address.Path)
+	case NetworkSystemd:
+		return o.openInherited(address.Path)
+	default:
+		return net.Listen("tcp", address.Path)
+	}
+}
+
+// openInherited returns the inherited socket named name, or the first
+// unused one when name is empty.
+func (o *Opener) openInherited(name string) (net.Listener, error) {
+	for _, in := range o.inherited {
+		if in.used || (name != "" && in.name != name) {
+			continue
+		}
+		ln, err := net.FileListener(in.file)
+		if err != nil {
+			return nil, fmt.Errorf("use inherited socket %s: %w", in.file.Name(), err)
+		}
+		in.used = true
+		in.file.Close()
+		return ln, nil
+	}
+	if name == "" {
+		return nil, errors.New("no unused socket was passed by systemd (LISTEN_FDS)")
+	}
+	return nil, fmt.Errorf("no socket named %q was passed by systemd (LISTEN_FDNAMES)", name)
+}
+
+// Close releases the inherited sockets that were not opened.
+func (o *Opener) Close() {
+	for _, in := range o.inherited {
+		if !in.used {
+			in.used = true
+			in.file.Close()
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// inheritedFiles reports systemd socket activation as unsupported when
+// LISTEN_FDS is set.
+func inheritedFiles(getenv func(string) string, _, _ int) ([]*inherited, error) {
+	if getenv("LISTEN_FDS") != "" {
+		return nil, errors.New("systemd socket activation is not supported on this platform")
+	}
+	return nil, nil
+}
+
+// openUnix reports Unix socket listeners as unsupported.
+func (o *Opener) openUnix(path string) (net.Listener, error) {
+	return nil, fmt.Errorf("listen on %s: Unix sockets are not supported on this platform", path)
+}
@@ -0,0 +1,38 @@
+package listener
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	cases := map[string]Address{
+		"8080":               {Network: NetworkTCP, Path: ":8080"},
+		"127.0.0.1:9000":     {Network: NetworkTCP, Path: "127.0.0.1:9000"},
+		"unix:/run/api.sock": {Network: NetworkUnix, Path: "/run/api.sock"},
+		"systemd":            {Network: NetworkSystemd},
+		"systemd:admin":      {Network: NetworkSystemd, Path: "admin"},
+	}
+	for raw, want := range cases {
+		got, err := ParseAddress(raw)
+		if err != nil || got != want {
+			t.Fatalf("%s: expected %+v, got %+v (%v)", raw, want, got, err)
+		}
+		if raw != "8080" && got.String() != raw {
+			t.Fatalf("%s: expected String to round-trip, got %q", raw, got.String())
+		}
+	}
+	for _, raw := range []string{"", "unix:", "systemd:", "70000", "host:port"} {
+		if _, err := ParseAddress(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestParseSocketMode(t *testing.T) {
+	if mode, err := ParseSocketMode("0600"); err != nil || mode != 0o600 {
+		t.Fatalf("expected 0600, got %v (%v)", mode, err)
+	}
+	for _, raw := range []string{"rw", "0800", "1777"} {
+		if _, err := ParseSocketMode(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+//go:build unix
+
+package listener
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// inheritedFiles implements the sd_listen_fds protocol.
+func inheritedFiles(getenv func(string) string, pid, first int) ([]*inherited, error) {
+	rawPID, rawFDs := getenv("LISTEN_PID"), getenv("LISTEN_FDS")
+	if rawPID == "" || rawFDs == "" {
+		return nil, nil
+	}
+	if listenPID, err := strconv.Atoi(rawPID); err != nil || listenPID != pid {
+		// The variables were meant for another process.
+		return nil, nil
+	}
+	count, err := strconv.Atoi(rawFDs)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", rawFDs)
+	}
+	var names []string
+	if raw := getenv("LISTEN_FDNAMES"); raw != "" {
+		names = strings.Split(raw, ":")
+	}
+
+	files := make([]*inherited, count)
+	for i := range count {
+		fd := first + i
+		syscall.CloseOnExec(fd)
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		files[i] = &inherited{name: name, file: os.NewFile(uintptr(fd), "systemd:"+name)}
+	}
+	return files, nil
+}
+
+// openUnix listens on path, replacing a socket left behind by a previous
+// run. A socket another process still listens on, or any other file at
+// path, is an error. The socket is removed when the listener is closed.
+//
+// The socket is created in a private directory next to path and moved into
+// place once it has its permissions, so it is never reachable with more.
+func (o *Opener) openUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("listen on %s: file exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen on %s: socket is in use by another process", path)
+		}
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".sock")
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "s")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: private, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(private, o.socketMode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("set permissions on %s: %w", path, err)
+	}
+	// Rename replaces a stale socket atomically.
+	if err := os.Rename(private, path); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	return &unixListener{UnixListener: ln, path: path}, nil
+}
+
+// unixListener reports and removes the socket at path, where it was moved
+// after being created.
+type unixListener struct {
+	*net.UnixListener
+	path   string
+	remove sync.Once
+}
+
+func (l *unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.remove.Do(func() { _ = os.Remove(l.path) })
+	return err
+}
@@ -0,0 +1,133 @@
+//go:build unix
+
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	opener := &Opener{socketMode: 0o600}
+
+	ln, err := opener.Open(Address{Network: NetworkUnix, Path: path})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected socket with mode 0600, got %v (%v)", info, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("expected only the socket in its directory, got %v", entries)
+	}
+	if ln.Addr().String() != path {
+		t.Fatalf("expected the listener to report %s, got %s", path, ln.Addr())
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dial socket: %v", err)
+	}
+	conn.Close()
+	ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket to be removed on close, got %v", err)
+	}
+}
+
+func TestOpenUnixSocketReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	opener := &Opener{socketMode: DefaultSocketMode}
+
+	// A crashed process leaves its socket behind.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := opener.Open(Address{Network: NetworkUnix, Path: path})
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+
+	regular := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(regular, nil, 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := opener.Open(Address{Network: NetworkUnix, Path: regular}); err == nil {
+		t.Fatalf("expected a regular file not to be replaced")
+	}
+}
+
+func TestOpenUnixSocketRefusesLiveSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	opener := &Opener{socketMode: DefaultSocketMode}
+
+	live, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer live.Close()
+
+	if _, err := opener.Open(Address{Network: NetworkUnix, Path: path}); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("expected a socket in use to be refused, got %v", err)
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("expected the other process to keep its socket, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestOpenInheritedSockets(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("file: %v", err)
+	}
+
+	env := map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "1", "LISTEN_FDNAMES": "api"}
+	getenv := func(key string) string { return env[key] }
+
+	if files, err := inheritedFiles(getenv, 7, int(file.Fd())); err != nil || len(files) != 0 {
+		t.Fatalf("expected sockets for another process to be ignored, got %v (%v)", files, err)
+	}
+
+	files, err := inheritedFiles(getenv, 42, int(file.Fd()))
+	if err != nil || len(files) != 1 || files[0].name != "api" {
+		t.Fatalf("expected one socket named api, got %v (%v)", files, err)
+	}
+	opener := &Opener{inherited: files}
+	defer opener.Close()
+
+	if _, err := opener.Open(Address{Network: NetworkSystemd, Path: "admin"}); err == nil {
+		t.Fatalf("expected an unknown socket name to be rejected")
+	}
+	ln, err := opener.Open(Address{Network: NetworkSystemd, Path: "api"})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != tcp.Addr().String() {
+		t.Fatalf("expected the inherited address %s, got %s", tcp.Addr(), ln.Addr())
+	}
+	if _, err := opener.Open(Address{Network: NetworkSystemd}); err == nil {
+		t.Fatalf("expected each inherited socket to be opened once")
+	}
+
+	env["LISTEN_FDS"] = "many"
+	if _, err := inheritedFiles(getenv, 42, 3); err == nil {
+		t.Fatalf("expected an invalid LISTEN_FDS to be rejected")
+	}
+}
@@ -0,0 +1,25 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) == nil {
+		t.Fatalf("expected a no-op logger without one in the context")
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := NewContext(context.Background(), zap.New(core).With(zap.String("request_id", "abc")))
+	FromContext(ctx).Info("pack sizes replaced")
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["request_id"] != "abc" {
+		t.Fatalf("expected the entry to carry the request ID, got %+v", entries)
+	}
+}
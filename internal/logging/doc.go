@@ -1,2 +1,3 @@
-// Package logging centralizes construction of structured loggers.
+// Package logging centralizes construction of structured loggers and carries
+// request-scoped loggers through contexts (see NewContext and FromContext).
 package logging
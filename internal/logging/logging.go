@@ -2,23 +2,102 @@ package logging
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-// New creates a production-ready structured logger configured for JSON output.
-func New() (*zap.Logger, error) {
-	cfg := zap.NewProductionConfig()
-	cfg.Encoding = "json"
-	cfg.EncoderConfig.TimeKey = "timestamp"
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	cfg.EncoderConfig.StacktraceKey = "stacktrace"
-	cfg.DisableStacktrace = false
+// Log encodings.
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Config describes how logs are written. The zero value writes JSON at
+// info level to stderr.
+type Config struct {
+	// Level is the minimum level: debug, info, warn or error.
+	Level string
+	// Format is FormatJSON or FormatConsole, a human-readable format for
+	// local development.
+	Format string
+	// OutputPaths are "stdout", "stderr" or file paths.
+	OutputPaths []string
+}
 
-	logger, err := cfg.Build()
+// ParseLevel parses a level name such as "debug" or "warn".
+func ParseLevel(raw string) (zapcore.Level, error) {
+	level, err := zapcore.ParseLevel(strings.TrimSpace(raw))
 	if err != nil {
-		return nil, fmt.Errorf("build logger: %w", err)
+		return level, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", raw)
+	}
+	return level, nil
+}
+
+// ParseFormat checks a log encoding.
+func ParseFormat(raw string) (string, error) {
+	switch format := strings.TrimSpace(raw); format {
+	case FormatJSON, FormatConsole:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (use %s or %s)", raw, FormatJSON, FormatConsole)
+	}
+}
+
+// New creates a structured logger from cfg. The returned level controls the
+// logger and can be changed while it is in use.
+func New(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevel()
+	if cfg.Level != "" {
+		parsed, err := ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, level, err
+		}
+		level.SetLevel(parsed)
+	}
+	format := FormatJSON
+	if cfg.Format != "" {
+		var err error
+		if format, err = ParseFormat(cfg.Format); err != nil {
+			return nil, level, err
+		}
+	}
+
+	zcfg := zap.NewProductionConfig()
+	zcfg.Level = level
+	zcfg.Encoding = format
+	zcfg.EncoderConfig.TimeKey = "timestamp"
+	zcfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zcfg.EncoderConfig.StacktraceKey = "stacktrace"
+	zcfg.DisableStacktrace = false
+	// Access logs are sampled separately (see Sampled), so other entries
+	// are never dropped.
+	zcfg.Sampling = nil
+	if format == FormatConsole {
+		zcfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+		zcfg.EncoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+	}
+	if len(cfg.OutputPaths) > 0 {
+		zcfg.OutputPaths = cfg.OutputPaths
+	}
+
+	logger, err := zcfg.Build()
+	if err != nil {
+		return nil, level, fmt.Errorf("build logger: %w", err)
+	}
+	return logger, level, nil
+}
+
+// Sampled returns a logger that, every second, writes the first initial
+// entries with the same level and message and then every thereafter-th
+// one. With both zero, logger is returned unchanged.
+func Sampled(logger *zap.Logger, initial, thereafter int) *zap.Logger {
+	if initial <= 0 && thereafter <= 0 {
+		return logger
 	}
-	return logger, nil
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewSamplerWithOptions(core, time.Second, initial, thereafter)
+	}))
 }
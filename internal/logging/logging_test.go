@@ -1,14 +1,73 @@
 package logging
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
 
 func TestNew(t *testing.T) {
-	logger, err := New()
+	logger, level, err := New(Config{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if logger == nil {
 		t.Fatalf("expected logger instance")
 	}
+	if level.Level() != zapcore.InfoLevel {
+		t.Fatalf("expected info level by default, got %s", level.Level())
+	}
 	_ = logger.Sync()
 }
+
+func TestNewWritesConsoleFormatAtConfiguredLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	logger, level, err := New(Config{Level: "debug", Format: FormatConsole, OutputPaths: []string{path}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Debug("table rebuilt", zap.Int("sizes", 3))
+	level.SetLevel(zapcore.WarnLevel)
+	logger.Info("dropped")
+	_ = logger.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "\tDEBUG\t") || !strings.Contains(out, "table rebuilt\t{\"sizes\": 3}") || strings.Contains(out, "dropped") {
+		t.Fatalf("unexpected console output %q", out)
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	if _, _, err := New(Config{Level: "loud"}); err == nil {
+		t.Fatalf("expected an unknown level to be rejected")
+	}
+	if _, _, err := New(Config{Format: "xml"}); err == nil {
+		t.Fatalf("expected an unknown format to be rejected")
+	}
+}
+
+func TestSampled(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+	if Sampled(logger, 0, 0) != logger {
+		t.Fatalf("expected sampling to be disabled")
+	}
+
+	sampled := Sampled(logger, 2, 5)
+	for range 12 {
+		sampled.Info("request completed")
+	}
+	// The first two entries, then the 5th and 10th of the rest.
+	if got := logs.Len(); got != 4 {
+		t.Fatalf("expected 4 sampled entries, got %d", got)
+	}
+}
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"sort"
 	"sync"
+
+	"github.com/eugenenazirov/re-partners/internal/logging"
+	"go.uber.org/zap"
 )
 
 const maxPackSizes = 10
@@ -21,16 +25,53 @@ type Storage interface {
 	SetPackSizes(sizes []int) error
 }
 
+// ContextStorage is implemented by storages that accept the caller's context
+// when pack sizes are replaced, e.g. to log with its request-scoped logger.
+type ContextStorage interface {
+	SetPackSizesContext(ctx context.Context, sizes []int) error
+}
+
+// SetPackSizesWithContext stores sizes in s, passing ctx along when s
+// implements ContextStorage.
+func SetPackSizesWithContext(ctx context.Context, s Storage, sizes []int) error {
+	if cs, ok := s.(ContextStorage); ok {
+		return cs.SetPackSizesContext(ctx, sizes)
+	}
+	return s.SetPackSizes(sizes)
+}
+
+// Snapshot is a versioned copy of the configured pack sizes. The version
+// increases every time the pack sizes are replaced.
+type Snapshot struct {
+	Version   uint64
+	PackSizes []int
+}
+
+// Versioned is implemented by storages that can return the pack sizes together
+// with their version in one consistent read.
+type Versioned interface {
+	Snapshot() Snapshot
+}
+
+// Observable is implemented by storages that notify listeners when the pack
+// sizes change.
+type Observable interface {
+	Subscribe(fn func(Snapshot))
+}
+
 // MemoryStorage keeps pack sizes in-memory and guards access with a RWMutex.
 type MemoryStorage struct {
-	mu        sync.RWMutex
-	packSizes []int
+	mu          sync.RWMutex
+	packSizes   []int
+	version     uint64
+	subscribers []func(Snapshot)
 }
 
 // NewMemoryStorage initialises storage with a copy of the default pack sizes.
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
 		packSizes: cloneAndSort(defaultPackSizes),
+		version:   1,
 	}
 }
 
@@ -49,18 +90,53 @@ func (s *MemoryStorage) GetPackSizes() ([]int, error) {
 
 // SetPackSizes validates, normalises, and stores the provided pack sizes.
 func (s *MemoryStorage) SetPackSizes(sizes []int) error {
-	normalized, err := normalizePackSizes(sizes)
+	return s.SetPackSizesContext(context.Background(), sizes)
+}
+
+// SetPackSizesContext behaves like SetPackSizes and logs the change with the
+// logger carried by ctx.
+func (s *MemoryStorage) SetPackSizesContext(ctx context.Context, sizes []int) error {
+	normalized, err := NormalizePackSizes(sizes)
 	if err != nil {
 		return err
 	}
 
 	s.mu.Lock()
+	previous := s.packSizes
 	s.packSizes = normalized
+	s.version++
+	snapshot := Snapshot{Version: s.version, PackSizes: cloneAndSort(normalized)}
+	subscribers := append([]func(Snapshot){}, s.subscribers...)
 	s.mu.Unlock()
 
+	logging.FromContext(ctx).Info("pack sizes replaced",
+		zap.Ints("previous", previous),
+		zap.Ints("pack_sizes", normalized),
+		zap.Uint64("version", snapshot.Version),
+	)
+	for _, fn := range subscribers {
+		fn(snapshot)
+	}
+
 	return nil
 }
 
+// Snapshot returns the current pack sizes together with their version.
+func (s *MemoryStorage) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return Snapshot{Version: s.version, PackSizes: cloneAndSort(s.packSizes)}
+}
+
+// Subscribe registers fn to be called after every successful SetPackSizes.
+// Callbacks run synchronously on the caller's goroutine and must not block.
+func (s *MemoryStorage) Subscribe(fn func(Snapshot)) {
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, fn)
+	s.mu.Unlock()
+}
+
 func cloneAndSort(src []int) []int {
 	if len(src) == 0 {
 		return []int{}
@@ -72,7 +148,9 @@ func cloneAndSort(src []int) []int {
 	return out
 }
 
-func normalizePackSizes(packSizes []int) ([]int, error) {
+// NormalizePackSizes validates sizes with the storage rules and returns them
+// de-duplicated and sorted ascending.
+func NormalizePackSizes(packSizes []int) ([]int, error) {
 	if len(packSizes) == 0 {
 		return nil, ErrInvalidPackSizes
 	}
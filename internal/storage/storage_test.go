@@ -105,3 +105,35 @@ func TestMemoryStorageConcurrentAccess(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestSetPackSizesNotifiesSubscribers(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStorage()
+	initial := store.Snapshot()
+
+	var got []Snapshot
+	store.Subscribe(func(s Snapshot) {
+		got = append(got, s)
+	})
+
+	if err := store.SetPackSizes([]int{0}); err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if err := store.SetPackSizes([]int{31, 23}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected one notification for the successful update, got %d", len(got))
+	}
+	if got[0].Version != initial.Version+1 {
+		t.Fatalf("expected version %d, got %d", initial.Version+1, got[0].Version)
+	}
+	if want := []int{23, 31}; !slices.Equal(got[0].PackSizes, want) {
+		t.Fatalf("expected snapshot sizes %v, got %v", want, got[0].PackSizes)
+	}
+	if current := store.Snapshot(); current.Version != got[0].Version {
+		t.Fatalf("expected Snapshot to report version %d, got %d", got[0].Version, current.Version)
+	}
+}
@@ -0,0 +1,4 @@
+// Package tlsconfig builds the server's TLS configuration from certificate
+// files and reloads them when they are rotated, so certificates can be
+// renewed without restarting the server.
+package tlsconfig
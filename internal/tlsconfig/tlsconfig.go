@@ -0,0 +1,199 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultCheckInterval bounds how often handshakes check the files for
+// changes.
+const defaultCheckInterval = 5 * time.Second
+
+// Client authentication policies for mutual TLS.
+const (
+	// ClientAuthRequire rejects clients without a certificate signed by the
+	// client CA.
+	ClientAuthRequire = "require"
+	// ClientAuthOptional verifies a client certificate when one is sent but
+	// also accepts clients without one.
+	ClientAuthOptional = "optional"
+)
+
+// Config describes the server certificate and the mutual TLS policy.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against the CAs in this PEM file.
+	ClientCAFile string
+	MinVersion   uint16
+	ClientAuth   tls.ClientAuthType
+	// OnReloadError is called when changed files cannot be loaded. The
+	// previous certificates stay in use and loading is retried on the next
+	// check.
+	OnReloadError func(error)
+}
+
+// ParseVersion converts "1.2" or "1.3" to a TLS version.
+func ParseVersion(raw string) (uint16, error) {
+	switch strings.TrimSpace(raw) {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q (use 1.2 or 1.3)", raw)
+	}
+}
+
+// ParseClientAuth converts a client authentication policy to its tls type.
+func ParseClientAuth(raw string) (tls.ClientAuthType, error) {
+	switch strings.TrimSpace(raw) {
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth policy %q (use %s or %s)", raw, ClientAuthRequire, ClientAuthOptional)
+	}
+}
+
+// fileStamp identifies one version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader serves the certificates from the configured files and reloads
+// them when the files change. It is safe for concurrent use.
+type Reloader struct {
+	cfg           Config
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	stamps    []fileStamp
+
+	current atomic.Pointer[tls.Config]
+}
+
+// New loads the files and returns a Reloader serving them.
+func New(cfg Config) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("certificate and key files are required")
+	}
+	r := &Reloader{cfg: cfg, checkInterval: defaultCheckInterval}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the configuration to install in http.Server. Every
+// handshake uses the most recently loaded certificates.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.cfg.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.checkForChanges()
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload loads the files now. On error the previous certificates stay in
+// use.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+// checkForChanges reloads the files when their modification time or size
+// changed, at most once per check interval.
+func (r *Reloader) checkForChanges() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) < r.checkInterval {
+		return
+	}
+	r.lastCheck = now
+
+	stamps := r.stampFiles()
+	if stampsEqual(stamps, r.stamps) {
+		return
+	}
+	if err := r.load(); err != nil && r.cfg.OnReloadError != nil {
+		r.cfg.OnReloadError(err)
+	}
+}
+
+// load reads the files and swaps in a new configuration. Callers hold mu.
+func (r *Reloader) load() error {
+	// Stamp before reading so that a write racing with the read is picked
+	// up by the next check.
+	stamps := r.stampFiles()
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+
+	next := &tls.Config{
+		MinVersion:   r.cfg.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+		Certificates: []tls.Certificate{cert},
+	}
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("load client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("load client CA: no certificates found in %s", r.cfg.ClientCAFile)
+		}
+		next.ClientCAs = pool
+		next.ClientAuth = r.cfg.ClientAuth
+	}
+
+	r.current.Store(next)
+	r.stamps = stamps
+	return nil
+}
+
+func (r *Reloader) stampFiles() []fileStamp {
+	files := []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile}
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+func stampsEqual(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,246 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM certificate and key for commonName.
+func (ca *testCA) issue(t *testing.T, commonName string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writes gives every write a distinct modification time, even on file
+// systems with coarse timestamps.
+var writes = time.Now()
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	writes = writes.Add(time.Second)
+	if err := os.Chtimes(path, writes, writes); err != nil {
+		t.Fatalf("touch %s: %v", path, err)
+	}
+}
+
+// startServer serves r's configuration. Its handler echoes the verified
+// client certificate's common name in X-Client.
+func startServer(t *testing.T, r *Reloader) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if len(req.TLS.VerifiedChains) > 0 {
+			w.Header().Set("X-Client", req.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+	}))
+	server.TLS = r.TLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func client(ca *testCA, certs ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certs},
+		DisableKeepAlives: true,
+	}}
+}
+
+func servedSerial(t *testing.T, c *http.Client, url string) int64 {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestReloaderPicksUpRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	cert, key := ca.issue(t, "server", 10, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	var reloadErrors []error
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS12, OnReloadError: func(err error) {
+		reloadErrors = append(reloadErrors, err)
+	}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	r.checkInterval = 0
+	server := startServer(t, r)
+	c := client(ca)
+
+	if serial := servedSerial(t, c, server.URL); serial != 10 {
+		t.Fatalf("expected certificate 10, got %d", serial)
+	}
+
+	// A half-written rotation keeps the previous certificate.
+	cert, key = ca.issue(t, "server", 11, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert)
+	if serial := servedSerial(t, c, server.URL); serial != 10 || len(reloadErrors) != 1 {
+		t.Fatalf("expected certificate 10 and a reload error, got %d and %v", serial, reloadErrors)
+	}
+
+	writeFile(t, keyFile, key)
+	if serial := servedSerial(t, c, server.URL); serial != 11 {
+		t.Fatalf("expected rotated certificate 11, got %d", serial)
+	}
+}
+
+func TestReloaderReloadOnDemand(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	cert, key := ca.issue(t, "server", 20, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	server := startServer(t, r)
+
+	cert, key = ca.issue(t, "server", 21, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if serial := servedSerial(t, client(ca), server.URL); serial != 21 {
+		t.Fatalf("expected reloaded certificate 21, got %d", serial)
+	}
+}
+
+func TestReloaderMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	cert, key := ca.issue(t, "server", 30, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, cert)
+	writeFile(t, keyFile, key)
+	writeFile(t, caFile, ca.pem)
+
+	r, err := New(Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tls.RequireAndVerifyClientCert})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	server := startServer(t, r)
+
+	if _, err := client(ca).Get(server.URL); err == nil {
+		t.Fatalf("expected a client without a certificate to be rejected")
+	}
+
+	clientCert, clientKey := ca.issue(t, "warehouse-7", 31, x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("load client certificate: %v", err)
+	}
+	resp, err := client(ca, pair).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Client"); got != "warehouse-7" {
+		t.Fatalf("expected the verified client identity, got %q", got)
+	}
+}
+
+func TestNewRejectsMissingFiles(t *testing.T) {
+	if _, err := New(Config{CertFile: "/missing.crt", KeyFile: "/missing.key"}); err == nil {
+		t.Fatalf("expected missing files to be rejected")
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Fatalf("expected a certificate to be required")
+	}
+}
+
+func TestParseVersionAndClientAuth(t *testing.T) {
+	if v, err := ParseVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Fatalf("expected TLS 1.3, got %v (%v)", v, err)
+	}
+	if _, err := ParseVersion("1.1"); err == nil {
+		t.Fatalf("expected TLS 1.1 to be rejected")
+	}
+	if auth, err := ParseClientAuth(ClientAuthOptional); err != nil || auth != tls.VerifyClientCertIfGiven {
+		t.Fatalf("expected optional client auth, got %v (%v)", auth, err)
+	}
+	if _, err := ParseClientAuth("sometimes"); err == nil {
+		t.Fatalf("expected an unknown policy to be rejected")
+	}
+}
@@ -0,0 +1,4 @@
+// Package webui serves the browser UI: the index page and its static
+// assets, either embedded in the binary with content-hashed URLs or from a
+// directory during UI development.
+package webui
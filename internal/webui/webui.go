@@ -0,0 +1,162 @@
+package webui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	indexFile = "templates/index.html"
+	staticDir = "static"
+
+	// hashLength is the number of hex digits of the content hash put in
+	// asset URLs.
+	hashLength = 12
+
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate lets browsers keep a copy but check its ETag before
+	// every use.
+	cacheRevalidate = "no-cache"
+)
+
+// asset is one file served from memory.
+type asset struct {
+	name    string
+	data    []byte
+	etag    string
+	modTime time.Time
+}
+
+func (a *asset) serve(w http.ResponseWriter, r *http.Request, cacheControl string) {
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", a.etag)
+	http.ServeContent(w, r, a.name, a.modTime, bytes.NewReader(a.data))
+}
+
+// ui serves assets loaded once at startup.
+type ui struct {
+	index *asset
+	// static maps paths under /static/ to assets. Every asset is listed
+	// under its plain name and under its content-hashed name.
+	static map[string]*asset
+	hashed map[string]bool
+}
+
+// New returns a handler serving the UI in fsys, which holds static/ and
+// templates/index.html. Every asset is also served under a name containing
+// its content hash, e.g. /static/css/styles.3f2a9c0b1d4e.css, and the index
+// page links to those names, so assets can be cached indefinitely while a
+// new release is picked up on the next page load. The index page and the
+// plain asset names are revalidated with their ETag or, since embedded files
+// have no modification time, with the time New was called as Last-Modified.
+func New(fsys fs.FS) (http.Handler, error) {
+	u := &ui{static: make(map[string]*asset), hashed: make(map[string]bool)}
+	loaded := time.Now().UTC().Truncate(time.Second)
+	renames := make(map[string]string)
+
+	err := fs.WalkDir(fsys, staticDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		a := newAsset(p, data, loaded)
+		plain := strings.TrimPrefix(p, staticDir+"/")
+		hashed := hashedName(plain, strings.Trim(a.etag, `"`))
+		u.static[plain] = a
+		u.static[hashed] = a
+		u.hashed[hashed] = true
+		renames[plain] = hashed
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load static assets: %w", err)
+	}
+
+	index, err := fs.ReadFile(fsys, indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("load index page: %w", err)
+	}
+	for plain, hashed := range renames {
+		index = bytes.ReplaceAll(index, []byte(`"/static/`+plain+`"`), []byte(`"/static/`+hashed+`"`))
+	}
+	u.index = newAsset(indexFile, index, loaded)
+
+	return u.handler(), nil
+}
+
+func newAsset(name string, data []byte, modTime time.Time) *asset {
+	sum := sha256.Sum256(data)
+	return &asset{name: name, data: data, etag: `"` + hex.EncodeToString(sum[:])[:hashLength] + `"`, modTime: modTime}
+}
+
+// hashedName inserts hash before the extension of name.
+func hashedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+func (u *ui) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/static/")
+		a, ok := u.static[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if u.hashed[name] {
+			a.serve(w, r, cacheImmutable)
+			return
+		}
+		a.serve(w, r, cacheRevalidate)
+	}))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		u.index.serve(w, r, cacheRevalidate)
+	}))
+	return mux
+}
+
+// NewDir returns a handler serving the UI from dir on every request, for
+// UI development: edits show up on reload and nothing is cached.
+func NewDir(dir string) (http.Handler, error) {
+	index := filepath.Join(dir, filepath.FromSlash(indexFile))
+	if _, err := os.Stat(index); err != nil {
+		return nil, fmt.Errorf("load index page: %w", err)
+	}
+	static := filepath.Join(dir, staticDir)
+	if info, err := os.Stat(static); err != nil {
+		return nil, fmt.Errorf("load static assets: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("load static assets: %s is not a directory", static)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(static))))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, index)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		mux.ServeHTTP(w, r)
+	}), nil
+}
@@ -0,0 +1,123 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/eugenenazirov/re-partners/web"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"templates/index.html": {Data: []byte(`<link href="/static/css/app.css"><script src="/static/js/app.js"></script>`)},
+		"static/css/app.css":   {Data: []byte("body { color: red; }")},
+		"static/js/app.js":     {Data: []byte("console.log('hi');")},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewServesHashedAssets(t *testing.T) {
+	h, err := New(testFS())
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	index := get(t, h, "/")
+	if index.Code != http.StatusOK || index.Header().Get("Cache-Control") != cacheRevalidate || !strings.HasPrefix(index.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected index response %d %v", index.Code, index.Header())
+	}
+	css := regexp.MustCompile(`/static/css/app\.[0-9a-f]{12}\.css`).FindString(index.Body.String())
+	if css == "" || !strings.Contains(index.Body.String(), "/static/js/app.") {
+		t.Fatalf("expected the index to link hashed assets, got %s", index.Body.String())
+	}
+
+	hashed := get(t, h, css)
+	if hashed.Code != http.StatusOK || hashed.Header().Get("Cache-Control") != cacheImmutable || hashed.Body.String() != "body { color: red; }" {
+		t.Fatalf("unexpected hashed asset response %d %v", hashed.Code, hashed.Header())
+	}
+	if !strings.HasPrefix(hashed.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("expected a CSS content type, got %q", hashed.Header().Get("Content-Type"))
+	}
+
+	plain := get(t, h, "/static/css/app.css")
+	if plain.Code != http.StatusOK || plain.Header().Get("Cache-Control") != cacheRevalidate {
+		t.Fatalf("expected the plain name to be revalidated, got %d %v", plain.Code, plain.Header())
+	}
+	if rec := get(t, h, "/static/css/app.css", "If-None-Match", plain.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/", "If-None-Match", index.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for the index page, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/static/css/app.css", "If-Modified-Since", plain.Header().Get("Last-Modified")); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for an unmodified asset, got %d", rec.Code)
+	}
+
+	for _, path := range []string{"/static/css/missing.css", "/unknown"} {
+		if rec := get(t, h, path); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestNewServesEmbeddedUI(t *testing.T) {
+	h, err := New(web.Files)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	body := get(t, h, "/").Body.String()
+	script := regexp.MustCompile(`/static/js/app\.[0-9a-f]{12}\.js`).FindString(body)
+	if script == "" {
+		t.Fatalf("expected the embedded index to link a hashed app.js, got %s", body)
+	}
+	if rec := get(t, h, script); rec.Code != http.StatusOK {
+		t.Fatalf("expected the embedded script, got %d", rec.Code)
+	}
+}
+
+func TestNewDirServesFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewDir(dir); err == nil {
+		t.Fatalf("expected a directory without the UI to be rejected")
+	}
+	for name, file := range testFS() {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, file.Data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	h, err := NewDir(dir)
+	if err != nil {
+		t.Fatalf("NewDir returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "css", "app.css"), []byte("edited"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	rec := get(t, h, "/static/css/app.css")
+	if rec.Body.String() != "edited" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected the edited file without caching, got %q %v", rec.Body.String(), rec.Header())
+	}
+	if rec := get(t, h, "/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"/static/css/app.css"`) {
+		t.Fatalf("expected the index page as written, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,11 @@
+// Package web holds the browser UI. The files are embedded in the server
+// binary, so it runs from any working directory.
+package web
+
+import "embed"
+
+// Files contains static/ (stylesheets and scripts) and templates/ (the
+// index page).
+//
+//go:embed static templates
+var Files embed.FS